@@ -16,12 +16,18 @@ limitations under the License.
 // Command fetch_repo downloads a Go module or repository at a specific
 // version or commit.
 //
-// In module mode, fetch_repo downloads a module using "go mod download",
-// verifies the contents against a sum, then copies the contents of the module
-// to a target directory. fetch_repo respects GOPATH, GOCACHE, and GOPROXY.
+// In module mode, fetch_repo reads a module's zip file from the module cache
+// or downloads it from GOPROXY, verifies it against a sum, then extracts it
+// into a target directory. With -go_mod_download, fetch_repo falls back to
+// "go mod download" for modules that can't be fetched that way, for example,
+// private modules fetched directly from version control. fetch_repo respects
+// GOPATH, GOMODCACHE, GOPROXY, GONOPROXY, and GOPRIVATE.
 //
 // In repository mode, fetch_repo clones a repository using a VCS tool.
 // fetch_repo performs import path redirection in this mode.
+//
+// In all modes, fetch_repo can apply patches given with -patch to the
+// fetched files. Patches are applied before -clean removes build files.
 package main
 
 import (
@@ -38,6 +44,8 @@ var (
 	dest       = flag.String("dest", "", "destination directory")
 	no_fetch   = flag.Bool("no-fetch", false, "files already exist, do not fetch")
 	clean      = flag.Bool("clean", false, "remove existing bazel build files")
+	patchStrip = flag.Int("patch_strip", 0, "number of leading path components to strip from file names in -patch files")
+	patchFuzz  = flag.Int("patch_fuzz", 0, "maximum number of context lines at the start and end of a hunk that may be ignored when applying -patch files")
 
 	// Repository flags
 	remote = flag.String("remote", "", "The URI of the remote repository. Must be used with the --vcs flag.")
@@ -45,10 +53,19 @@ var (
 	rev    = flag.String("rev", "", "target revision")
 
 	// Module flags
-	version = flag.String("version", "", "module version. Must be semantic version or pseudo-version.")
-	sum     = flag.String("sum", "", "hash of module contents")
+	version       = flag.String("version", "", "module version. Must be semantic version or pseudo-version.")
+	sum           = flag.String("sum", "", "hash of module contents")
+	goModDownload = flag.Bool("go_mod_download", false, "fall back to \"go mod download\" if the module can't be fetched from the module cache or GOPROXY directly")
 )
 
+// patches are unified diffs to apply after fetching, in order. Set with
+// repeated -patch flags.
+var patches stringListFlag
+
+func init() {
+	flag.Var(&patches, "patch", "unified diff to apply to the fetched files before -clean. May be repeated.")
+}
+
 // Override in tests to disable network calls.
 var repoRootForImportPath = vcs.RepoRootForImportPath
 
@@ -109,7 +126,7 @@ func main() {
 		if *sum == "" {
 			log.Fatal("-sum must be set in module mode")
 		}
-		if err := fetchModule(*dest, *importpath, *version, *sum); err != nil {
+		if err := fetchModule(*dest, *importpath, *version, *sum, *goModDownload); err != nil {
 			log.Fatal(err)
 		}
 	} else {
@@ -127,6 +144,15 @@ func main() {
 		}
 	}
 
+	if len(patches) > 0 {
+		if *patchStrip < 0 || *patchFuzz < 0 {
+			log.Fatal("-patch_strip and -patch_fuzz must not be negative")
+		}
+		if err := applyPatches(*dest, patches, *patchStrip, *patchFuzz); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	if *clean {
 		if err := cleanBuildFiles(*dest); err != nil {
 			log.Fatal(err)
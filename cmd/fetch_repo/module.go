@@ -16,13 +16,22 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"strings"
 
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy"
 	"golang.org/x/mod/sumdb/dirhash"
 )
 
-func fetchModule(dest, importpath, version, sum string) error {
+// fetchModule downloads a module version into dest and verifies it against
+// sum. The module's zip file is read from the module cache or fetched from
+// GOPROXY directly. If that fails and goModDownload is true, the module is
+// downloaded with "go mod download" instead, which also supports fetching
+// modules directly from version control.
+func fetchModule(dest, importpath, version, sum string, goModDownload bool) error {
 	// Check that version is a complete semantic version or pseudo-version.
 	if _, ok := parse(version); !ok {
 		return fmt.Errorf("%q is not a valid semantic version", version)
@@ -30,6 +39,35 @@ func fetchModule(dest, importpath, version, sum string) error {
 		return fmt.Errorf("-version must be a complete semantic version. %q is a prefix.", version)
 	}
 
+	zipPath, cleanup, err := downloadModuleZip(importpath, version)
+	if err != nil {
+		if !goModDownload {
+			return err
+		}
+		if !errors.Is(err, goproxy.ErrUseDirect) {
+			log.Printf("falling back to go mod download: %v", err)
+		}
+		return fetchModuleWithGo(dest, importpath, version, sum)
+	}
+	defer cleanup()
+
+	// Verify the zip file before extracting anything from it.
+	zipSum, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	if err != nil {
+		return fmt.Errorf("failed computing sum: %w", err)
+	}
+	if zipSum != sum {
+		if modCache := findModCache(); modCache != "" && strings.HasPrefix(zipPath, modCache) {
+			return fmt.Errorf("module zip %s has sum %s; expected sum %s, Please try clearing your module cache directory %q", zipPath, zipSum, sum, modCache)
+		}
+		return fmt.Errorf("module zip %s has sum %s; expected sum %s", zipPath, zipSum, sum)
+	}
+	return extractModuleZip(dest, zipPath, importpath+"@"+version)
+}
+
+// fetchModuleWithGo downloads a module version with "go mod download" and
+// copies it from the module cache into dest.
+func fetchModuleWithGo(dest, importpath, version, sum string) error {
 	// Download the module. In Go 1.11, this command must be run in a module,
 	// so we create a dummy module in the current directory (which should be
 	// empty).
@@ -0,0 +1,596 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stringListFlag is a flag.Value that accumulates values from repeated flags.
+type stringListFlag []string
+
+func (l *stringListFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringListFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// filePatch is the part of a unified diff that changes a single file.
+type filePatch struct {
+	// oldPath and newPath are the paths from the "---" and "+++" lines or the
+	// "diff --git" line, before leading components are stripped. They are
+	// "/dev/null" for created and deleted files.
+	oldPath, newPath string
+
+	// renameFrom and renameTo are set for git renames. Git writes these
+	// without a prefix, so they are never stripped.
+	renameFrom, renameTo string
+
+	isGit, isNew, isDelete bool
+
+	// mode is the file mode from "new file mode" or "new mode" lines, or 0.
+	mode fs.FileMode
+
+	hunks []*hunk
+}
+
+// hunk is a single "@@" section of a filePatch.
+type hunk struct {
+	header             string
+	oldStart, oldLines int
+	newStart, newLines int
+	lines              []hunkLine
+
+	// line is the line number of the header in the patch file.
+	line int
+}
+
+// hunkLine is a line within a hunk. op is ' ', '-', or '+'. text includes
+// the trailing newline unless the line was followed by
+// "\ No newline at end of file".
+type hunkLine struct {
+	op   byte
+	text string
+}
+
+const devNull = "/dev/null"
+
+// applyPatches applies unified diffs to the files in dir, in order. strip is
+// the number of leading path components removed from file names in the
+// patches, like patch -p. fuzz is the maximum number of context lines at the
+// start and end of each hunk that may be ignored when a hunk doesn't match
+// exactly.
+//
+// Each patch file is applied completely or not at all. If a hunk can't be
+// applied, the error names the patch, file, and hunk and shows the lines the
+// hunk expected next to the lines actually found.
+func applyPatches(dir string, patches []string, strip, fuzz int) error {
+	for _, p := range patches {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("reading patch: %w", err)
+		}
+		fps, err := parsePatch(data)
+		if err != nil {
+			return fmt.Errorf("patch %s: %w", p, err)
+		}
+		if err := applyFilePatches(dir, p, fps, strip, fuzz); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parsePatch parses the file patches in a unified diff. Text outside of file
+// patches, such as a commit message, is ignored.
+func parsePatch(data []byte) ([]*filePatch, error) {
+	lines := splitLines(string(data))
+	var fps []*filePatch
+	var fp *filePatch
+	// inHeader is true while reading lines between "diff --git" and the first
+	// hunk, where git writes extended header lines.
+	inHeader := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			fp = &filePatch{isGit: true}
+			fps = append(fps, fp)
+			inHeader = true
+			if f := strings.Fields(strings.TrimPrefix(line, "diff --git ")); len(f) == 2 {
+				fp.oldPath, fp.newPath = f[0], f[1]
+			}
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if fp == nil || !inHeader {
+				fp = &filePatch{}
+				fps = append(fps, fp)
+			}
+			inHeader = false
+			fp.oldPath = parsePatchPath(line[len("--- "):])
+			fp.newPath = parsePatchPath(strings.TrimSuffix(lines[i+1], "\n")[len("+++ "):])
+			fp.isNew = fp.oldPath == devNull
+			fp.isDelete = fp.newPath == devNull
+			i++
+
+		case strings.HasPrefix(line, "@@ "):
+			if fp == nil {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			inHeader = false
+			h, n, err := parseHunk(lines[i:], i+1)
+			if err != nil {
+				return nil, err
+			}
+			fp.hunks = append(fp.hunks, h)
+			i += n - 1
+
+		case inHeader:
+			if err := parseGitHeaderLine(fp, line); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+		}
+	}
+	if len(fps) == 0 {
+		return nil, errors.New("no file patches found")
+	}
+	return fps, nil
+}
+
+// parseGitHeaderLine records information from an extended header line in a
+// git diff. Lines that don't matter for applying the patch are ignored.
+func parseGitHeaderLine(fp *filePatch, line string) error {
+	switch {
+	case strings.HasPrefix(line, "new file mode "):
+		fp.isNew = true
+		return parseMode(fp, strings.TrimPrefix(line, "new file mode "))
+	case strings.HasPrefix(line, "deleted file mode "):
+		fp.isDelete = true
+	case strings.HasPrefix(line, "new mode "):
+		return parseMode(fp, strings.TrimPrefix(line, "new mode "))
+	case strings.HasPrefix(line, "rename from "):
+		fp.renameFrom = strings.TrimPrefix(line, "rename from ")
+	case strings.HasPrefix(line, "rename to "):
+		fp.renameTo = strings.TrimPrefix(line, "rename to ")
+	case strings.HasPrefix(line, "copy from "), strings.HasPrefix(line, "copy to "):
+		return errors.New("git copies are not supported")
+	case strings.HasPrefix(line, "GIT binary patch"), strings.HasPrefix(line, "Binary files "):
+		return errors.New("binary patches are not supported")
+	}
+	return nil
+}
+
+func parseMode(fp *filePatch, s string) error {
+	mode, err := strconv.ParseUint(strings.TrimSpace(s), 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file mode %q", s)
+	}
+	if mode&0o111 != 0 {
+		fp.mode = 0o777
+	} else {
+		fp.mode = 0o666
+	}
+	return nil
+}
+
+// parsePatchPath returns the path from a "---" or "+++" line, without a
+// trailing timestamp.
+func parsePatchPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if unq, err := strconv.Unquote(s); err == nil {
+		s = unq
+	}
+	return s
+}
+
+// parseHunk parses a hunk starting with the "@@" header at lines[0]. It
+// returns the hunk and the number of lines it spans.
+func parseHunk(lines []string, lineNo int) (*hunk, int, error) {
+	header := strings.TrimSuffix(lines[0], "\n")
+	h := &hunk{header: header, line: lineNo}
+	var ranges string
+	if end := strings.Index(header[len("@@ "):], " @@"); end >= 0 {
+		ranges = header[len("@@ ") : len("@@ ")+end]
+	}
+	f := strings.Fields(ranges)
+	if len(f) != 2 || !strings.HasPrefix(f[0], "-") || !strings.HasPrefix(f[1], "+") {
+		return nil, 0, fmt.Errorf("line %d: invalid hunk header %q", lineNo, header)
+	}
+	var err1, err2 error
+	h.oldStart, h.oldLines, err1 = parseHunkRange(f[0][1:])
+	h.newStart, h.newLines, err2 = parseHunkRange(f[1][1:])
+	if err1 != nil || err2 != nil {
+		return nil, 0, fmt.Errorf("line %d: invalid hunk header %q", lineNo, header)
+	}
+
+	n := 1
+	oldLeft, newLeft := h.oldLines, h.newLines
+	for oldLeft > 0 || newLeft > 0 {
+		if n >= len(lines) {
+			return nil, 0, fmt.Errorf("line %d: hunk %q is truncated", lineNo, header)
+		}
+		line := lines[n]
+		op := byte(' ')
+		text := ""
+		if line == "\n" {
+			// Some tools strip the space from blank context lines.
+			text = line
+		} else {
+			op, text = line[0], line[1:]
+		}
+		switch op {
+		case ' ':
+			oldLeft--
+			newLeft--
+		case '-':
+			oldLeft--
+		case '+':
+			newLeft--
+		case '\\':
+			n++
+			markNoNewline(h)
+			continue
+		default:
+			return nil, 0, fmt.Errorf("line %d: unexpected line in hunk %q", lineNo+n, header)
+		}
+		if oldLeft < 0 || newLeft < 0 {
+			return nil, 0, fmt.Errorf("line %d: hunk %q has more lines than its header says", lineNo+n, header)
+		}
+		h.lines = append(h.lines, hunkLine{op: op, text: text})
+		n++
+	}
+	// A "\ No newline at end of file" marker may follow the last line.
+	if n < len(lines) && strings.HasPrefix(lines[n], `\`) {
+		markNoNewline(h)
+		n++
+	}
+	return h, n, nil
+}
+
+func markNoNewline(h *hunk) {
+	if len(h.lines) > 0 {
+		last := &h.lines[len(h.lines)-1]
+		last.text = strings.TrimSuffix(last.text, "\n")
+	}
+}
+
+func parseHunkRange(s string) (start, count int, err error) {
+	count = 1
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		if count, err = strconv.Atoi(s[i+1:]); err != nil {
+			return 0, 0, err
+		}
+		s = s[:i]
+	}
+	start, err = strconv.Atoi(s)
+	return start, count, err
+}
+
+// applyFilePatches applies the file patches from one patch file. Changes
+// are computed in memory and written only after every hunk has applied.
+func applyFilePatches(dir, patchName string, fps []*filePatch, strip, fuzz int) error {
+	// files holds the new content of changed files. A nil value means the
+	// file is deleted.
+	files := make(map[string]*string)
+	modes := make(map[string]fs.FileMode)
+	var order []string
+	read := func(name string) (string, bool, error) {
+		if content, ok := files[name]; ok {
+			if content == nil {
+				return "", false, nil
+			}
+			return *content, true, nil
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if os.IsNotExist(err) {
+			return "", false, nil
+		} else if err != nil {
+			return "", false, err
+		}
+		return string(data), true, nil
+	}
+	write := func(name string, content *string) {
+		if _, ok := files[name]; !ok {
+			order = append(order, name)
+		}
+		files[name] = content
+	}
+
+	for _, fp := range fps {
+		oldName, newName, err := fp.names(strip)
+		if err != nil {
+			return fmt.Errorf("patch %s: %w", patchName, err)
+		}
+		name := oldName
+		if fp.isNew {
+			name = newName
+		}
+
+		var content string
+		if fp.isNew {
+			if _, exists, err := read(name); err != nil {
+				return err
+			} else if exists {
+				return fmt.Errorf("patch %s: cannot create %s: file already exists", patchName, name)
+			}
+		} else {
+			var exists bool
+			content, exists, err = read(name)
+			if err != nil {
+				return err
+			}
+			if !exists && !fp.isGit && newName != oldName {
+				// Traditional diffs may name the file to patch on either line.
+				if content, exists, err = read(newName); err != nil {
+					return err
+				}
+				name = newName
+			}
+			if !exists {
+				return fmt.Errorf("patch %s: cannot patch %s: file does not exist", patchName, name)
+			}
+		}
+
+		patched, err := applyHunks(name, content, fp.hunks, fuzz)
+		if err != nil {
+			return fmt.Errorf("patch %s: %w", patchName, err)
+		}
+
+		switch {
+		case fp.isDelete:
+			if patched != "" {
+				return fmt.Errorf("patch %s: cannot delete %s: file is not empty after patching", patchName, name)
+			}
+			write(name, nil)
+		case fp.isGit && newName != name:
+			write(name, nil)
+			write(newName, &patched)
+			name = newName
+		default:
+			write(name, &patched)
+		}
+		if fp.mode != 0 {
+			modes[name] = fp.mode
+		}
+	}
+
+	for _, name := range order {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		content := files[name]
+		if content == nil {
+			if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		mode := fs.FileMode(0o666)
+		if fi, err := os.Stat(p); err == nil {
+			mode = fi.Mode().Perm()
+		}
+		if m, ok := modes[name]; ok {
+			mode = m
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, []byte(*content), mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// names returns the slash-separated paths, relative to the patched
+// directory, of the file before and after the patch.
+func (fp *filePatch) names(strip int) (oldName, newName string, err error) {
+	resolve := func(p string, doStrip bool) (string, error) {
+		if p == devNull || p == "" {
+			return "", nil
+		}
+		if doStrip {
+			p = strings.TrimLeft(p, "/")
+			for i := 0; i < strip; i++ {
+				j := strings.IndexByte(p, '/')
+				if j < 0 {
+					return "", fmt.Errorf("cannot strip %d leading components from %q", strip, p)
+				}
+				p = strings.TrimLeft(p[j+1:], "/")
+			}
+		}
+		clean := filepath.ToSlash(filepath.Clean(filepath.FromSlash(p)))
+		if filepath.IsAbs(p) || clean == ".." || strings.HasPrefix(clean, "../") || clean == "." {
+			return "", fmt.Errorf("invalid file name %q in patch", p)
+		}
+		return clean, nil
+	}
+
+	if fp.renameFrom != "" {
+		oldName, err = resolve(fp.renameFrom, false)
+	} else {
+		oldName, err = resolve(fp.oldPath, true)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if fp.renameTo != "" {
+		newName, err = resolve(fp.renameTo, false)
+	} else {
+		newName, err = resolve(fp.newPath, true)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if oldName == "" {
+		oldName = newName
+	}
+	if newName == "" {
+		newName = oldName
+	}
+	if oldName == "" {
+		return "", "", errors.New("file patch has no file name")
+	}
+	return oldName, newName, nil
+}
+
+// applyHunks applies hunks to the content of the file name. Each hunk is
+// matched first at the line it names (shifted by the offset at which the
+// previous hunk applied), then at increasing distances from it. If it
+// doesn't match anywhere, up to fuzz lines of leading and trailing context
+// are ignored and the search is repeated.
+func applyHunks(name, content string, hunks []*hunk, fuzz int) (string, error) {
+	lines := splitLines(content)
+	var out []string
+	pos := 0
+	offset := 0
+	for i, h := range hunks {
+		var oldText, newText []string
+		for _, l := range h.lines {
+			if l.op != '+' {
+				oldText = append(oldText, l.text)
+			}
+			if l.op != '-' {
+				newText = append(newText, l.text)
+			}
+		}
+		leading, trailing := 0, 0
+		for leading < len(h.lines) && h.lines[leading].op == ' ' {
+			leading++
+		}
+		for trailing < len(h.lines)-leading && h.lines[len(h.lines)-1-trailing].op == ' ' {
+			trailing++
+		}
+		start := h.oldStart - 1
+		if h.oldLines == 0 {
+			// An empty old range names the line after which lines are added.
+			start = h.oldStart
+		}
+
+		applied := false
+		for f := 0; f <= fuzz && !applied; f++ {
+			trimStart, trimEnd := min(f, leading), min(f, trailing)
+			if f > 0 && trimStart == 0 && trimEnd == 0 {
+				break
+			}
+			pattern := oldText[trimStart : len(oldText)-trimEnd]
+			m, ok := findLines(lines, pattern, pos, start+trimStart+offset)
+			if !ok {
+				continue
+			}
+			if m != start+trimStart+offset || f > 0 {
+				log.Printf("%s: hunk #%d succeeded at %d (offset %d lines, fuzz %d)", name, i+1, m-trimStart+1, m-trimStart-start, f)
+			}
+			out = append(out, lines[pos:m]...)
+			out = append(out, newText[trimStart:len(newText)-trimEnd]...)
+			pos = m + len(pattern)
+			offset = m - trimStart - start
+			applied = true
+		}
+		if !applied {
+			return "", hunkFailure(name, i, h, lines, start+offset)
+		}
+	}
+	out = append(out, lines[pos:]...)
+	return strings.Join(out, ""), nil
+}
+
+// findLines returns the index of the occurrence of pattern in lines closest
+// to want that starts at or after pos.
+func findLines(lines, pattern []string, pos, want int) (int, bool) {
+	last := len(lines) - len(pattern)
+	if want < pos {
+		want = pos
+	}
+	if want > last {
+		want = last
+	}
+	for d := 0; want-d >= pos || want+d <= last; d++ {
+		for _, m := range []int{want - d, want + d} {
+			if m >= pos && m <= last && matchLines(lines[m:m+len(pattern)], pattern) {
+				return m, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func matchLines(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkFailure describes a hunk that could not be applied. It shows the hunk
+// and the lines of the file where the hunk was expected to apply.
+func hunkFailure(name string, i int, h *hunk, lines []string, at int) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s: hunk #%d (%s, patch line %d) failed to apply\n", name, i+1, h.header, h.line)
+	fmt.Fprintf(buf, "hunk:\n")
+	for _, l := range h.lines {
+		fmt.Fprintf(buf, "\t%c%s\n", l.op, strings.TrimSuffix(l.text, "\n"))
+	}
+	const margin = 3
+	oldLines := 0
+	for _, l := range h.lines {
+		if l.op != '+' {
+			oldLines++
+		}
+	}
+	from, to := max(at-margin, 0), min(at+oldLines+margin, len(lines))
+	if from >= to {
+		fmt.Fprintf(buf, "%s has %d lines", name, len(lines))
+		return errors.New(buf.String())
+	}
+	fmt.Fprintf(buf, "%s, lines %d-%d:\n", name, from+1, to)
+	for j := from; j < to; j++ {
+		fmt.Fprintf(buf, "\t%5d %s\n", j+1, strings.TrimSuffix(lines[j], "\n"))
+	}
+	return errors.New(strings.TrimSuffix(buf.String(), "\n"))
+}
+
+// splitLines splits s into lines, keeping the newline at the end of each.
+func splitLines(s string) []string {
+	var lines []string
+	for s != "" {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+	return lines
+}
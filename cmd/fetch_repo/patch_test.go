@@ -0,0 +1,217 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func checkFiles(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if content == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s: got error %v; want file not to exist", name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Error(err)
+		} else if string(data) != content {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", name, data, content)
+		}
+	}
+}
+
+func TestApplyPatches(t *testing.T) {
+	for _, tc := range []struct {
+		desc        string
+		files       map[string]string
+		patch       string
+		strip, fuzz int
+		want        map[string]string
+	}{
+		{
+			desc: "modify",
+			files: map[string]string{
+				"foo.go": "package foo\n\nfunc A() {}\n\nfunc B() {}\n",
+			},
+			patch: `commit message
+
+--- a/foo.go	2026-01-01 00:00:00
++++ b/foo.go	2026-01-01 00:00:00
+@@ -3,3 +3,3 @@
+ func A() {}
+
+-func B() {}
++func B() { A() }
+`,
+			strip: 1,
+			want: map[string]string{
+				"foo.go": "package foo\n\nfunc A() {}\n\nfunc B() { A() }\n",
+			},
+		}, {
+			desc: "offset",
+			files: map[string]string{
+				"foo.txt": "x\nx\na\nb\nc\n",
+			},
+			patch: `--- foo.txt
++++ foo.txt
+@@ -1,3 +1,3 @@
+ a
+-b
++B
+ c
+`,
+			want: map[string]string{
+				"foo.txt": "x\nx\na\nB\nc\n",
+			},
+		}, {
+			desc: "fuzz",
+			files: map[string]string{
+				"foo.txt": "a\nb\nc\nd\ne\n",
+			},
+			patch: `--- a/foo.txt
++++ b/foo.txt
+@@ -1,5 +1,5 @@
+ changed
+ b
+-c
++C
+ d
+ changed
+`,
+			strip: 1,
+			fuzz:  1,
+			want: map[string]string{
+				"foo.txt": "a\nb\nC\nd\ne\n",
+			},
+		}, {
+			desc: "git create delete rename",
+			files: map[string]string{
+				"old.txt":    "a\nb\n",
+				"delete.txt": "gone\n",
+			},
+			patch: `diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3b18e51
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+\ No newline at end of file
+diff --git a/delete.txt b/delete.txt
+deleted file mode 100644
+--- a/delete.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+diff --git a/old.txt b/sub/renamed.txt
+similarity index 50%
+rename from old.txt
+rename to sub/renamed.txt
+--- a/old.txt
++++ b/sub/renamed.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`,
+			strip: 1,
+			want: map[string]string{
+				"new.txt":         "hello",
+				"delete.txt":      "",
+				"old.txt":         "",
+				"sub/renamed.txt": "a\nc\n",
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.files)
+			patchPath := filepath.Join(t.TempDir(), "p.patch")
+			writeFiles(t, filepath.Dir(patchPath), map[string]string{"p.patch": tc.patch})
+			if err := applyPatches(dir, []string{patchPath}, tc.strip, tc.fuzz); err != nil {
+				t.Fatal(err)
+			}
+			checkFiles(t, dir, tc.want)
+		})
+	}
+}
+
+func TestApplyPatchesFailure(t *testing.T) {
+	dir := t.TempDir()
+	orig := map[string]string{
+		"a.txt": "one\ntwo\n",
+		"b.txt": "1\n2\n3\n4\n5\n",
+	}
+	writeFiles(t, dir, orig)
+	patch := `--- a.txt
++++ a.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+--- b.txt
++++ b.txt
+@@ -1,2 +1,2 @@
+-1
++one
+ 2
+@@ -4,2 +4,2 @@
+-four
++FOUR
+ 5
+`
+	patchPath := filepath.Join(t.TempDir(), "bad.patch")
+	if err := os.WriteFile(patchPath, []byte(patch), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	err := applyPatches(dir, []string{patchPath}, 0, 2)
+	if err == nil {
+		t.Fatal("got success; want error")
+	}
+	for _, want := range []string{
+		"patch " + patchPath,
+		"b.txt: hunk #2 (@@ -4,2 +4,2 @@, patch line 13) failed to apply",
+		"\t-four\n",
+		"\t    4 4\n",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not contain %q:\n%v", want, err)
+		}
+	}
+	// Nothing is written if any hunk fails.
+	checkFiles(t, dir, orig)
+}
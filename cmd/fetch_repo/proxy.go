@@ -0,0 +1,181 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy"
+	"golang.org/x/mod/module"
+)
+
+// Limits on files in module zip files, matching those enforced by the go
+// command in golang.org/x/mod/zip.
+const (
+	maxGoMod   = 16 << 20
+	maxLICENSE = 16 << 20
+)
+
+// downloadModuleZip locates the zip file for a module version. The module
+// cache is checked first, then each proxy in GOPROXY. Zip files from file://
+// proxies and the module cache are used in place; others are downloaded to a
+// temporary file, which the returned cleanup function removes.
+func downloadModuleZip(importpath, version string) (zipPath string, cleanup func(), err error) {
+	if modCache := findModCache(); modCache != "" {
+		escPath, err := module.EscapePath(importpath)
+		if err != nil {
+			return "", nil, err
+		}
+		escVersion, err := module.EscapeVersion(version)
+		if err != nil {
+			return "", nil, err
+		}
+		p := filepath.Join(modCache, "cache", "download", filepath.FromSlash(escPath), "@v", escVersion+".zip")
+		if _, err := os.Stat(p); err == nil {
+			return p, func() {}, nil
+		}
+	}
+
+	proxy, err := goproxy.NewFromEnv()
+	if err != nil {
+		return "", nil, err
+	}
+	return proxy.DownloadZip(importpath, version)
+}
+
+// findModCache returns the module cache directory, from GOMODCACHE or the
+// first entry in GOPATH. It returns "" if neither is set.
+func findModCache() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	if gopath := filepath.SplitList(os.Getenv("GOPATH")); len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod")
+	}
+	return ""
+}
+
+// extractModuleZip extracts a module zip file into dest. Every file in the
+// zip must be under the directory prefix ("module@version"). Paths that
+// would escape dest, duplicate files, and files or archives over the size
+// limits are rejected before anything is written.
+func extractModuleZip(dest, zipPath, prefix string) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("opening module zip: %w", err)
+	}
+	defer zr.Close()
+
+	prefix += "/"
+	seen := make(map[string]bool)
+	var total uint64
+	for _, f := range zr.File {
+		name, err := moduleZipFileName(f.Name, prefix)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		if seen[strings.ToLower(name)] {
+			return fmt.Errorf("module zip: multiple files named %q", name)
+		}
+		seen[strings.ToLower(name)] = true
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("module zip: %q is not a regular file", name)
+		}
+		total += f.UncompressedSize64
+		if total > goproxy.MaxZipFile {
+			return fmt.Errorf("module zip: total size of files exceeds %d bytes", goproxy.MaxZipFile)
+		}
+		if limit := fileSizeLimit(name); f.UncompressedSize64 > limit {
+			return fmt.Errorf("module zip: %q is larger than %d bytes", name, limit)
+		}
+	}
+
+	for _, f := range zr.File {
+		name, _ := moduleZipFileName(f.Name, prefix)
+		if name == "" {
+			continue
+		}
+		if err := extractZipFile(filepath.Join(dest, filepath.FromSlash(name)), f); err != nil {
+			return fmt.Errorf("module zip: extracting %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// moduleZipFileName returns the path of a zip entry relative to prefix.
+// It returns "" for directory entries.
+func moduleZipFileName(zipName, prefix string) (string, error) {
+	if !strings.HasPrefix(zipName, prefix) {
+		return "", fmt.Errorf("module zip: %q is not in directory %q", zipName, prefix)
+	}
+	name := zipName[len(prefix):]
+	if name == "" || strings.HasSuffix(name, "/") {
+		return "", nil
+	}
+	if strings.Contains(name, `\`) || pathpkg.IsAbs(name) || pathpkg.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("module zip: invalid file name %q", zipName)
+	}
+	return name, nil
+}
+
+func fileSizeLimit(name string) uint64 {
+	switch name {
+	case "go.mod":
+		return maxGoMod
+	case "LICENSE":
+		return maxLICENSE
+	}
+	return goproxy.MaxZipFile
+}
+
+// extractZipFile writes a single zip entry to dest. The declared size is not
+// trusted; no more than that many bytes are written.
+func extractZipFile(dest string, f *zip.File) (err error) {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o777); err != nil {
+		return err
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); err == nil && cerr != nil {
+			err = cerr
+		}
+	}()
+	n, err := io.Copy(w, io.LimitReader(r, int64(f.UncompressedSize64)+1))
+	if err != nil {
+		return err
+	}
+	if uint64(n) != f.UncompressedSize64 {
+		return fmt.Errorf("uncompressed size does not match header")
+	}
+	return nil
+}
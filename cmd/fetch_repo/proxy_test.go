@@ -0,0 +1,182 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+type zipEntry struct {
+	name, content string
+}
+
+// writeModuleZip writes a zip file at dir/rel and returns its sum.
+func writeModuleZip(t *testing.T, dir, rel string, entries []zipEntry) string {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, e := range entries {
+		fw, err := w.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	sum, err := dirhash.HashZip(p, dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sum
+}
+
+func setProxyEnv(t *testing.T, goproxy, private string) {
+	t.Setenv("GOPROXY", goproxy)
+	t.Setenv("GOPRIVATE", private)
+	t.Setenv("GONOPROXY", private)
+	t.Setenv("GOMODCACHE", "")
+	t.Setenv("GOPATH", "")
+}
+
+func TestFetchModuleFromProxy(t *testing.T) {
+	proxyDir := t.TempDir()
+	sum := writeModuleZip(t, proxyDir, "example.com/!foo/@v/v1.0.0.zip", []zipEntry{
+		{"example.com/Foo@v1.0.0/go.mod", "module example.com/Foo\n"},
+		{"example.com/Foo@v1.0.0/sub/foo.go", "package sub\n"},
+	})
+	setProxyEnv(t, "file://"+filepath.ToSlash(t.TempDir())+",file://"+filepath.ToSlash(proxyDir), "")
+
+	dest := t.TempDir()
+	if err := fetchModule(dest, "example.com/Foo", "v1.0.0", sum, false); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dest, "sub", "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "package sub\n" {
+		t.Errorf("got %q; want %q", got, "package sub\n")
+	}
+	if destSum, err := dirhash.HashDir(dest, "example.com/Foo@v1.0.0", dirhash.Hash1); err != nil || destSum != sum {
+		t.Errorf("extracted module has sum %q, %v; want %q", destSum, err, sum)
+	}
+
+	if err := fetchModule(t.TempDir(), "example.com/Foo", "v1.0.0", "h1:wrong=", false); err == nil || !strings.Contains(err.Error(), "expected sum h1:wrong=") {
+		t.Errorf("wrong sum: got error %v", err)
+	}
+}
+
+func TestFetchModuleFromModCache(t *testing.T) {
+	modCache := t.TempDir()
+	sum := writeModuleZip(t, modCache, "cache/download/example.com/foo/@v/v1.0.0.zip", []zipEntry{
+		{"example.com/foo@v1.0.0/go.mod", "module example.com/foo\n"},
+	})
+	setProxyEnv(t, "off", "")
+	t.Setenv("GOMODCACHE", modCache)
+
+	dest := t.TempDir()
+	if err := fetchModule(dest, "example.com/foo", "v1.0.0", sum, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "go.mod")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFetchModuleDirect(t *testing.T) {
+	for _, tc := range []struct {
+		desc, goproxy, private string
+	}{
+		{desc: "direct", goproxy: "file://" + filepath.ToSlash(t.TempDir()) + ",direct"},
+		{desc: "private", goproxy: "https://proxy.example.com", private: "example.com/private"},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			setProxyEnv(t, tc.goproxy, tc.private)
+			_, _, err := downloadModuleZip("example.com/private", "v1.0.0")
+			if !errors.Is(err, goproxy.ErrUseDirect) {
+				t.Errorf("got error %v; want %v", err, goproxy.ErrUseDirect)
+			}
+		})
+	}
+}
+
+func TestExtractModuleZipInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		entries []zipEntry
+		wantErr string
+	}{
+		{
+			desc:    "parent directory",
+			entries: []zipEntry{{"example.com/foo@v1.0.0/../../evil.go", ""}},
+			wantErr: "invalid file name",
+		}, {
+			desc:    "outside prefix",
+			entries: []zipEntry{{"example.com/bar@v1.0.0/bar.go", ""}},
+			wantErr: "is not in directory",
+		}, {
+			desc:    "backslash",
+			entries: []zipEntry{{`example.com/foo@v1.0.0/..\evil.go`, ""}},
+			wantErr: "invalid file name",
+		}, {
+			desc: "duplicate",
+			entries: []zipEntry{
+				{"example.com/foo@v1.0.0/foo.go", ""},
+				{"example.com/foo@v1.0.0/FOO.go", ""},
+			},
+			wantErr: "multiple files",
+		}, {
+			desc:    "large go.mod",
+			entries: []zipEntry{{"example.com/foo@v1.0.0/go.mod", strings.Repeat("x", maxGoMod+1)}},
+			wantErr: "larger than",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			writeModuleZip(t, dir, "foo.zip", tc.entries)
+			dest := t.TempDir()
+			err := extractModuleZip(dest, filepath.Join(dir, "foo.zip"), "example.com/foo@v1.0.0")
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("got error %v; want error containing %q", err, tc.wantErr)
+			}
+			if files, _ := os.ReadDir(dest); len(files) > 0 {
+				t.Errorf("files were extracted despite the error: %v", files)
+			}
+		})
+	}
+}
@@ -47,7 +47,7 @@ func diffFile(c *config.Config, f *rule.File) error {
 		ToDate:   date,
 	}
 
-	newContent := f.Format()
+	newContent := formatFile(c, f)
 	if bytes.Equal(newContent, f.Content) {
 		// No change.
 		return nil
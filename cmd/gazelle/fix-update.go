@@ -55,10 +55,45 @@ type updateConfig struct {
 	patchBuffer    bytes.Buffer
 	print0         bool
 	profile        profiler
+	remoteCache    remoteCacheFlags
+
+	// patterns are the target patterns given as positional arguments,
+	// including directories, which are converted to patterns. Only rules
+	// matching the patterns are merged. patterns is nil if only directories
+	// were given.
+	patterns []label.Pattern
+
+	// whyModule is the module path or repository name passed to why-module.
+	whyModule string
+
+	// minimalEdits is true if only statements that changed should be
+	// formatted when build files are written. Set with -minimal_edits.
+	minimalEdits bool
+
+	// mergeState holds the values generated for each attribute the last time
+	// Gazelle ran. When it's set, attributes are merged three ways, and
+	// mergeState is written back to mergeStatePath in fix mode.
+	mergeState      *merger.State
+	mergeStatePath  string
+	writeMergeState bool
+
+	// renameLabels is true if references to rules renamed while merging
+	// should be updated in all build files in the repository, not just the
+	// files containing the rules. Set with -rename_labels.
+	renameLabels bool
 }
 
 type emitFunc func(c *config.Config, f *rule.File) error
 
+// formatFile returns the new content of a build file. Emit functions should
+// call this instead of f.Format.
+func formatFile(c *config.Config, f *rule.File) []byte {
+	if uc, ok := c.Exts[updateName].(*updateConfig); ok && uc.minimalEdits {
+		return f.FormatMinimal()
+	}
+	return f.Format()
+}
+
 var modeFromName = map[string]emitFunc{
 	"print": printFile,
 	"fix":   fixFile,
@@ -74,6 +109,7 @@ func getUpdateConfig(c *config.Config) *updateConfig {
 var _ config.Configurer = (*updateConfigurer)(nil)
 
 type updateConfigurer struct {
+	cmd            string
 	mode           string
 	recursive      bool
 	knownImports   []string
@@ -86,16 +122,21 @@ func (ucr *updateConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *conf
 	uc := &updateConfig{}
 	c.Exts[updateName] = uc
 
+	ucr.cmd = cmd
 	c.ShouldFix = cmd == "fix"
 
 	fs.StringVar(&ucr.mode, "mode", "fix", "print: prints all of the updated BUILD files\n\tfix: rewrites all of the BUILD files in place\n\tdiff: computes the rewrite but then just does a diff")
 	fs.BoolVar(&ucr.recursive, "r", true, "when true, gazelle will update subdirectories recursively")
 	fs.StringVar(&uc.patchPath, "patch", "", "when set with -mode=diff, gazelle will write to a file instead of stdout")
 	fs.BoolVar(&uc.print0, "print0", false, "when set with -mode=fix, gazelle will print the names of rewritten files separated with \\0 (NULL)")
+	fs.BoolVar(&uc.minimalEdits, "minimal_edits", false, "when true, gazelle only reformats statements it inserts or changes, leaving the rest of each build file byte-for-byte unchanged")
+	fs.StringVar(&uc.mergeStatePath, "merge_state", "", "file where gazelle records the attribute values it generates, relative to the repository root. When set, attributes edited by hand are merged with newly generated values three ways instead of being replaced")
+	fs.BoolVar(&uc.renameLabels, "rename_labels", false, "when true, gazelle updates labels that refer to rules it renamed while merging in all build files in the repository, not only in the files containing those rules")
 	fs.StringVar(&ucr.cpuProfile, "cpuprofile", "", "write cpu profile to `file`")
 	fs.StringVar(&ucr.memProfile, "memprofile", "", "write memory profile to `file`")
 	fs.Var(&gzflag.MultiFlag{Values: &ucr.knownImports}, "known_import", "import path for which external resolution is skipped (can specify multiple times)")
 	fs.StringVar(&ucr.repoConfigPath, "repo_config", "", "file where Gazelle should load repository configuration. Defaults to WORKSPACE.")
+	uc.remoteCache.register(fs)
 }
 
 func (ucr *updateConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
@@ -112,20 +153,57 @@ func (ucr *updateConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) erro
 	if uc.patchPath != "" && !filepath.IsAbs(uc.patchPath) {
 		uc.patchPath = filepath.Join(c.WorkDir, uc.patchPath)
 	}
+	if uc.mergeStatePath != "" {
+		if !filepath.IsAbs(uc.mergeStatePath) {
+			uc.mergeStatePath = filepath.Join(c.RepoRoot, uc.mergeStatePath)
+		}
+		s, err := merger.ReadState(uc.mergeStatePath)
+		if err != nil {
+			return fmt.Errorf("-merge_state: %w", err)
+		}
+		uc.mergeState = s
+		uc.writeMergeState = ucr.mode == "fix"
+	}
 	p, err := newProfiler(ucr.cpuProfile, ucr.memProfile)
 	if err != nil {
 		return err
 	}
 	uc.profile = p
+	uc.remoteCache.check(c)
 
 	dirs := fs.Args()
+	if ucr.cmd == "why-module" {
+		if len(dirs) != 1 {
+			return fmt.Errorf("got %d positional arguments; wanted a module path or repository name.\nTry -help for more information.", len(dirs))
+		}
+		uc.whyModule = dirs[0]
+		// Any package may depend on the module, so visit the whole repository.
+		dirs = []string{c.RepoRoot}
+	}
 	if len(dirs) == 0 {
 		dirs = []string{"."}
 	}
-	uc.dirs = make([]string, len(dirs))
-	for i, arg := range dirs {
+	uc.dirs = make([]string, 0, len(dirs))
+	recursive := ucr.recursive
+	havePatterns := false
+	for _, arg := range dirs {
 		dir := arg
-		if !filepath.IsAbs(dir) {
+		var p label.Pattern
+		if isTargetPattern(arg) {
+			var err error
+			p, err = parseUpdatePattern(c, arg)
+			if err != nil {
+				return err
+			}
+			havePatterns = true
+			if p.Negative {
+				// Negative patterns only remove targets named by other patterns,
+				// so there's no directory to visit.
+				uc.patterns = append(uc.patterns, p)
+				continue
+			}
+			dir = filepath.Join(c.RepoRoot, filepath.FromSlash(p.Pkg))
+		} else if !filepath.IsAbs(dir) {
 			dir = filepath.Join(c.WorkDir, dir)
 		}
 		dir, err = filepath.EvalSymlinks(dir)
@@ -135,18 +213,44 @@ func (ucr *updateConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) erro
 		if !isDescendingDir(dir, c.RepoRoot) {
 			return fmt.Errorf("%s: not a subdirectory of repo root %s", arg, c.RepoRoot)
 		}
-		uc.dirs[i] = dir
+		if !isTargetPattern(arg) {
+			// A directory names all targets in the directory and, with -r,
+			// in its subdirectories.
+			rel, _ := filepath.Rel(c.RepoRoot, dir)
+			if rel = filepath.ToSlash(rel); rel == "." {
+				rel = ""
+			}
+			p = label.Pattern{Pkg: rel, Name: "all", Recursive: ucr.recursive}
+			if p.Recursive {
+				p.Name = ""
+			}
+		}
+		uc.dirs = append(uc.dirs, dir)
+		uc.patterns = append(uc.patterns, p)
+	}
+	if havePatterns {
+		// Patterns say whether they're recursive; -r only applies to
+		// directories. Packages visited but not matched by a pattern aren't
+		// updated.
+		recursive = false
+		for _, p := range uc.patterns {
+			recursive = recursive || (p.Recursive && !p.Negative)
+		}
+	} else {
+		uc.patterns = nil
 	}
 
-	indexAll := c.IndexLibraries && !c.IndexLazy
+	// Every directory is visited if libraries are indexed eagerly or if
+	// labels of renamed rules need to be updated in every build file.
+	visitAll := (c.IndexLibraries && !c.IndexLazy) || uc.renameLabels
 	switch {
-	case ucr.recursive && indexAll:
+	case recursive && visitAll:
 		uc.walkMode = walk.VisitAllUpdateSubdirsMode
-	case !ucr.recursive && indexAll:
+	case !recursive && visitAll:
 		uc.walkMode = walk.VisitAllUpdateDirsMode
-	case ucr.recursive && !indexAll:
+	case recursive && !visitAll:
 		uc.walkMode = walk.UpdateSubdirsMode
-	case !ucr.recursive && !indexAll:
+	case !recursive && !visitAll:
 		uc.walkMode = walk.UpdateDirsMode
 	}
 
@@ -188,6 +292,22 @@ func (ucr *updateConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) erro
 		}
 	}
 
+	// With Bzlmod, Go dependencies are declared with go_deps tags in
+	// MODULE.bazel instead of go_repository rules. Resolve imports of those
+	// modules to the names their repositories are visible as. Repositories
+	// missing from use_repo are resolved to the names go_deps gives them;
+	// the Go extension warns about those.
+	for _, r := range c.GoDepsRepos {
+		name := r.ApparentName
+		if name == "" {
+			name = r.RepoName
+		}
+		uc.repos = append(uc.repos, repo.Repo{
+			Name:     name,
+			GoPrefix: r.ModulePath,
+		})
+	}
+
 	// If the repo configuration file is not WORKSPACE, also load WORKSPACE
 	// and any declared macro files so we can apply fixes.
 	workspacePath := wspace.FindWORKSPACEFile(c.RepoRoot)
@@ -246,7 +366,9 @@ type visitRecord struct {
 	// file is the build file being processed.
 	file *rule.File
 
-	// mappedKinds are mapped kinds used during this visit.
+	// mappedKinds are mapped kinds used during this visit. mappedKindInfo
+	// holds metadata for mapped kinds and kinds declared with
+	// # gazelle:kind_info, overriding the metadata languages provide.
 	mappedKinds    []config.MappedKind
 	mappedKindInfo map[string]rule.KindInfo
 }
@@ -307,6 +429,9 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 
 	// Visit all directories in the repository.
 	var visits []visitRecord
+	// otherFiles holds build files in directories that aren't updated. They're
+	// kept to update labels of rules that were moved, split, or renamed.
+	var otherFiles []visitRecord
 	uc := getUpdateConfig(c)
 	defer func() {
 		if err := uc.profile.stop(); err != nil {
@@ -318,7 +443,7 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 		dir := args.Dir
 		rel := args.Rel
 		c := args.Config
-		update := args.Update
+		update := args.Update && (uc.patterns == nil || matchesPackage(uc.patterns, rel))
 		f := args.File
 		subdirs := args.Subdirs
 		regularFiles := args.RegularFiles
@@ -336,6 +461,9 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 					ruleIndex.AddRule(c, r, f)
 				}
 			}
+			if f != nil {
+				otherFiles = append(otherFiles, visitRecord{pkgRel: rel, c: c, file: f})
+			}
 			return walk.Walk2FuncResult{}
 		}
 
@@ -372,10 +500,21 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 				relsToVisit = append(relsToVisit, res.RelsToIndex...)
 			}
 		}
+		// If targets were named on the command line, only merge rules they
+		// match, leaving other rules in the package unchanged.
+		if uc.patterns != nil {
+			gen, imports = filterRulesByPatterns(uc.patterns, rel, gen, imports)
+			empty, _ = filterRulesByPatterns(uc.patterns, rel, empty, nil)
+		}
 		if f == nil && len(gen) == 0 {
 			return walk.Walk2FuncResult{RelsToVisit: relsToVisit}
 		}
 
+		// Set attributes declared with # gazelle:default_attr and
+		// # gazelle:enforce_attr. This is done before kinds are mapped, so
+		// directives name the kinds languages generate.
+		applyDefaultAttrs(c, gen)
+
 		// Apply and record relevant kind mappings.
 		var (
 			mappedKinds    []config.MappedKind
@@ -441,7 +580,30 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 			}
 		}
 
+		// Apply metadata declared with # gazelle:kind_info. Declarations for
+		// aliased kinds extend the metadata of the kinds they wrap.
+		for kind, info := range c.KindInfos {
+			base, ok := mappedKindInfo[kind]
+			if !ok {
+				base, ok = kinds[kind]
+			}
+			if underlying, isAlias := c.AliasMap[kind]; !ok && isAlias {
+				base = kinds[underlying]
+			}
+			mappedKindInfo[kind] = base.Union(info)
+		}
+
 		// Insert or merge rules into the build file.
+		normalizeLabels(c, rel, f, gen, unionKindInfoMaps(kinds, mappedKindInfo))
+		if uc.mergeState != nil {
+			uc.mergeState.AttachBase(rel, gen)
+			if uc.patterns != nil {
+				// Keep values recorded for rules in the package that weren't named.
+				uc.mergeState.RecordRules(rel, gen, merger.PreResolve, unionKindInfoMaps(kinds, mappedKindInfo))
+			} else {
+				uc.mergeState.Record(rel, gen, merger.PreResolve, unionKindInfoMaps(kinds, mappedKindInfo))
+			}
+		}
 		if f == nil {
 			f = rule.EmptyFile(filepath.Join(dir, c.DefaultBuildFileName()), rel)
 			for _, r := range gen {
@@ -487,11 +649,18 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 		return walkErr
 	}
 
+	// Move sources that are now in different packages. Rules created here
+	// are indexed too.
+	changedFiles := movePackageSources(c, visits, otherFiles, ruleIndex, kinds)
+
 	// Finish building the index for dependency resolution.
 	ruleIndex.Finish()
 
 	// Resolve dependencies.
-	rc, cleanupRc := repo.NewRemoteCache(uc.repos)
+	rc, cleanupRc, err := uc.remoteCache.newRemoteCache(uc.repos)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if cerr := cleanupRc(); err == nil && cerr != nil {
 			err = cerr
@@ -507,16 +676,34 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 				rslv.Resolve(v.c, ruleIndex, rc, r, v.imports[i], from)
 			}
 		}
+		normalizeLabels(v.c, v.pkgRel, v.file, v.rules, unionKindInfoMaps(kinds, v.mappedKindInfo))
+		if uc.mergeState != nil {
+			uc.mergeState.Record(v.pkgRel, v.rules, merger.PostResolve, unionKindInfoMaps(kinds, v.mappedKindInfo))
+		}
 		merger.MergeFile(v.file, v.empty, v.rules, merger.PostResolve,
 			unionKindInfoMaps(kinds, v.mappedKindInfo),
 			v.c.AliasMap,
 		)
 	}
+	if uc.renameLabels {
+		changedFiles = append(changedFiles, renameLabelsInRepo(visits, otherFiles)...)
+	}
 	for _, lang := range languages {
 		if life, ok := lang.(language.LifecycleManager); ok {
 			life.AfterResolvingDeps(ctx)
 		}
 	}
+	if cmd == whyModuleCmd {
+		return whyModule(c, ruleIndex, mrslv, kinds, visits)
+	}
+	var checkErrs []error
+	for _, lang := range languages {
+		if checker, ok := lang.(language.CheckingLanguage); ok {
+			if err := checker.Check(c); err != nil {
+				checkErrs = append(checkErrs, fmt.Errorf("%s: %w", lang.Name(), err))
+			}
+		}
+	}
 
 	// Emit merged files.
 	var exit error
@@ -530,15 +717,66 @@ func runFixUpdate(wd string, cmd command, args []string) (err error) {
 			}
 		}
 	}
+	emitted := make(map[*rule.File]bool)
+	for _, v := range changedFiles {
+		if emitted[v.file] {
+			continue
+		}
+		emitted[v.file] = true
+		if err := uc.emit(v.c, v.file); err != nil {
+			if err == errExit {
+				exit = err
+			} else {
+				log.Print(err)
+			}
+		}
+	}
+	if uc.writeMergeState {
+		if err := uc.mergeState.Write(uc.mergeStatePath); err != nil {
+			return err
+		}
+	}
 	if uc.patchPath != "" {
 		if err := os.WriteFile(uc.patchPath, uc.patchBuffer.Bytes(), 0o666); err != nil {
 			return err
 		}
 	}
+	if len(checkErrs) > 0 {
+		return errors.Join(checkErrs...)
+	}
 
 	return exit
 }
 
+// renameLabelsInRepo updates labels that refer to rules renamed by
+// merger.MergeFile in any visited file. Labels are updated in visited files
+// and in otherFiles, the build files in directories that weren't updated.
+// renameLabelsInRepo returns the records for otherFiles that were changed,
+// which need to be emitted along with visited files.
+func renameLabelsInRepo(visits, otherFiles []visitRecord) []visitRecord {
+	renames := make(map[label.Label]label.Label)
+	for _, v := range visits {
+		for _, r := range v.file.Rules {
+			if from, ok := r.PrivateAttr(merger.RenamedFromKey).(string); ok {
+				renames[label.New("", v.pkgRel, from)] = label.New("", v.pkgRel, r.Name())
+			}
+		}
+	}
+	if len(renames) == 0 {
+		return nil
+	}
+	for _, v := range visits {
+		merger.RenameLabels(v.file, renames)
+	}
+	var changed []visitRecord
+	for _, v := range otherFiles {
+		if merger.RenameLabels(v.file, renames) {
+			changed = append(changed, v)
+		}
+	}
+	return changed
+}
+
 // lookupMapKindReplacement finds a mapped replacement for rule kind `kind`, resolving transitively.
 // i.e. if go_library is mapped to custom_go_library, and custom_go_library is mapped to other_go_library,
 // looking up go_library will return other_go_library.
@@ -585,7 +823,11 @@ func newFixUpdateConfiguration(wd string, cmd command, args []string, cexts []co
 
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
-			fixUpdateUsage(fs)
+			if cmd == whyModuleCmd {
+				whyModuleUsage(fs)
+			} else {
+				fixUpdateUsage(fs)
+			}
 			return nil, err
 		}
 		// flag already prints the error; don't print it again.
@@ -602,7 +844,7 @@ func newFixUpdateConfiguration(wd string, cmd command, args []string, cexts []co
 }
 
 func fixUpdateUsage(fs *flag.FlagSet) {
-	fmt.Fprint(os.Stderr, `usage: gazelle [fix|update] [flags...] [package-dirs...]
+	fmt.Fprint(os.Stderr, `usage: gazelle [fix|update] [flags...] [package-dirs or target patterns...]
 
 The update command creates new build files and update existing BUILD files
 when needed.
@@ -624,6 +866,13 @@ subdirectories. All directories must be under the directory specified by
 -repo_root; if -repo_root is not given, this is the directory containing the
 WORKSPACE file.
 
+Gazelle also accepts labels and target patterns in the main repository, like
+//foo/..., //foo:all, or //foo:bar. Packages matched by a pattern are updated,
+and only rules matched by a pattern are merged into their build files.
+Patterns prefixed with "-", like -//foo/bar/..., exclude targets matched by
+earlier patterns. Use -- before the first positional argument if it's a
+negative pattern.
+
 FLAGS:
 
 `)
@@ -662,6 +911,78 @@ func fixRepoFiles(c *config.Config, loads []rule.LoadInfo) error {
 	return nil
 }
 
+// isTargetPattern returns whether a positional argument to update or fix
+// is a Bazel label or target pattern like "//foo/..." or ":bar" rather than
+// a directory.
+func isTargetPattern(arg string) bool {
+	if strings.HasPrefix(arg, "//") || strings.HasPrefix(arg, "@") || strings.HasPrefix(arg, "-") {
+		return true
+	}
+	// Absolute paths may contain ":" on Windows.
+	return !filepath.IsAbs(arg) &&
+		(strings.Contains(arg, ":") || arg == "..." || strings.HasSuffix(arg, "/..."))
+}
+
+// parseUpdatePattern parses a target pattern given as a positional argument.
+// Relative patterns are relative to the working directory. The pattern must
+// name targets in the main repository; the result has no repository name.
+func parseUpdatePattern(c *config.Config, arg string) (label.Pattern, error) {
+	p, err := label.ParsePattern(arg)
+	if err != nil {
+		return label.Pattern{}, err
+	}
+	if p.Repo != "" && p.Repo != "@" && (p.Canonical || p.Repo != c.RepoName) {
+		return label.Pattern{}, fmt.Errorf("%s: only targets in the main repository may be updated", arg)
+	}
+	workRel, err := filepath.Rel(c.RepoRoot, c.WorkDir)
+	if err != nil {
+		return label.Pattern{}, err
+	}
+	if workRel = filepath.ToSlash(workRel); workRel == "." {
+		workRel = ""
+	}
+	p = p.Abs("", workRel)
+	p.Repo = ""
+	p.Canonical = false
+	return p, nil
+}
+
+// matchesPackage returns whether any targets in the package rel may be
+// matched by patterns. Packages excluded by a negative pattern matching all
+// their targets aren't matched, unless a later pattern adds targets back.
+func matchesPackage(patterns []label.Pattern, rel string) bool {
+	matched := false
+	for _, p := range patterns {
+		if !p.MatchesPackage(rel) {
+			continue
+		}
+		if !p.Negative {
+			matched = true
+		} else if p.Recursive || p.Name == "all" || p.Name == "*" || p.Name == "all-targets" {
+			matched = false
+		}
+	}
+	return matched
+}
+
+// filterRulesByPatterns returns the rules in the package rel matched by
+// patterns, along with their imports. If imports is nil, only rules are
+// filtered.
+func filterRulesByPatterns(patterns []label.Pattern, rel string, rules []*rule.Rule, imports []interface{}) ([]*rule.Rule, []interface{}) {
+	var filteredRules []*rule.Rule
+	var filteredImports []interface{}
+	for i, r := range rules {
+		if !label.MatchesPatterns(patterns, label.New("", rel, r.Name())) {
+			continue
+		}
+		filteredRules = append(filteredRules, r)
+		if imports != nil {
+			filteredImports = append(filteredImports, imports[i])
+		}
+	}
+	return filteredRules, filteredImports
+}
+
 func isDescendingDir(dir, root string) bool {
 	rel, err := filepath.Rel(root, dir)
 	if err != nil {
@@ -747,6 +1068,70 @@ func unionKindInfoMaps(a, b map[string]rule.KindInfo) map[string]rule.KindInfo {
 	return result
 }
 
+// applyDefaultAttrs sets attributes configured in c.DefaultAttrs on
+// generated rules that don't already have them. Attributes that should only
+// be set on new rules and attributes that should replace existing values are
+// marked for merger.MergeFile with private attributes.
+func applyDefaultAttrs(c *config.Config, gen []*rule.Rule) {
+	for _, r := range gen {
+		attrs := c.DefaultAttrs[r.Kind()]
+		if len(attrs) == 0 {
+			continue
+		}
+		createOnly := make(map[string]bool)
+		enforced := make(map[string]bool)
+		for key, attr := range attrs {
+			if r.Attr(key) != nil {
+				continue
+			}
+			// Copy the value for each rule, since merging may modify it.
+			expr := copyExpr(attr.Expr)
+			if expr == nil {
+				continue
+			}
+			r.SetAttr(key, expr)
+			if attr.Enforce {
+				enforced[key] = true
+			} else {
+				createOnly[key] = true
+			}
+		}
+		if len(createOnly) > 0 {
+			r.SetPrivateAttr(merger.CreateOnlyAttrsKey, createOnly)
+		}
+		if len(enforced) > 0 {
+			if mergeable, ok := r.PrivateAttr(merger.MergeableAttrsKey).(map[string]bool); ok {
+				for key := range mergeable {
+					enforced[key] = true
+				}
+			}
+			r.SetPrivateAttr(merger.MergeableAttrsKey, enforced)
+		}
+	}
+}
+
+// normalizeLabels writes labels in generated rules and in the rules of the
+// existing file f in the style configured with # gazelle:label_style, so
+// that labels written differently compare equal when the rules are merged.
+func normalizeLabels(c *config.Config, rel string, f *rule.File, gen []*rule.Rule, kinds map[string]rule.KindInfo) {
+	if c.LabelStyle == label.NoStyle {
+		return
+	}
+	for _, r := range gen {
+		r.NormalizeLabels(kinds[r.Kind()], c.RepoName, rel, c.LabelStyle)
+	}
+	if f == nil {
+		return
+	}
+	for _, r := range f.Rules {
+		info, ok := kinds[r.Kind()]
+		if !ok {
+			info = kinds[c.AliasMap[r.Kind()]]
+		}
+		r.NormalizeLabels(info, c.RepoName, rel, c.LabelStyle)
+	}
+}
+
 // applyKindMappings returns a copy of LoadInfo that includes c.KindMap.
 func applyKindMappings(mappedKinds []config.MappedKind, loads []rule.LoadInfo) []rule.LoadInfo {
 	if len(mappedKinds) == 0 {
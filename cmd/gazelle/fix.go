@@ -26,7 +26,7 @@ import (
 )
 
 func fixFile(c *config.Config, f *rule.File) error {
-	newContent := f.Format()
+	newContent := formatFile(c, f)
 	if bytes.Equal(f.Content, newContent) {
 		return nil
 	}
@@ -21,7 +21,9 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -29,9 +31,11 @@ import (
 	"testing"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy/goproxytest"
 	"github.com/bazelbuild/bazel-gazelle/internal/wspace"
 	"github.com/bazelbuild/bazel-gazelle/testtools"
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/mod/sumdb/dirhash"
 )
 
 // skipIfWorkspaceVisible skips the test if the WORKSPACE file for the
@@ -56,6 +60,8 @@ func TestHelp(t *testing.T) {
 		{"fix", "-h"},
 		{"update", "-h"},
 		{"update-repos", "-h"},
+		{"verify-repos", "-h"},
+		{"why-module", "-h"},
 	} {
 		t.Run(args[0], func(t *testing.T) {
 			if err := runGazelle(".", args); err == nil {
@@ -5114,3 +5120,1996 @@ go_library(
 		},
 	})
 }
+
+func TestResolveGoDepsFromModuleBazel(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `
+bazel_dep(name = "gazelle", version = "0.40.0")
+bazel_dep(name = "rules_go", version = "0.50.0")
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.from_file(go_mod = "//:go.mod")
+use_repo(go_deps, my_dep = "com_example_dep")
+`,
+		},
+		{
+			Path: "go.mod",
+			Content: `module example.com/use
+
+require (
+	example.com/dep v1.0.0
+	example.com/hidden v1.0.0
+)
+`,
+		},
+		{
+			Path: "use.go",
+			Content: `package use
+
+import (
+	_ "example.com/dep/pkg"
+	_ "example.com/hidden"
+)
+`,
+		},
+	})
+	defer cleanup()
+
+	args := []string{"update", "-repo_root", dir, "-go_prefix", "example.com/use"}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "BUILD.bazel",
+			Content: `
+load("@rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "use",
+    srcs = ["use.go"],
+    importpath = "example.com/use",
+    visibility = ["//visibility:public"],
+    deps = [
+        "@com_example_hidden//:hidden",
+        "@my_dep//pkg",
+    ],
+)
+`,
+		},
+	})
+}
+
+func TestUpdateReposToModule(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `
+bazel_dep(name = "gazelle", version = "0.40.0")
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+use_repo(go_deps, "com_example_stale", my_dep = "com_example_dep")
+`,
+		},
+		{
+			Path: "go.mod",
+			Content: `module example.com/use
+
+require (
+	example.com/dep v1.0.0
+	example.com/hidden v1.0.0
+	example.com/unused v1.0.0
+)
+`,
+		},
+		{
+			Path: "BUILD.bazel",
+			Content: `
+go_library(
+    name = "use",
+    deps = [
+        "@com_example_hidden//:hidden",
+        "@my_dep//pkg",
+    ],
+)
+`,
+		},
+	})
+	defer cleanup()
+
+	args := []string{"update-repos", "-to_module", "-from_file=go.mod", "-prune"}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `
+bazel_dep(name = "gazelle", version = "0.40.0")
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.from_file(go_mod = "//:go.mod")
+use_repo(go_deps, "com_example_hidden", my_dep = "com_example_dep")
+`,
+		},
+	})
+}
+
+func TestUpdateReposToModuleSkipsExcludedFiles(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `
+bazel_dep(name = "gazelle", version = "0.40.0")
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+use_repo(go_deps, "com_example_stale")
+`,
+		},
+		{
+			Path: "go.mod",
+			Content: `module example.com/use
+
+require (
+	example.com/dep v1.0.0
+	example.com/excluded v1.0.0
+	example.com/ignored v1.0.0
+	example.com/nested v1.0.0
+)
+`,
+		},
+		{
+			Path:    ".bazelignore",
+			Content: "ignored\n",
+		},
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:exclude excluded
+`,
+		},
+		{
+			Path:    "pkg/BUILD.bazel",
+			Content: `go_library(name = "pkg", deps = ["@com_example_dep//:dep"])`,
+		},
+		{
+			Path:    "excluded/BUILD.bazel",
+			Content: `go_library(name = "excluded", deps = ["@com_example_excluded//:excluded"])`,
+		},
+		{
+			Path:    "ignored/BUILD.bazel",
+			Content: `go_library(name = "ignored", deps = ["@com_example_ignored//:ignored"])`,
+		},
+		{
+			Path:    "nested/MODULE.bazel",
+			Content: `module(name = "nested")`,
+		},
+		{
+			Path:    "nested/BUILD.bazel",
+			Content: `go_library(name = "nested", deps = ["@com_example_nested//:nested"])`,
+		},
+		{
+			Path:    "testdata/BUILD.bazel",
+			Content: `not valid (`,
+		},
+	})
+	defer cleanup()
+
+	args := []string{"update-repos", "-to_module", "-from_file=go.mod", "-prune"}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `
+bazel_dep(name = "gazelle", version = "0.40.0")
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.from_file(go_mod = "//:go.mod")
+use_repo(go_deps, "com_example_dep")
+`,
+		},
+	})
+}
+
+func TestUpdateReposToModuleWithoutGoDeps(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path:    "MODULE.bazel",
+			Content: `bazel_dep(name = "gazelle", version = "0.40.0", repo_name = "bazel_gazelle")`,
+		},
+		{
+			Path: "go.mod",
+			Content: `module example.com/use
+
+require example.com/dep v1.0.0
+`,
+		},
+		{
+			Path:    "BUILD.bazel",
+			Content: `alias(name = "dep", actual = "@com_example_dep//:dep")`,
+		},
+	})
+	defer cleanup()
+
+	args := []string{"update-repos", "-to_module", "-from_file=go.mod"}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `bazel_dep(name = "gazelle", version = "0.40.0", repo_name = "bazel_gazelle")
+
+go_deps = use_extension("@bazel_gazelle//:extensions.bzl", "go_deps")
+go_deps.from_file(go_mod = "//:go.mod")
+use_repo(go_deps, "com_example_dep")
+`,
+		},
+	})
+}
+
+func TestMigrateBzlmod(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "WORKSPACE",
+			Content: `
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+# gazelle:repository_macro deps.bzl%go_dependencies
+
+go_repository(
+    name = "com_example_dep",
+    importpath = "example.com/dep",
+    sum = "h1:dep=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "custom_name",
+    build_file_proto_mode = "disable",
+    importpath = "example.com/custom",
+    patch_args = ["-p1"],
+    patches = ["//patches:custom.patch"],
+    sum = "h1:custom=",
+    version = "v1.2.0",
+)
+
+go_repository(
+    name = "com_example_replaced",
+    build_file_proto_mode = "disable",
+    importpath = "example.com/replaced",
+    replace = "example.com/fork",
+    sum = "h1:fork=",
+    version = "v1.0.0",
+)
+`,
+		},
+		{
+			Path: "deps.bzl",
+			Content: `
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+def go_dependencies():
+    go_repository(
+        name = "vcs",
+        commit = "0123456789abcdef",
+        importpath = "example.com/vcs",
+    )
+`,
+		},
+		{
+			Path:    "MODULE.bazel",
+			Content: `bazel_dep(name = "gazelle", version = "0.40.0")`,
+		},
+		{
+			Path: "BUILD.bazel",
+			Content: `go_library(
+    name = "lib",
+    deps = [
+        "@com_example_dep//:dep",
+        "@com_example_replaced//pkg",
+        "@custom_name//pkg",
+        "@vcs//pkg",
+    ],
+)
+`,
+		},
+	})
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"migrate-bzlmod"}); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "MODULE.bazel",
+			Content: `bazel_dep(name = "gazelle", version = "0.40.0")
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.module(
+    path = "example.com/dep",
+    sum = "h1:dep=",
+    version = "v1.0.0",
+)
+go_deps.module(
+    path = "example.com/custom",
+    sum = "h1:custom=",
+    version = "v1.2.0",
+)
+go_deps.gazelle_override(
+    directives = ["gazelle:proto disable"],
+    path = "example.com/custom",
+)
+go_deps.module_override(
+    patch_strip = 1,
+    patches = ["//patches:custom.patch"],
+    path = "example.com/custom",
+)
+use_repo(go_deps, "com_example_custom", "com_example_dep")
+`,
+		},
+		{
+			Path: "BUILD.bazel",
+			Content: `go_library(
+    name = "lib",
+    deps = [
+        "@com_example_custom//pkg",
+        "@com_example_dep//:dep",
+        "@com_example_replaced//pkg",
+        "@vcs//pkg",
+    ],
+)
+`,
+		},
+	})
+}
+
+func TestMigrateBzlmodMissingModuleFile(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "WORKSPACE",
+			Content: `
+go_repository(
+    name = "com_example_dep",
+    importpath = "example.com/dep",
+    sum = "h1:dep=",
+    version = "v1.0.0",
+)
+`,
+		},
+	})
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"migrate-bzlmod"}); err == nil || !strings.Contains(err.Error(), "MODULE.bazel: file does not exist") {
+		t.Errorf("got error %v; want MODULE.bazel does not exist", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "MODULE.bazel")); !os.IsNotExist(err) {
+		t.Errorf("MODULE.bazel was created: %v", err)
+	}
+}
+
+func TestVerifyRepos(t *testing.T) {
+	modCache := t.TempDir()
+	goSum := "example.com/a v1.0.0 h1:a=\nexample.com/b v1.1.0 h1:b=\n"
+	for _, m := range []struct{ path, version string }{
+		{"example.com/a", "v1.0.0"},
+		{"example.com/b", "v1.1.0"},
+	} {
+		content := "module " + m.path + "\n\ngo 1.21\n"
+		p := filepath.Join(modCache, "cache", "download", filepath.FromSlash(m.path), "@v", m.version+".mod")
+		if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(content)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		goSum += m.path + " " + m.version + "/go.mod " + sum + "\n"
+	}
+	t.Setenv("GOMODCACHE", modCache)
+	t.Setenv("GOPROXY", "off")
+
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "go.mod",
+			Content: `module example.com/main
+
+go 1.21
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.1.0
+)
+`,
+		},
+		{Path: "go.sum", Content: goSum},
+		{
+			Path: "WORKSPACE",
+			Content: `
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+go_repository(
+    name = "com_example_a",
+    importpath = "example.com/a",
+    sum = "h1:old=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "com_example_b",
+    importpath = "example.com/b",
+    sum = "h1:b=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "com_example_c",
+    importpath = "example.com/c",
+    sum = "h1:c=",
+    version = "v1.0.0",
+)
+`,
+		},
+		{
+			Path: "MODULE.bazel",
+			Content: `
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.module(
+    path = "example.com/b",
+    sum = "h1:b=",
+    version = "v1.1.0",
+)
+`,
+		},
+	})
+	defer cleanup()
+
+	verify := func(args ...string) (string, error) {
+		stdout := os.Stdout
+		f, err := os.CreateTemp(t.TempDir(), "stdout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		os.Stdout = f
+		err = runGazelle(dir, append([]string{"verify-repos", "-offline"}, args...))
+		os.Stdout = stdout
+		out, readErr := os.ReadFile(f.Name())
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		return string(out), err
+	}
+
+	out, err := verify()
+	if err != errExit {
+		t.Errorf("got error %v; want errExit", err)
+	}
+	want := `WORKSPACE: example.com/a (com_example_a): sum h1:old=; want h1:a=
+WORKSPACE: example.com/b (com_example_b): version v1.0.0; want v1.1.0
+WORKSPACE: example.com/c (com_example_c): not in the module graph
+MODULE.bazel: example.com/a: missing; want version v1.0.0
+`
+	if out != want {
+		t.Errorf("got output:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = verify("-format=json")
+	if err != errExit {
+		t.Errorf("got error %v; want errExit", err)
+	}
+	var problems []map[string]string
+	if err := json.Unmarshal([]byte(out), &problems); err != nil {
+		t.Fatalf("parsing output: %v\n%s", err, out)
+	}
+	wantProblems := []map[string]string{
+		{"kind": "sum_mismatch", "source": "WORKSPACE", "module": "example.com/a", "repo": "com_example_a", "want_version": "v1.0.0", "got_version": "v1.0.0", "want_sum": "h1:a=", "got_sum": "h1:old="},
+		{"kind": "version_mismatch", "source": "WORKSPACE", "module": "example.com/b", "repo": "com_example_b", "want_version": "v1.1.0", "got_version": "v1.0.0"},
+		{"kind": "extra", "source": "WORKSPACE", "module": "example.com/c", "repo": "com_example_c"},
+		{"kind": "missing", "source": "MODULE.bazel", "module": "example.com/a", "want_version": "v1.0.0", "want_sum": "h1:a="},
+	}
+	if diff := cmp.Diff(wantProblems, problems); diff != "" {
+		t.Errorf("JSON output (-want +got):\n%s", diff)
+	}
+}
+
+func TestUpdateReposUpgrade(t *testing.T) {
+	proxyFiles := []testtools.FileSpec{
+		{Path: "example.com/a/@v/list", Content: "v1.0.0\nv1.0.1\nv1.1.0\nv1.2.0-pre\nv2.0.0+incompatible\n"},
+		{Path: "example.com/a/@v/v1.0.1.info", Content: `{"Version":"v1.0.1"}`},
+		{Path: "example.com/a/@v/v1.0.1.mod", Content: "module example.com/a\n"},
+		{Path: "example.com/a/@v/v1.1.0.info", Content: `{"Version":"v1.1.0"}`},
+		{Path: "example.com/a/@v/v1.1.0.mod", Content: "module example.com/a\n\nrequire (\n\texample.com/b v1.0.3\n\texample.com/c v1.0.0\n\texample.com/d v1.0.0\n)\n"},
+		{Path: "example.com/b/@v/list", Content: "v1.0.0\nv1.0.3\nv1.0.5\nv1.1.0\n"},
+		{Path: "example.com/b/@v/v1.0.3.info", Content: `{"Version":"v1.0.3"}`},
+		{Path: "example.com/b/@v/v1.0.3.mod", Content: "module example.com/b\n"},
+		{Path: "example.com/b/@v/v1.0.5.info", Content: `{"Version":"v1.0.5"}`},
+		{Path: "example.com/b/@v/v1.0.5.mod", Content: "module example.com/b\n"},
+		{Path: "example.com/d/@v/v1.0.0.info", Content: `{"Version":"v1.0.0"}`},
+		{Path: "example.com/d/@v/v1.0.0.mod", Content: "module example.com/d\n"},
+	}
+	proxyDir, proxyCleanup := testtools.CreateFiles(t, proxyFiles)
+	defer proxyCleanup()
+	t.Setenv("GOPROXY", "file://"+filepath.ToSlash(proxyDir))
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GOSUMDB", goproxytest.ServeSumDB(t, map[string]string{
+		"example.com/a@v1.0.1": "h1:a101=",
+		"example.com/a@v1.1.0": "h1:a110=",
+		"example.com/b@v1.0.3": "h1:b103=",
+		"example.com/b@v1.0.5": "h1:b105=",
+		"example.com/d@v1.0.0": "h1:d100=",
+	}))
+
+	const workspace = `
+# gazelle:repo bazel_gazelle
+
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+go_repository(
+    name = "com_example_a",
+    importpath = "example.com/a",
+    sum = "h1:a100=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "com_example_b",
+    importpath = "example.com/b",
+    sum = "h1:b100=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "com_example_c",
+    commit = "0123456789abcdef0123456789abcdef01234567",
+    importpath = "example.com/c",
+)
+`
+
+	for _, tc := range []struct {
+		desc          string
+		args          []string
+		wantOut       string
+		wantWorkspace string
+	}{
+		{
+			desc: "minor_only",
+			args: []string{"-upgrade=minor", "-only=example.com/a"},
+			wantOut: `MODULE         BEFORE  AFTER   NOTE
+example.com/a  v1.0.0  v1.1.0
+example.com/b  v1.0.0  v1.0.3  required by example.com/a@v1.1.0
+example.com/d  -       v1.0.0  required by example.com/a@v1.1.0
+`,
+			wantWorkspace: `
+# gazelle:repo bazel_gazelle
+
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+go_repository(
+    name = "com_example_a",
+    importpath = "example.com/a",
+    sum = "h1:a110=",
+    version = "v1.1.0",
+)
+
+go_repository(
+    name = "com_example_b",
+    importpath = "example.com/b",
+    sum = "h1:b103=",
+    version = "v1.0.3",
+)
+
+go_repository(
+    name = "com_example_c",
+    commit = "0123456789abcdef0123456789abcdef01234567",
+    importpath = "example.com/c",
+)
+
+go_repository(
+    name = "com_example_d",
+    importpath = "example.com/d",
+    sum = "h1:d100=",
+    version = "v1.0.0",
+)
+`,
+		}, {
+			desc: "patch",
+			args: []string{"-upgrade=patch"},
+			wantOut: `MODULE         BEFORE  AFTER   NOTE
+example.com/a  v1.0.0  v1.0.1
+example.com/b  v1.0.0  v1.0.5
+`,
+			wantWorkspace: `
+# gazelle:repo bazel_gazelle
+
+load("@bazel_gazelle//:deps.bzl", "go_repository")
+
+go_repository(
+    name = "com_example_a",
+    importpath = "example.com/a",
+    sum = "h1:a101=",
+    version = "v1.0.1",
+)
+
+go_repository(
+    name = "com_example_b",
+    importpath = "example.com/b",
+    sum = "h1:b105=",
+    version = "v1.0.5",
+)
+
+go_repository(
+    name = "com_example_c",
+    commit = "0123456789abcdef0123456789abcdef01234567",
+    importpath = "example.com/c",
+)
+`,
+		}, {
+			desc:          "up_to_date",
+			args:          []string{"-upgrade=latest", "-only=example.com/c"},
+			wantOut:       "all modules are up to date\n",
+			wantWorkspace: workspace,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{{Path: "WORKSPACE", Content: workspace}})
+			defer cleanup()
+
+			stdout := os.Stdout
+			f, err := os.CreateTemp(t.TempDir(), "stdout")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			os.Stdout = f
+			err = runGazelle(dir, append([]string{"update-repos"}, tc.args...))
+			os.Stdout = stdout
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := os.ReadFile(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tc.wantOut {
+				t.Errorf("got output:\n%s\nwant:\n%s", out, tc.wantOut)
+			}
+			testtools.CheckFiles(t, dir, []testtools.FileSpec{{Path: "WORKSPACE", Content: tc.wantWorkspace}})
+		})
+	}
+}
+
+func TestWhyModule(t *testing.T) {
+	files := []testtools.FileSpec{
+		{
+			Path: "WORKSPACE",
+			Content: `
+go_repository(
+    name = "com_example_foo",
+    importpath = "example.com/foo",
+    sum = "h1:foo=",
+    version = "v1.0.0",
+)
+`,
+		},
+		{Path: "BUILD.bazel", Content: "# gazelle:prefix example.com/main\n"},
+		{
+			Path:    "cmd/x/main.go",
+			Content: "package main\n\nimport _ \"example.com/main/pkg/a\"\n\nfunc main() {}\n",
+		},
+		{
+			Path:    "pkg/a/a.go",
+			Content: "package a\n\nimport _ \"example.com/foo/bar\"\n",
+		},
+		{
+			Path:    "pkg/a/a_test.go",
+			Content: "package a\n\nimport _ \"example.com/foo\"\n",
+		},
+		{
+			Path:    "pkg/b/b.go",
+			Content: "package b\n",
+		},
+		{
+			Path:    "pkg/b/b_test.go",
+			Content: "package b\n\nimport _ \"example.com/main/pkg/a\"\n",
+		},
+		{
+			Path:    "pkg/c/c.go",
+			Content: "package c\n",
+		},
+		{
+			Path: "pkg/testutil/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "testutil",
+    srcs = ["testutil.go"],
+    importpath = "example.com/main/pkg/testutil",
+    testonly = True,
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path:    "pkg/testutil/testutil.go",
+			Content: "package testutil\n\nimport _ \"example.com/foo/bar\"\n",
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	whyModule := func(arg string) string {
+		t.Helper()
+		stdout := os.Stdout
+		f, err := os.CreateTemp(t.TempDir(), "stdout")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		os.Stdout = f
+		err = runGazelle(filepath.Join(dir, "pkg", "c"), []string{"why-module", arg})
+		os.Stdout = stdout
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	want := `packages importing example.com/foo (@com_example_foo):
+	//pkg/a [example.com/main/pkg/a] imports @com_example_foo//bar
+	//pkg/a:a_test imports @com_example_foo//:foo (test only)
+	//pkg/testutil [example.com/main/pkg/testutil] imports @com_example_foo//bar (test only)
+
+shortest paths:
+	//cmd/x -> //cmd/x:x_lib -> //pkg/a -> @com_example_foo//bar
+	//cmd/x:x_lib -> //pkg/a -> @com_example_foo//bar
+	//pkg/a -> @com_example_foo//bar
+	//pkg/a:a_test -> @com_example_foo//:foo (test only)
+	//pkg/b:b_test -> //pkg/a -> @com_example_foo//bar (test only)
+	//pkg/testutil -> @com_example_foo//bar (test only)
+`
+	for _, arg := range []string{"example.com/foo", "@com_example_foo"} {
+		if got := whyModule(arg); got != want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", arg, got, want)
+		}
+	}
+	if got, want := whyModule("example.com/other"), "no packages import example.com/other (@com_example_other)\n"; got != want {
+		t.Errorf("got %q; want %q", got, want)
+	}
+
+	// No files are written.
+	if _, err := os.Stat(filepath.Join(dir, "pkg", "a", "BUILD.bazel")); !os.IsNotExist(err) {
+		t.Errorf("why-module wrote a build file: %v", err)
+	}
+}
+
+func TestGoToolAliases(t *testing.T) {
+	files := []testtools.FileSpec{
+		{
+			Path: "WORKSPACE",
+			Content: `
+go_repository(
+    name = "org_golang_x_tools",
+    importpath = "golang.org/x/tools",
+    sum = "h1:tools=",
+    version = "v0.30.0",
+)
+`,
+		},
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:prefix example.com/main
+# gazelle:go_tool_aliases tools
+`,
+		},
+		{
+			Path: "go.mod",
+			Content: `
+module example.com/main
+
+go 1.24
+
+require golang.org/x/tools v0.30.0
+
+tool (
+	example.com/main/cmd/gen
+	golang.org/x/tools/cmd/stringer
+)
+`,
+		},
+		{
+			Path:    "cmd/gen/main.go",
+			Content: "package main\n\nfunc main() {}\n",
+		},
+		{
+			Path: "tools/BUILD.bazel",
+			Content: `
+# Alias for the go.mod tool golang.org/x/tools/cmd/goyacc.
+alias(
+    name = "goyacc",
+    actual = "@org_golang_x_tools//cmd/goyacc",
+)
+
+alias(
+    name = "goimports",
+    actual = "@org_golang_x_tools//cmd/goimports",
+)
+
+alias(
+    name = "other",
+    actual = "//other:gen",
+)
+`,
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"-go_naming_convention=import"}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "tools/BUILD.bazel",
+			Content: `
+alias(
+    name = "goimports",
+    actual = "@org_golang_x_tools//cmd/goimports",
+)
+
+alias(
+    name = "other",
+    actual = "//other:gen",
+)
+
+# Alias for the go.mod tool example.com/main/cmd/gen.
+alias(
+    name = "gen",
+    actual = "//cmd/gen",
+)
+
+# Alias for the go.mod tool golang.org/x/tools/cmd/stringer.
+alias(
+    name = "stringer",
+    actual = "@org_golang_x_tools//cmd/stringer",
+)
+`,
+		},
+	})
+
+	// Aliases generated for tools removed from go.mod are deleted. Hand-written
+	// aliases are kept, even if they look like tool aliases.
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/main\n\ngo 1.24\n\ntool example.com/main/cmd/gen\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, []string{"-go_naming_convention=import"}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "tools/BUILD.bazel",
+			Content: `
+alias(
+    name = "goimports",
+    actual = "@org_golang_x_tools//cmd/goimports",
+)
+
+alias(
+    name = "other",
+    actual = "//other:gen",
+)
+
+# Alias for the go.mod tool example.com/main/cmd/gen.
+alias(
+    name = "gen",
+    actual = "//cmd/gen",
+)
+`,
+		},
+	})
+}
+
+func TestGoWorkUpdate(t *testing.T) {
+	files := []testtools.FileSpec{
+		{
+			Path: "WORKSPACE",
+			Content: `
+go_repository(
+    name = "com_example_x",
+    importpath = "example.com/x",
+    sum = "h1:x=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "com_example_x_y",
+    importpath = "example.com/x/y",
+    sum = "h1:y=",
+    version = "v1.0.0",
+)
+`,
+		},
+		{Path: "BUILD.bazel"},
+		{
+			Path:    "go.work",
+			Content: "go 1.22\n\nuse (\n\t./a\n\t./b\n)\n",
+		},
+		{
+			Path:    "a/go.mod",
+			Content: "module example.com/a\n\ngo 1.22\n\nrequire example.com/x v1.0.0\n",
+		},
+		{
+			Path: "a/a.go",
+			Content: `package a
+
+import (
+	_ "example.com/b/lib"
+	_ "example.com/x/y/z"
+)
+`,
+		},
+		{
+			Path:    "b/go.mod",
+			Content: "module example.com/b\n\ngo 1.22\n\nrequire example.com/x/y v1.0.0\n",
+		},
+		{
+			Path: "b/lib/lib.go",
+			Content: `package lib
+
+import _ "example.com/x/y/z"
+`,
+		},
+	}
+	want := []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/a",
+    visibility = ["//visibility:public"],
+    deps = [
+        "//b/lib",
+        "@com_example_x//y/z",
+    ],
+)
+`,
+		},
+		{
+			Path: "b/lib/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/b/lib",
+    visibility = ["//visibility:public"],
+    deps = ["@com_example_x_y//z"],
+)
+`,
+		},
+	}
+
+	for _, index := range []string{"all", "lazy", "none"} {
+		t.Run(index, func(t *testing.T) {
+			dir, cleanup := testtools.CreateFiles(t, files)
+			defer cleanup()
+			args := []string{"-go_naming_convention=import", "-index=" + index}
+			if index == "lazy" {
+				// Only the directory containing a is visited. b/lib is found
+				// through the go.work file.
+				args = append(args, "-r=false", "a")
+			}
+			if err := runGazelle(dir, args); err != nil {
+				t.Fatal(err)
+			}
+			if index == "lazy" {
+				testtools.CheckFiles(t, dir, want[:1])
+			} else {
+				testtools.CheckFiles(t, dir, want)
+			}
+		})
+	}
+}
+
+func TestVendorModulesTxt(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:prefix example.com/main
+# gazelle:go_naming_convention import
+`,
+		},
+		{Path: "go.mod", Content: "module example.com/main\n\ngo 1.22\n"},
+		// The nested module in sub has its own vendor directory. It's found
+		// through go.work.
+		{Path: "go.work", Content: "go 1.22\n\nuse (\n\t.\n\t./sub\n)\n"},
+		{
+			Path: "main.go",
+			Content: `package main
+
+import (
+	_ "example.com/x/a"
+	_ "example.com/x/y/b"
+	_ "example.com/x/unlisted"
+)
+
+func main() {}
+`,
+		},
+		{
+			Path: "vendor/modules.txt",
+			Content: `# example.com/x v1.0.0
+## explicit; go 1.22
+example.com/x/a
+# example.com/x/y v1.1.0 => example.com/yfork v1.2.0
+## explicit; go 1.22
+example.com/x/y/b
+example.com/x/y/vendor/c
+# example.com/z => ./z
+`,
+		},
+		{Path: "vendor/example.com/x/a/a.go", Content: "package a\n"},
+		{Path: "vendor/example.com/x/extra/extra.go", Content: "package extra\n"},
+		{Path: "vendor/example.com/x/y/b/b.go", Content: "package b\n\nimport _ \"example.com/x/y/vendor/c\"\n"},
+		{Path: "vendor/example.com/x/y/vendor/c/c.go", Content: "package c\n"},
+		{Path: "sub/BUILD.bazel", Content: "# gazelle:prefix example.com/sub\n"},
+		{Path: "sub/go.mod", Content: "module example.com/sub\n\ngo 1.22\n"},
+		{Path: "sub/sub.go", Content: "package sub\n\nimport _ \"example.com/x/a\"\n"},
+		{Path: "sub/vendor/modules.txt", Content: "# example.com/x v1.0.0\n## explicit\nexample.com/x/a\n"},
+		{Path: "sub/vendor/example.com/x/a/a.go", Content: "package a\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"-external=vendored", "-index=none"}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library")
+
+# gazelle:prefix example.com/main
+# gazelle:go_naming_convention import
+
+go_library(
+    name = "main_lib",
+    srcs = ["main.go"],
+    importpath = "example.com/main",
+    visibility = ["//visibility:private"],
+    deps = [
+        "//vendor/example.com/x/a",
+        "//vendor/example.com/x/y/b",
+    ],
+)
+
+go_binary(
+    name = "main",
+    embed = [":main_lib"],
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path: "vendor/example.com/x/y/b/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importmap = "example.com/main/vendor/example.com/x/y/b",
+    importpath = "example.com/x/y/b",
+    visibility = ["//visibility:public"],
+    deps = ["//vendor/example.com/x/y/vendor/c"],
+)
+`,
+		},
+		{
+			Path: "vendor/example.com/x/y/vendor/c/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "c",
+    srcs = ["c.go"],
+    importmap = "example.com/main/vendor/example.com/x/y/vendor/c",
+    importpath = "example.com/x/y/vendor/c",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path: "sub/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:prefix example.com/sub
+
+go_library(
+    name = "sub",
+    srcs = ["sub.go"],
+    importpath = "example.com/sub",
+    visibility = ["//visibility:public"],
+    deps = ["//sub/vendor/example.com/x/a"],
+)
+`,
+		},
+		{
+			Path: "sub/vendor/example.com/x/a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importmap = "example.com/sub/vendor/example.com/x/a",
+    importpath = "example.com/x/a",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+	})
+}
+
+func TestMinimalEdits(t *testing.T) {
+	const handWritten = `# gazelle:prefix example.com/repo
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+load('//build:defs.bzl', 'my_macro')
+
+my_macro(name='hand',   srcs = [ 'data.txt' ])  # odd spacing
+
+go_library(
+    name = "repo",
+    srcs = ["a.go"],
+    importpath = "example.com/repo",
+    visibility = ["//visibility:public"],
+)
+`
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: handWritten},
+		{Path: "a.go", Content: "package repo\n"},
+		{Path: "b.go", Content: "package repo\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, []string{"-minimal_edits"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "BUILD.bazel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(handWritten, `srcs = ["a.go"],`, `srcs = [
+        "a.go",
+        "b.go",
+    ],`, 1)
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeState(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: "# gazelle:prefix example.com/repo\n"},
+		{Path: "a.go", Content: "package repo\n\nimport _ \"example.com/repo/x\"\n"},
+		{Path: "x/x.go", Content: "package x\n"},
+		{Path: "y/y.go", Content: "package y\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	args := []string{"-merge_state=.gazelle_state.json"}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gazelle_state.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a dependency by hand, then import another package. Both deps are kept
+	// without a "# keep" comment.
+	buildPath := filepath.Join(dir, "BUILD.bazel")
+	data, err := os.ReadFile(buildPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(data), `deps = ["//x"],`, `deps = [
+        "//extra",
+        "//x",
+    ],`, 1)
+	if edited == string(data) {
+		t.Fatalf("unexpected build file:\n%s", data)
+	}
+	if err := os.WriteFile(buildPath, []byte(edited), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte("package repo\n\nimport (\n\t_ \"example.com/repo/x\"\n\t_ \"example.com/repo/y\"\n)\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{{
+		Path: "BUILD.bazel",
+		Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+# gazelle:prefix example.com/repo
+
+go_library(
+    name = "repo",
+    srcs = ["a.go"],
+    importpath = "example.com/repo",
+    visibility = ["//visibility:public"],
+    deps = [
+        "//extra",
+        "//x",
+        "//y",
+    ],
+)
+`,
+	}})
+}
+
+func TestMergeStateWithTargetPattern(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: "# gazelle:prefix example.com/repo\n"},
+		{Path: "a/a.go", Content: "package a\n"},
+		{Path: "a/a_test.go", Content: "package a\n\nimport _ \"example.com/repo/x\"\n"},
+		{Path: "x/x.go", Content: "package x\n"},
+		{Path: "y/y.go", Content: "package y\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	args := []string{"-merge_state=.gazelle_state.json"}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a dependency to the test by hand, then update only the library.
+	// Values recorded for the test must be kept.
+	buildPath := filepath.Join(dir, "a", "BUILD.bazel")
+	data, err := os.ReadFile(buildPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(data), `deps = ["//x"],`, `deps = [
+        "//extra",
+        "//x",
+    ],`, 1)
+	if edited == string(data) {
+		t.Fatalf("unexpected build file:\n%s", data)
+	}
+	if err := os.WriteFile(buildPath, []byte(edited), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, append(args, "//a:a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Import another package in the test and update everything. The hand
+	// edit is still merged three ways.
+	if err := os.WriteFile(filepath.Join(dir, "a", "a_test.go"), []byte("package a\n\nimport (\n\t_ \"example.com/repo/x\"\n\t_ \"example.com/repo/y\"\n)\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := runGazelle(dir, args); err != nil {
+		t.Fatal(err)
+	}
+
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{{
+		Path: "a/BUILD.bazel",
+		Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "a_test",
+    srcs = ["a_test.go"],
+    embed = [":a"],
+    deps = [
+        "//extra",
+        "//x",
+        "//y",
+    ],
+)
+`,
+	}})
+}
+
+func TestRenameLabels(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: "# gazelle:prefix example.com/repo\n"},
+		{Path: "a/a.go", Content: "package a\n"},
+		{Path: "a/a_test.go", Content: "package a\n"},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+
+# Tests for a.
+go_test(
+    name = "unit_tests",
+    srcs = ["a_test.go"],
+    embed = [":a"],
+)
+
+test_suite(
+    name = "all",
+    tests = [":unit_tests"],
+)
+`,
+		},
+		{
+			Path: "b/BUILD.bazel",
+			Content: `
+test_suite(
+    name = "all",
+    tests = ["//a:unit_tests"],
+)
+`,
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	// Only a is updated, but labels are renamed in every build file.
+	if err := runGazelle(dir, []string{"-rename_labels", "-index=none", "//a:all"}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+
+# Tests for a.
+go_test(
+    name = "a_test",
+    srcs = ["a_test.go"],
+    embed = [":a"],
+)
+
+test_suite(
+    name = "all",
+    tests = [":a_test"],
+)
+`,
+		},
+		{
+			Path: "b/BUILD.bazel",
+			Content: `
+test_suite(
+    name = "all",
+    tests = ["//a:a_test"],
+)
+`,
+		},
+	})
+}
+
+func TestMovePackageSources(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel"},
+		{Path: "foo/a.txt"},
+		{Path: "foo/bar/b.txt"},
+		{Path: "foo/bar/c.txt"},
+		{Path: "old/x.txt"},
+		{
+			Path: "foo/BUILD.bazel",
+			Content: `
+load("//build:defs.bzl", "my_lib")
+
+filegroup(
+    name = "data",
+    srcs = ["bar/b.txt"],
+    visibility = ["//visibility:public"],  # keep
+)
+
+my_lib(
+    name = "lib",
+    srcs = [
+        "a.txt",
+        "bar/c.txt",  # keep
+    ],
+    tags = ["manual"],
+    deps = [":data"],
+)
+`,
+		},
+		{Path: "foo/bar/BUILD.bazel"},
+		{
+			Path: "baz/BUILD.bazel",
+			Content: `
+filegroup(
+    name = "all",
+    srcs = [
+        "//foo:data",
+        "//foo:lib",
+        "//old:x.txt",
+    ],
+)
+`,
+		},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	// Labels are updated in baz, even though only foo and foo/bar are.
+	if err := runGazelle(dir, []string{"-r=false", "foo", "foo/bar"}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "foo/BUILD.bazel",
+			Content: `
+load("//build:defs.bzl", "my_lib")
+
+my_lib(
+    name = "lib",
+    srcs = ["a.txt"],
+    tags = ["manual"],
+    deps = ["//foo/bar:data"],
+)
+`,
+		},
+		{
+			Path: "foo/bar/BUILD.bazel",
+			Content: `
+load("//build:defs.bzl", "my_lib")
+
+filegroup(
+    name = "data",
+    srcs = ["b.txt"],
+    visibility = ["//visibility:public"],  # keep
+)
+
+my_lib(
+    name = "lib",
+    srcs = [
+        "c.txt",  # keep
+    ],
+    tags = ["manual"],
+    deps = [":data"],
+)
+`,
+		},
+		{
+			Path: "baz/BUILD.bazel",
+			Content: `
+filegroup(
+    name = "all",
+    srcs = [
+        "//:old/x.txt",
+        "//foo:lib",
+        "//foo/bar:data",
+        "//foo/bar:lib",
+    ],
+)
+`,
+		},
+	})
+}
+
+func TestMovePackageSourcesGoLibrary(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: "# gazelle:prefix example.com/repo\n"},
+		{Path: "foo/foo.go", Content: "package foo\n"},
+		{Path: "foo/bar/bar.go", Content: "package bar\n"},
+		{Path: "foo/bar/data.txt"},
+		{
+			Path: "foo/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+filegroup(
+    name = "data",
+    srcs = glob(["**/*.txt"]),
+)
+
+go_library(
+    name = "foo",
+    srcs = [
+        "bar/bar.go",  # keep
+        "foo.go",
+    ],
+    importpath = "example.com/repo/foo",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{Path: "foo/bar/BUILD.bazel"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	if err := runGazelle(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "filegroup(data) lists sources with glob, which doesn't match files in the new package foo/bar"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("log does not contain %q\n--begin--\n%s--end--\n", want, buf.String())
+	}
+
+	// The copy of foo in foo/bar doesn't have foo's importpath, so the
+	// index doesn't have two libraries with the same import path.
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "foo/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+filegroup(
+    name = "data",
+    srcs = glob(["**/*.txt"]),
+)
+
+go_library(
+    name = "foo",
+    srcs = [
+        "foo.go",
+    ],
+    importpath = "example.com/repo/foo",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path: "foo/bar/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "bar",
+    srcs = ["bar.go"],
+    importpath = "example.com/repo/foo/bar",
+    visibility = ["//visibility:public"],
+)
+
+go_library(
+    name = "foo",
+    srcs = [
+        "bar.go",  # keep
+    ],
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+	})
+}
+
+func TestKindInfoDirective(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:prefix example.com/repo
+# gazelle:alias_kind my_go_library go_library
+`,
+		},
+		{
+			Path: "gone/BUILD.bazel",
+			Content: `
+# gazelle:kind_info my_go_library nonempty=srcs,deps,embed
+load("//build:defs.bzl", "my_go_library")
+
+my_go_library(
+    name = "gone",
+    srcs = ["gone.go"],
+    importpath = "example.com/repo/gone",
+)
+`,
+		},
+		{
+			Path: "extra/BUILD.bazel",
+			Content: `
+# gazelle:kind_info my_go_library mergeable=extra_srcs
+load("//build:defs.bzl", "my_go_library")
+
+my_go_library(
+    name = "extra",
+    srcs = ["extra.go"],
+    extra_srcs = ["stale.go"],
+    importpath = "example.com/repo/extra",
+)
+`,
+		},
+		{Path: "extra/extra.go", Content: "package extra\n"},
+		{
+			Path: "undeclared/BUILD.bazel",
+			Content: `
+load("//build:defs.bzl", "my_go_library")
+
+my_go_library(
+    name = "undeclared",
+    srcs = ["undeclared.go"],
+    extra_srcs = ["stale.go"],
+    importpath = "example.com/repo/undeclared",
+)
+`,
+		},
+		{Path: "undeclared/undeclared.go", Content: "package undeclared\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "gone/BUILD.bazel",
+			Content: `
+# gazelle:kind_info my_go_library nonempty=srcs,deps,embed
+load("//build:defs.bzl", "my_go_library")
+`,
+		},
+		{
+			Path: "extra/BUILD.bazel",
+			Content: `
+# gazelle:kind_info my_go_library mergeable=extra_srcs
+load("//build:defs.bzl", "my_go_library")
+
+my_go_library(
+    name = "extra",
+    srcs = ["extra.go"],
+    importpath = "example.com/repo/extra",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{
+			Path: "undeclared/BUILD.bazel",
+			Content: `
+load("//build:defs.bzl", "my_go_library")
+
+my_go_library(
+    name = "undeclared",
+    srcs = ["undeclared.go"],
+    extra_srcs = ["stale.go"],
+    importpath = "example.com/repo/undeclared",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+	})
+}
+
+func TestDefaultAttrDirectives(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:prefix example.com/repo
+# gazelle:default_attr go_test size "small"
+# gazelle:enforce_attr go_binary pure "on"
+`,
+		},
+		{Path: "created/created_test.go", Content: "package main\n"},
+		{Path: "created/main.go", Content: "package main\n\nfunc main() {}\n"},
+		{
+			Path: "existing/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library", "go_test")
+
+go_library(
+    name = "existing_lib",
+    srcs = ["main.go"],
+    importpath = "example.com/repo/existing",
+    visibility = ["//visibility:private"],
+)
+
+go_binary(
+    name = "existing",
+    embed = [":existing_lib"],
+    pure = "off",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "existing_test",
+    srcs = ["existing_test.go"],
+)
+`,
+		},
+		{Path: "existing/existing_test.go", Content: "package main\n"},
+		{Path: "existing/main.go", Content: "package main\n\nfunc main() {}\n"},
+		{
+			Path:    "cleared/BUILD.bazel",
+			Content: "# gazelle:default_attr go_test size\n",
+		},
+		{Path: "cleared/cleared_test.go", Content: "package cleared\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "created/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library", "go_test")
+
+go_library(
+    name = "created_lib",
+    srcs = ["main.go"],
+    importpath = "example.com/repo/created",
+    visibility = ["//visibility:private"],
+)
+
+go_binary(
+    name = "created",
+    embed = [":created_lib"],
+    pure = "on",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "created_test",
+    size = "small",
+    srcs = ["created_test.go"],
+    embed = [":created_lib"],
+)
+`,
+		},
+		{
+			Path: "existing/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_binary", "go_library", "go_test")
+
+go_library(
+    name = "existing_lib",
+    srcs = ["main.go"],
+    importpath = "example.com/repo/existing",
+    visibility = ["//visibility:private"],
+)
+
+go_binary(
+    name = "existing",
+    embed = [":existing_lib"],
+    pure = "on",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "existing_test",
+    srcs = ["existing_test.go"],
+    embed = [":existing_lib"],
+)
+`,
+		},
+		{
+			Path: "cleared/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+# gazelle:default_attr go_test size
+
+go_test(
+    name = "cleared_test",
+    srcs = ["cleared_test.go"],
+)
+`,
+		},
+	})
+}
+
+func TestLabelStyle(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE", Content: `workspace(name = "repo")`},
+		{
+			Path: "BUILD.bazel",
+			Content: `
+# gazelle:prefix example.com/repo
+# gazelle:label_style relative
+`,
+		},
+		{Path: "lib/lib.go", Content: "package lib\n"},
+		{
+			Path: "app/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "app",
+    srcs = ["app.go"],
+    importpath = "example.com/repo/app",
+    visibility = ["//visibility:public"],
+    deps = [
+        "@repo//lib:lib",  # for Lib
+    ],
+)
+
+go_test(
+    name = "app_test",
+    srcs = ["app_test.go"],
+    embed = ["//app:app"],
+)
+`,
+		},
+		{
+			Path: "app/app.go",
+			Content: `package app
+
+import "example.com/repo/lib"
+`,
+		},
+		{Path: "app/app_test.go", Content: "package app\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	if err := runGazelle(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "app/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "app",
+    srcs = ["app.go"],
+    importpath = "example.com/repo/app",
+    visibility = ["//visibility:public"],
+    deps = [
+        "//lib",  # for Lib
+    ],
+)
+
+go_test(
+    name = "app_test",
+    srcs = ["app_test.go"],
+    embed = [":app"],
+)
+`,
+		},
+	})
+}
+
+func TestUpdateTargetPatterns(t *testing.T) {
+	files := []testtools.FileSpec{
+		{Path: "WORKSPACE"},
+		{Path: "BUILD.bazel", Content: "# gazelle:prefix example.com/repo"},
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "a",
+    srcs = ["old.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "a_test",
+    srcs = ["old_test.go"],
+    embed = [":a"],
+)
+`,
+		},
+		{Path: "a/a.go", Content: "package a\n"},
+		{Path: "a/a_test.go", Content: "package a\n"},
+		{Path: "b/b.go", Content: "package b\n"},
+		{Path: "b/c/c.go", Content: "package c\n"},
+	}
+	dir, cleanup := testtools.CreateFiles(t, files)
+	defer cleanup()
+
+	// Naming one target only updates that rule.
+	if err := runGazelle(dir, []string{"//a:a"}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "a/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "a",
+    srcs = ["a.go"],
+    importpath = "example.com/repo/a",
+    visibility = ["//visibility:public"],
+)
+
+go_test(
+    name = "a_test",
+    srcs = ["old_test.go"],
+    embed = [":a"],
+)
+`,
+		},
+		{Path: "b/BUILD.bazel", NotExist: true},
+	})
+
+	// Negative patterns exclude packages matched by earlier patterns.
+	if err := runGazelle(dir, []string{"b/...", "-//b/c/..."}); err != nil {
+		t.Fatal(err)
+	}
+	testtools.CheckFiles(t, dir, []testtools.FileSpec{
+		{
+			Path: "b/BUILD.bazel",
+			Content: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library")
+
+go_library(
+    name = "b",
+    srcs = ["b.go"],
+    importpath = "example.com/repo/b",
+    visibility = ["//visibility:public"],
+)
+`,
+		},
+		{Path: "b/c/BUILD.bazel", NotExist: true},
+	})
+}
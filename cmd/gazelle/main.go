@@ -34,14 +34,20 @@ const (
 	updateCmd command = iota
 	fixCmd
 	updateReposCmd
+	migrateBzlmodCmd
+	verifyReposCmd
+	whyModuleCmd
 	helpCmd
 )
 
 var commandFromName = map[string]command{
-	"fix":          fixCmd,
-	"help":         helpCmd,
-	"update":       updateCmd,
-	"update-repos": updateReposCmd,
+	"fix":            fixCmd,
+	"help":           helpCmd,
+	"migrate-bzlmod": migrateBzlmodCmd,
+	"update":         updateCmd,
+	"update-repos":   updateReposCmd,
+	"verify-repos":   verifyReposCmd,
+	"why-module":     whyModuleCmd,
 }
 
 var nameFromCommand = []string{
@@ -49,6 +55,9 @@ var nameFromCommand = []string{
 	"update",
 	"fix",
 	"update-repos",
+	"migrate-bzlmod",
+	"verify-repos",
+	"why-module",
 	"help",
 }
 
@@ -92,7 +101,7 @@ func run(wd string, args []string) error {
 	}
 
 	switch cmd {
-	case fixCmd, updateCmd:
+	case fixCmd, updateCmd, whyModuleCmd:
 		if relativePath := os.Getenv("GAZELLE_WORKSPACE_RELATIVE_PATH"); relativePath != "" {
 			wd = filepath.Join(wd, relativePath)
 		}
@@ -101,6 +110,10 @@ func run(wd string, args []string) error {
 		return help()
 	case updateReposCmd:
 		return updateRepos(wd, args)
+	case migrateBzlmodCmd:
+		return migrateBzlmod(wd, args)
+	case verifyReposCmd:
+		return verifyRepos(wd, args)
 	default:
 		log.Panicf("unknown command: %v", cmd)
 	}
@@ -126,6 +139,13 @@ Gazelle defaults to "update".
       existing rules.
   update-repos - updates repository rules in the WORKSPACE file. Run with
       -h for details.
+  migrate-bzlmod - translates go_repository rules in the WORKSPACE file into
+      go_deps configuration in MODULE.bazel. Run with -h for details.
+  verify-repos - checks that repository rules in the WORKSPACE file and
+      go_deps tags in MODULE.bazel match go.mod and go.sum. Run with -h for
+      details.
+  why-module - lists the packages that import an external module and the
+      shortest dependency paths to it. Run with -h for details.
   help - show this message.
 
 For usage information for a specific command, run the command with the -h flag.
@@ -0,0 +1,389 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/internal/module"
+	"github.com/bazelbuild/bazel-gazelle/internal/wspace"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/repo"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/buildtools/build"
+	"golang.org/x/mod/modfile"
+)
+
+type migrateBzlmodConfig struct {
+	repoFilePath string
+	workspace    *rule.File
+}
+
+const migrateBzlmodName = "_migrate-bzlmod"
+
+func getMigrateBzlmodConfig(c *config.Config) *migrateBzlmodConfig {
+	return c.Exts[migrateBzlmodName].(*migrateBzlmodConfig)
+}
+
+var _ config.Configurer = (*migrateBzlmodConfigurer)(nil)
+
+type migrateBzlmodConfigurer struct{}
+
+func (*migrateBzlmodConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {
+	mc := &migrateBzlmodConfig{}
+	c.Exts[migrateBzlmodName] = mc
+	fs.StringVar(&mc.repoFilePath, "from_file", "", "A go.mod file to name in a go_deps.from_file tag. Modules it requires are not declared with go_deps.module tags.")
+}
+
+func (*migrateBzlmodConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	mc := getMigrateBzlmodConfig(c)
+	if len(fs.Args()) != 0 {
+		return fmt.Errorf("got %d positional arguments; wanted 0.\nTry -help for more information.", len(fs.Args()))
+	}
+	if mc.repoFilePath != "" {
+		if filepath.Base(mc.repoFilePath) != "go.mod" {
+			return fmt.Errorf("-from_file: %s: only go.mod files are supported", mc.repoFilePath)
+		}
+		if !filepath.IsAbs(mc.repoFilePath) {
+			mc.repoFilePath = filepath.Join(c.WorkDir, mc.repoFilePath)
+		}
+	}
+
+	workspacePath := wspace.FindWORKSPACEFile(c.RepoRoot)
+	var err error
+	mc.workspace, err = rule.LoadWorkspaceFile(workspacePath, "")
+	if err != nil {
+		return fmt.Errorf("loading WORKSPACE file: %v", err)
+	}
+	c.Repos, _, err = repo.ListRepositories(mc.workspace)
+	if err != nil {
+		return fmt.Errorf("loading WORKSPACE file: %v", err)
+	}
+	return nil
+}
+
+func (*migrateBzlmodConfigurer) KnownDirectives() []string { return nil }
+
+func (*migrateBzlmodConfigurer) Configure(c *config.Config, rel string, f *rule.File) {}
+
+// migrateBzlmod translates go_repository rules declared in WORKSPACE and
+// repository macros into go_deps configuration in MODULE.bazel, then rewrites
+// labels in build files to use the names the repositories are visible as.
+// Repositories that can't be translated completely are reported and left in
+// WORKSPACE for the user to migrate; the migration proceeds without them.
+// MODULE.bazel must already exist and depend on gazelle.
+func migrateBzlmod(wd string, args []string) error {
+	cexts := []config.Configurer{&config.CommonConfigurer{}, &migrateBzlmodConfigurer{}}
+	c, err := newMigrateBzlmodConfiguration(wd, args, cexts)
+	if err != nil {
+		return err
+	}
+	mc := getMigrateBzlmodConfig(c)
+
+	goModPaths := make(map[string]bool)
+	u := module.GoDepsUpdate{GazelleRepo: c.ModuleToApparentName("gazelle")}
+	if mc.repoFilePath != "" {
+		rel, err := filepath.Rel(c.RepoRoot, mc.repoFilePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("%s: file must be in the repository %s", mc.repoFilePath, c.RepoRoot)
+		}
+		u.GoModFiles = []string{filepath.ToSlash(rel)}
+		data, err := os.ReadFile(mc.repoFilePath)
+		if err != nil {
+			return err
+		}
+		f, err := modfile.ParseLax(mc.repoFilePath, data, nil)
+		if err != nil {
+			return err
+		}
+		for _, req := range f.Require {
+			goModPaths[req.Mod.Path] = true
+		}
+	}
+
+	if c.ModuleFiles == nil {
+		// The go_deps extension is loaded from gazelle, so an empty file
+		// wouldn't work.
+		return fmt.Errorf("%s: file does not exist; create it with a module() call and a bazel_dep on gazelle first", filepath.Join(c.RepoRoot, "MODULE.bazel"))
+	}
+	goDeps := c.GoDeps
+
+	repos := append([]*rule.Rule(nil), c.Repos...)
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].Name() < repos[j].Name()
+	})
+	renames := make(map[string]string)
+	var problems []string
+	for _, r := range repos {
+		if r.Kind() != "go_repository" {
+			continue
+		}
+		importPath := r.AttrString("importpath")
+		if importPath == "" {
+			problems = append(problems, fmt.Sprintf("%s: missing importpath", r.Name()))
+			continue
+		}
+		t := translateGoRepository(r, goModPaths[importPath])
+		if len(t.problems) > 0 {
+			// Tags for the attributes that could be translated would change
+			// what's built, for example, a module tag for a repository with
+			// replace would fetch the original module.
+			for _, p := range t.problems {
+				problems = append(problems, fmt.Sprintf("%s: %s", r.Name(), p))
+			}
+			continue
+		}
+		u.Modules = append(u.Modules, t.modules...)
+		u.Overrides = append(u.Overrides, t.overrides...)
+
+		// Labels are only renamed if go_deps will create the repository,
+		// either from a go_deps.module tag or from go.mod.
+		if len(t.modules) == 0 && !goModPaths[importPath] {
+			continue
+		}
+		repoName := label.ImportPathToBazelRepoName(importPath)
+		apparentName := repoName
+		if goDeps != nil && goDeps.UseRepo[repoName] != "" {
+			apparentName = goDeps.UseRepo[repoName]
+		}
+		if r.Name() != apparentName {
+			renames[r.Name()] = apparentName
+		}
+	}
+
+	if err := renameRepoLabels(c, renames); err != nil {
+		return err
+	}
+	u.UsedRepos, err = findReferencedRepos(c)
+	if err != nil {
+		return err
+	}
+	if _, err := module.UpdateGoDeps(c.RepoRoot, u); err != nil {
+		return err
+	}
+
+	for _, p := range problems {
+		log.Printf("could not translate %s", p)
+	}
+	return nil
+}
+
+// goRepositoryTranslation is the go_deps configuration equivalent to a
+// go_repository rule.
+type goRepositoryTranslation struct {
+	modules   []module.GoDepsModule
+	overrides []module.GoDepsTag
+
+	// problems describes attributes that could not be translated.
+	problems []string
+}
+
+// translateGoRepository translates a go_repository rule into go_deps tags.
+// inGoMod indicates the module is required by the go.mod file named with
+// -from_file, so its version is taken from there.
+func translateGoRepository(r *rule.Rule, inGoMod bool) goRepositoryTranslation {
+	var t goRepositoryTranslation
+	importPath := r.AttrString("importpath")
+	gazelleAttrs := make(map[string]build.Expr)
+	patchAttrs := make(map[string]build.Expr)
+	archiveAttrs := make(map[string]build.Expr)
+	var directives []string
+	hasVCS := false
+
+	for _, key := range r.AttrKeys() {
+		switch key {
+		case "name", "importpath", "version", "sum":
+			// Handled below.
+
+		case "commit", "tag", "vcs", "remote":
+			hasVCS = true
+
+		case "build_directives":
+			directives = append(directives, r.AttrStrings(key)...)
+		case "build_file_proto_mode":
+			if mode := r.AttrString(key); mode != "" && mode != "default" {
+				directives = append(directives, "gazelle:proto "+mode)
+			}
+		case "build_naming_convention":
+			if nc := r.AttrString(key); nc != "" && nc != "import_alias" {
+				directives = append(directives, "gazelle:go_naming_convention "+nc)
+			}
+		case "build_file_name":
+			directives = append(directives, "gazelle:build_file_name "+r.AttrString(key))
+		case "build_tags":
+			directives = append(directives, "gazelle:build_tags "+strings.Join(r.AttrStrings(key), ","))
+		case "build_file_generation":
+			if mode := r.AttrString(key); mode != "" && mode != "auto" {
+				gazelleAttrs[key] = &build.StringExpr{Value: mode}
+			}
+		case "build_extra_args":
+			gazelleAttrs[key] = r.Attr(key)
+
+		case "patches":
+			patchAttrs[key] = r.Attr(key)
+		case "patch_args":
+			for _, arg := range r.AttrStrings(key) {
+				if n, err := strconv.Atoi(strings.TrimPrefix(arg, "-p")); err == nil && strings.HasPrefix(arg, "-p") {
+					patchAttrs["patch_strip"] = &build.LiteralExpr{Token: strconv.Itoa(n)}
+				} else {
+					t.problems = append(t.problems, fmt.Sprintf("patch argument %q is not supported by go_deps", arg))
+				}
+			}
+
+		case "urls", "strip_prefix", "sha256":
+			archiveAttrs[key] = r.Attr(key)
+
+		case "replace":
+			t.problems = append(t.problems, fmt.Sprintf("replace = %q is not supported by go_deps; add a replace directive to go.mod instead", r.AttrString(key)))
+
+		default:
+			t.problems = append(t.problems, fmt.Sprintf("attribute %q is not supported by go_deps", key))
+		}
+	}
+
+	if !inGoMod {
+		version, sum := r.AttrString("version"), r.AttrString("sum")
+		switch {
+		case version != "" && sum != "":
+			t.modules = append(t.modules, module.GoDepsModule{Path: importPath, Version: version, Sum: sum})
+		case hasVCS:
+			t.problems = append(t.problems, "repositories fetched from version control are not supported by go_deps; require a module version in go.mod instead")
+		default:
+			t.problems = append(t.problems, "go_deps.module requires a module version and sum")
+		}
+	}
+
+	if len(directives) > 0 {
+		gazelleAttrs["directives"] = stringList(directives)
+	}
+	if len(gazelleAttrs) > 0 {
+		t.overrides = append(t.overrides, module.GoDepsTag{Name: "gazelle_override", Path: importPath, Attrs: gazelleAttrs})
+	}
+	if len(archiveAttrs) > 0 {
+		// archive_override applies patches itself; module_override can't be
+		// combined with it.
+		for key, value := range patchAttrs {
+			archiveAttrs[key] = value
+		}
+		t.overrides = append(t.overrides, module.GoDepsTag{Name: "archive_override", Path: importPath, Attrs: archiveAttrs})
+	} else if len(patchAttrs) > 0 {
+		t.overrides = append(t.overrides, module.GoDepsTag{Name: "module_override", Path: importPath, Attrs: patchAttrs})
+	}
+	return t
+}
+
+func stringList(values []string) *build.ListExpr {
+	list := &build.ListExpr{}
+	for _, v := range values {
+		list.List = append(list.List, &build.StringExpr{Value: v})
+	}
+	return list
+}
+
+// renameRepoLabels rewrites labels in build files and .bzl files that refer
+// to repositories named in renames, which maps old repository names to new
+// ones.
+func renameRepoLabels(c *config.Config, renames map[string]string) error {
+	if len(renames) == 0 {
+		return nil
+	}
+	return walkRepoBuildFiles(c, func(p string, f *build.File) error {
+		changed := false
+		build.Walk(f, func(x build.Expr, _ []build.Expr) {
+			str, ok := x.(*build.StringExpr)
+			if !ok || !strings.HasPrefix(str.Value, "@") {
+				return
+			}
+			l, err := label.Parse(str.Value)
+			if err != nil || l.Canonical {
+				return
+			}
+			if newName, ok := renames[l.Repo]; ok {
+				str.Value = "@" + newName + strings.TrimPrefix(str.Value, "@"+l.Repo)
+				changed = true
+			}
+		})
+		if !changed {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if newData := build.Format(f); !bytes.Equal(data, newData) {
+			return os.WriteFile(p, newData, 0o666)
+		}
+		return nil
+	})
+}
+
+func newMigrateBzlmodConfiguration(wd string, args []string, cexts []config.Configurer) (*config.Config, error) {
+	c := config.New()
+	c.WorkDir = wd
+	fs := flag.NewFlagSet("gazelle", flag.ContinueOnError)
+	// Flag will call this on any parse error. Don't print usage unless
+	// -h or -help were passed explicitly.
+	fs.Usage = func() {}
+	for _, cext := range cexts {
+		cext.RegisterFlags(fs, "migrate-bzlmod", c)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			migrateBzlmodUsage(fs)
+			return nil, err
+		}
+		// flag already prints the error; don't print it again.
+		return nil, errors.New("Try -help for more information")
+	}
+	for _, cext := range cexts {
+		if err := cext.CheckFlags(fs, c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func migrateBzlmodUsage(fs *flag.FlagSet) {
+	fmt.Fprint(os.Stderr, `usage:
+
+# Translate go_repository rules into go_deps tags in MODULE.bazel
+gazelle migrate-bzlmod
+
+# Use go.mod for module versions
+gazelle migrate-bzlmod -from_file=go.mod
+
+The migrate-bzlmod command reads go_repository rules from the WORKSPACE file
+and the repository macros it declares, and writes equivalent go_deps tags,
+overrides, and use_repo entries into MODULE.bazel. Labels in build files are
+rewritten to the names repositories are visible as in MODULE.bazel.
+Attributes that can't be expressed with go_deps are reported. WORKSPACE is
+not modified.
+
+FLAGS:
+
+`)
+	fs.PrintDefaults()
+}
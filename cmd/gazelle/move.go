@@ -0,0 +1,490 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// packageMover updates visited build files after package boundaries change.
+//
+// When a build file is added to a subdirectory, sources in that directory
+// can no longer be listed in srcs of rules in an enclosing package. They're
+// moved to a rule of the same kind and name in the new package, which is
+// created by copying the original rule if it doesn't exist. If all sources
+// were moved, the original rule is deleted, and labels referring to it are
+// updated. Otherwise, the rule is split, and the new label is added next to
+// the old one wherever it's listed.
+//
+// When a build file is removed, the sources in its directory belong to the
+// enclosing package. Labels referring to them through the removed package
+// are updated.
+type packageMover struct {
+	c         *config.Config
+	ruleIndex *resolve.RuleIndex
+	kinds     map[string]rule.KindInfo
+	visits    map[string]*visitRecord
+
+	// newPkgs lists packages whose build files are new or were empty, in
+	// the order they were visited.
+	newPkgs []string
+
+	// isPkg caches the results of isPackage.
+	isPkg map[string]bool
+
+	// moves maps labels of rules whose sources were moved to the labels of
+	// the rules that received them.
+	moves map[label.Label]ruleMove
+}
+
+// ruleMove describes where the sources of a rule were moved.
+type ruleMove struct {
+	to []label.Label
+
+	// deleted is true if all sources were moved and the original rule was
+	// deleted. Labels referring to it are replaced instead of supplemented.
+	deleted bool
+}
+
+// movePackageSources moves sources between the packages in visits. Labels
+// are updated in visits and in otherFiles, the build files in directories
+// that weren't updated. movePackageSources returns the records for
+// otherFiles that were changed, which need to be emitted along with visited
+// files.
+func movePackageSources(c *config.Config, visits, otherFiles []visitRecord, ruleIndex *resolve.RuleIndex, kinds map[string]rule.KindInfo) []visitRecord {
+	m := &packageMover{
+		c:         c,
+		ruleIndex: ruleIndex,
+		kinds:     kinds,
+		visits:    make(map[string]*visitRecord, len(visits)),
+		isPkg:     make(map[string]bool),
+		moves:     make(map[label.Label]ruleMove),
+	}
+	for i := range visits {
+		m.visits[visits[i].pkgRel] = &visits[i]
+	}
+	for i := range visits {
+		v := &visits[i]
+		if len(bytes.TrimSpace(v.file.Content)) == 0 && m.isPackage(v.pkgRel) {
+			m.newPkgs = append(m.newPkgs, v.pkgRel)
+		}
+	}
+	for i := range visits {
+		v := &visits[i]
+		for _, r := range v.file.Rules {
+			m.moveRuleSources(v, r)
+		}
+	}
+	for i := range visits {
+		m.updateMovedLabels(visits[i].file)
+		m.fixOrphanedLabels(visits[i].file)
+	}
+	var changed []visitRecord
+	for _, v := range otherFiles {
+		moved := m.updateMovedLabels(v.file)
+		if orphaned := m.fixOrphanedLabels(v.file); moved || orphaned {
+			changed = append(changed, v)
+		}
+	}
+	return changed
+}
+
+// isPackage returns whether the directory rel has a build file. Files
+// Gazelle is about to create count.
+func (m *packageMover) isPackage(rel string) bool {
+	if isPkg, ok := m.isPkg[rel]; ok {
+		return isPkg
+	}
+	isPkg := false
+	if v, ok := m.visits[rel]; ok {
+		isPkg = v.file.Content != nil || len(v.file.Rules) > 0
+	} else {
+		dir := filepath.Join(m.c.RepoRoot, filepath.FromSlash(rel))
+		for _, name := range m.c.ValidBuildFileNames {
+			if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && !fi.IsDir() {
+				isPkg = true
+				break
+			}
+		}
+	}
+	m.isPkg[rel] = isPkg
+	return isPkg
+}
+
+// moveRuleSources moves sources listed in srcs of r that are in a
+// subpackage of v's package.
+func (m *packageMover) moveRuleSources(v *visitRecord, r *rule.Rule) {
+	if r.ShouldKeep() {
+		return
+	}
+	srcs, ok := r.Attr("srcs").(*bzl.ListExpr)
+	if !ok {
+		m.warnGlobSources(v, r)
+		return
+	}
+	var kept []bzl.Expr
+	moved := make(map[string][]*bzl.StringExpr)
+	var movedPkgs []string
+	for _, e := range srcs.List {
+		s, ok := e.(*bzl.StringExpr)
+		if !ok || strings.HasPrefix(s.Value, "//") || strings.HasPrefix(s.Value, "@") {
+			kept = append(kept, e)
+			continue
+		}
+		src := strings.TrimPrefix(s.Value, ":")
+		pkg := ""
+		for dir := path.Dir(src); dir != "."; dir = path.Dir(dir) {
+			if rel := path.Join(v.pkgRel, dir); m.isPackage(rel) {
+				pkg = rel
+				break
+			}
+		}
+		if pkg == "" {
+			kept = append(kept, e)
+			continue
+		}
+		if _, ok := moved[pkg]; !ok {
+			movedPkgs = append(movedPkgs, pkg)
+		}
+		moved[pkg] = append(moved[pkg], s)
+	}
+	if len(moved) == 0 {
+		return
+	}
+
+	var mv ruleMove
+	for _, pkg := range movedPkgs {
+		dst := m.moveTarget(v, r, pkg)
+		if dst == nil {
+			for _, s := range moved[pkg] {
+				kept = append(kept, s)
+			}
+			continue
+		}
+		var dstSrcs []*bzl.StringExpr
+		for _, s := range moved[pkg] {
+			ds := *s
+			ds.Value = pathtools.TrimPrefix(path.Join(v.pkgRel, strings.TrimPrefix(s.Value, ":")), pkg)
+			dstSrcs = append(dstSrcs, &ds)
+		}
+		addSrcs(dst, dstSrcs)
+		mv.to = append(mv.to, label.New("", pkg, r.Name()))
+	}
+	if len(mv.to) == 0 {
+		return
+	}
+	if len(kept) == 0 {
+		r.Delete()
+		mv.deleted = true
+	} else {
+		srcs.List = kept
+		r.SetAttr("srcs", srcs)
+	}
+	m.moves[label.New("", v.pkgRel, r.Name())] = mv
+}
+
+// moveTarget returns the rule in the package pkg that sources of r should be
+// moved to. If there's no rule with the same name, a copy of r is added to
+// the package's build file. moveTarget returns nil if sources can't be moved.
+func (m *packageMover) moveTarget(v *visitRecord, r *rule.Rule, pkg string) *rule.Rule {
+	tv, ok := m.visits[pkg]
+	if !ok {
+		log.Printf("%s: %s(%s) lists sources in package %s, which is not being updated. Run gazelle in %s to move them.", v.file.Path, r.Kind(), r.Name(), pkg, pkg)
+		return nil
+	}
+	for _, dst := range tv.file.Rules {
+		if dst.Name() != r.Name() {
+			continue
+		}
+		if dst.Kind() != r.Kind() {
+			log.Printf("%s: could not move sources of %s(%s) to %s: a rule of the same name has kind %s", v.file.Path, r.Kind(), r.Name(), tv.file.Path, dst.Kind())
+			return nil
+		}
+		return dst
+	}
+
+	// Attributes that identify the rule, like importpath, and attributes
+	// that make it non-empty, like embed, describe the original sources.
+	// They're left for the languages to generate in the new package.
+	info, ok := v.mappedKindInfo[r.Kind()]
+	if !ok {
+		if info, ok = m.kinds[r.Kind()]; !ok {
+			info = m.kinds[v.c.AliasMap[r.Kind()]]
+		}
+	}
+	skip := map[string]bool{"name": true, "srcs": true}
+	for _, key := range info.MatchAttrs {
+		skip[key] = true
+	}
+	for key := range info.NonEmptyAttrs {
+		skip[key] = true
+	}
+
+	dst := rule.NewRule(r.Kind(), r.Name())
+	for _, key := range r.AttrKeys() {
+		if skip[key] {
+			continue
+		}
+		expr := copyExpr(r.Attr(key))
+		if expr == nil {
+			continue
+		}
+		// Relative labels refer to targets in the original package.
+		bzl.Walk(expr, func(x bzl.Expr, _ []bzl.Expr) {
+			if s, ok := x.(*bzl.StringExpr); ok && strings.HasPrefix(s.Value, ":") {
+				if l, err := label.Parse(s.Value); err == nil {
+					s.Value = l.Abs("", v.pkgRel).String()
+				}
+			}
+		})
+		dst.SetAttr(key, expr)
+		if c := r.AttrComments(key); c != nil {
+			*dst.AttrComments(key) = *c
+		}
+	}
+	for _, l := range v.file.Loads {
+		if l.Has(r.Kind()) {
+			addLoad(tv.file, l.Name(), l.Unalias(r.Kind()), r.Kind())
+		}
+	}
+	dst.Insert(tv.file)
+	if tv.c.IndexLibraries {
+		m.ruleIndex.AddRule(tv.c, dst, tv.file)
+	}
+	return dst
+}
+
+// warnGlobSources logs a warning if srcs of r is computed with glob and a
+// package was just created beneath v's package. glob doesn't match files in
+// subpackages, so sources there may have silently dropped out of r. They
+// can't be moved, since the files glob matched aren't known.
+func (m *packageMover) warnGlobSources(v *visitRecord, r *rule.Rule) {
+	hasGlob := false
+	bzl.Walk(r.Attr("srcs"), func(x bzl.Expr, _ []bzl.Expr) {
+		if call, ok := x.(*bzl.CallExpr); ok {
+			if ident, ok := call.X.(*bzl.Ident); ok && ident.Name == "glob" {
+				hasGlob = true
+			}
+		}
+	})
+	if !hasGlob {
+		return
+	}
+	subpkgs := label.Pattern{Pkg: v.pkgRel, Recursive: true}
+	for _, pkg := range m.newPkgs {
+		if pkg != v.pkgRel && subpkgs.MatchesPackage(pkg) {
+			log.Printf("%s: %s(%s) lists sources with glob, which doesn't match files in the new package %s. Move them there by hand.", v.file.Path, r.Kind(), r.Name(), pkg)
+		}
+	}
+}
+
+// fixOrphanedLabels updates labels in f that refer to source files in
+// directories that are no longer packages, so they refer to the files in
+// the enclosing package instead. It returns whether any label was updated.
+func (m *packageMover) fixOrphanedLabels(f *rule.File) bool {
+	fixed := false
+	for _, r := range f.Rules {
+		for _, key := range r.AttrKeys() {
+			expr := r.Attr(key)
+			changed := false
+			bzl.Walk(expr, func(x bzl.Expr, _ []bzl.Expr) {
+				s, ok := x.(*bzl.StringExpr)
+				if !ok || !strings.HasPrefix(s.Value, "//") {
+					return
+				}
+				l, err := label.Parse(s.Value)
+				if err != nil || l.Repo != "" || l.Pkg == "" || m.isPackage(l.Pkg) {
+					return
+				}
+				file := path.Join(l.Pkg, l.Name)
+				if _, err := os.Stat(filepath.Join(m.c.RepoRoot, filepath.FromSlash(file))); err != nil {
+					return
+				}
+				pkg := path.Dir(l.Pkg)
+				for ; pkg != "." && !m.isPackage(pkg); pkg = path.Dir(pkg) {
+				}
+				if pkg == "." {
+					if pkg = ""; !m.isPackage(pkg) {
+						return
+					}
+				}
+				s.Value = label.New("", pkg, pathtools.TrimPrefix(file, pkg)).Rel("", f.Pkg).String()
+				changed = true
+			})
+			if changed {
+				r.SetAttr(key, expr)
+				fixed = true
+			}
+		}
+	}
+	return fixed
+}
+
+// updateMovedLabels updates labels in f that refer to rules whose sources
+// were moved. If the original rule was deleted, its label is replaced with
+// the labels of the rules the sources were moved to. Otherwise, those labels
+// are added next to it in lists. updateMovedLabels returns whether any label
+// was updated.
+func (m *packageMover) updateMovedLabels(f *rule.File) bool {
+	if len(m.moves) == 0 {
+		return false
+	}
+	updated := false
+	// lookup returns the move for a label string and the labels to refer to
+	// the new rules with, in the same form.
+	lookup := func(e bzl.Expr) (ruleMove, []string, bool) {
+		s, ok := e.(*bzl.StringExpr)
+		if !ok || (!strings.HasPrefix(s.Value, ":") && !strings.HasPrefix(s.Value, "//")) {
+			return ruleMove{}, nil, false
+		}
+		l, err := label.Parse(s.Value)
+		if err != nil {
+			return ruleMove{}, nil, false
+		}
+		mv, ok := m.moves[l.Abs("", f.Pkg)]
+		if !ok {
+			return ruleMove{}, nil, false
+		}
+		to := make([]string, len(mv.to))
+		for i, t := range mv.to {
+			to[i] = t.Rel("", f.Pkg).String()
+		}
+		return mv, to, true
+	}
+
+	for _, r := range f.Rules {
+		for _, key := range r.AttrKeys() {
+			if key == "name" {
+				continue
+			}
+			expr := r.Attr(key)
+			if mv, to, ok := lookup(expr); ok {
+				if mv.deleted && len(to) == 1 {
+					r.SetAttr(key, to[0])
+					updated = true
+				}
+				continue
+			}
+			changed := false
+			bzl.Walk(expr, func(x bzl.Expr, _ []bzl.Expr) {
+				list, ok := x.(*bzl.ListExpr)
+				if !ok {
+					return
+				}
+				have := make(map[string]bool)
+				for _, e := range list.List {
+					if s, ok := e.(*bzl.StringExpr); ok {
+						have[s.Value] = true
+					}
+				}
+				var newList []bzl.Expr
+				for _, e := range list.List {
+					mv, to, ok := lookup(e)
+					if !ok {
+						newList = append(newList, e)
+						continue
+					}
+					changed = true
+					if !mv.deleted {
+						newList = append(newList, e)
+					}
+					for _, t := range to {
+						if !have[t] {
+							have[t] = true
+							newList = append(newList, &bzl.StringExpr{Value: t})
+						}
+					}
+				}
+				list.List = newList
+			})
+			if changed {
+				r.SetAttr(key, expr)
+				updated = true
+			}
+		}
+	}
+	return updated
+}
+
+// addSrcs appends sources to the srcs attribute of r, skipping sources that
+// are already listed.
+func addSrcs(r *rule.Rule, srcs []*bzl.StringExpr) {
+	list, ok := r.Attr("srcs").(*bzl.ListExpr)
+	if !ok {
+		if r.Attr("srcs") != nil {
+			log.Printf("could not add sources to %s(%s): srcs is not a list", r.Kind(), r.Name())
+			return
+		}
+		list = &bzl.ListExpr{}
+	}
+	have := make(map[string]bool)
+	for _, e := range list.List {
+		if s, ok := e.(*bzl.StringExpr); ok {
+			have[s.Value] = true
+		}
+	}
+	for _, s := range srcs {
+		if !have[s.Value] {
+			have[s.Value] = true
+			list.List = append(list.List, s)
+		}
+	}
+	r.SetAttr("srcs", list)
+}
+
+// addLoad makes sure f loads symbol as alias from the file name.
+func addLoad(f *rule.File, name, symbol, alias string) {
+	for _, l := range f.Loads {
+		if l.Name() == name {
+			if !l.Has(alias) {
+				if symbol == alias {
+					l.Add(symbol)
+				} else {
+					l.AddAlias(alias, symbol)
+				}
+			}
+			return
+		}
+	}
+	l := rule.NewLoad(name)
+	if symbol == alias {
+		l.Add(symbol)
+	} else {
+		l.AddAlias(alias, symbol)
+	}
+	l.Insert(f, 0)
+}
+
+// copyExpr returns a deep copy of e, including comments.
+func copyExpr(e bzl.Expr) bzl.Expr {
+	f, err := bzl.ParseBuild("", []byte(bzl.FormatString(e)))
+	if err != nil || len(f.Stmt) != 1 {
+		return nil
+	}
+	return f.Stmt[0]
+}
@@ -25,7 +25,7 @@ import (
 
 func printFile(c *config.Config, f *rule.File) error {
 	fmt.Printf(">>> %s\n", f.Path)
-	content := f.Format()
+	content := formatFile(c, f)
 	_, err := os.Stdout.Write(content)
 	return err
 }
@@ -0,0 +1,68 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/repo"
+)
+
+// remoteCacheFlags holds flags that control how repo.RemoteCache stores
+// results of looking up external repositories between runs. They're shared
+// by commands that resolve external dependencies.
+type remoteCacheFlags struct {
+	dir                            string
+	refresh                        bool
+	stableTTL, volatileTTL, errTTL time.Duration
+}
+
+func (f *remoteCacheFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.dir, "remote_cache_dir", "", "directory where Gazelle caches the results of looking up external repositories and modules between runs. By default, nothing is cached.")
+	fs.BoolVar(&f.refresh, "refresh", false, "when set with -remote_cache_dir, Gazelle ignores cached results and looks them up again")
+	fs.DurationVar(&f.stableTTL, "remote_cache_ttl", repo.DefaultStableTTL, "with -remote_cache_dir, how long successful lookups are cached")
+	fs.DurationVar(&f.volatileTTL, "remote_cache_volatile_ttl", repo.DefaultVolatileTTL, "with -remote_cache_dir, how long results that change often, like the latest commit of a repository, are cached")
+	fs.DurationVar(&f.errTTL, "remote_cache_error_ttl", repo.DefaultErrorTTL, "with -remote_cache_dir, how long failed lookups are cached")
+}
+
+func (f *remoteCacheFlags) check(c *config.Config) {
+	if f.dir != "" && !filepath.IsAbs(f.dir) {
+		f.dir = filepath.Join(c.WorkDir, f.dir)
+	}
+}
+
+// newRemoteCache creates a repo.RemoteCache with the given known
+// repositories that uses the persistent cache directory, if one was set.
+func (f *remoteCacheFlags) newRemoteCache(knownRepos []repo.Repo) (*repo.RemoteCache, func() error, error) {
+	rc, cleanup := repo.NewRemoteCache(knownRepos)
+	if f.dir != "" {
+		cfg := repo.PersistentCacheConfig{
+			Dir:         f.dir,
+			Refresh:     f.refresh,
+			StableTTL:   f.stableTTL,
+			VolatileTTL: f.volatileTTL,
+			ErrorTTL:    f.errTTL,
+		}
+		if err := rc.UsePersistentCache(cfg); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+	return rc, cleanup, nil
+}
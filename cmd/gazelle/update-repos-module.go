@@ -0,0 +1,240 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/internal/module"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"github.com/bazelbuild/bazel-gazelle/repo"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// updateModuleFile implements update-repos -to_module. Instead of writing
+// repository rules, it declares modules with the go_deps extension in
+// MODULE.bazel and keeps use_repo in sync with the repositories referenced
+// by build files.
+func updateModuleFile(c *config.Config, rc *repo.RemoteCache) error {
+	uc := getUpdateReposConfig(c)
+	u := module.GoDepsUpdate{
+		Prune:       uc.pruneRules,
+		GazelleRepo: c.ModuleToApparentName("gazelle"),
+	}
+
+	switch base := filepath.Base(uc.repoFilePath); {
+	case uc.repoFilePath != "" && (base == "go.mod" || base == "go.work"):
+		// go_deps reads these files itself, so there's no need to list
+		// individual modules.
+		rel, err := filepath.Rel(c.RepoRoot, uc.repoFilePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("%s: file must be in the repository %s", uc.repoFilePath, c.RepoRoot)
+		}
+		if base == "go.mod" {
+			u.GoModFiles = []string{filepath.ToSlash(rel)}
+		} else {
+			u.GoWorkFiles = []string{filepath.ToSlash(rel)}
+		}
+
+	default:
+		var err error
+		var gen []*rule.Rule
+		if uc.repoFilePath == "" {
+			gen, err = updateRepoImports(c, rc)
+		} else {
+			gen, _, err = importRepos(c, rc)
+		}
+		if err != nil {
+			return err
+		}
+		for _, r := range gen {
+			if r.Kind() != "go_repository" {
+				continue
+			}
+			m := module.GoDepsModule{
+				Path:    r.AttrString("importpath"),
+				Version: r.AttrString("version"),
+				Sum:     r.AttrString("sum"),
+			}
+			if m.Version == "" || m.Sum == "" {
+				return fmt.Errorf("%s: go_deps.module requires a module version and sum, but the repository was resolved to a VCS revision", m.Path)
+			}
+			u.Modules = append(u.Modules, m)
+		}
+	}
+
+	var err error
+	u.UsedRepos, err = findReferencedRepos(c)
+	if err != nil {
+		return err
+	}
+	_, err = module.UpdateGoDeps(c.RepoRoot, u)
+	return err
+}
+
+// findReferencedRepos returns the apparent names of external repositories
+// referenced by labels in build files and .bzl files in the main
+// repository.
+func findReferencedRepos(c *config.Config) (map[string]bool, error) {
+	repos := make(map[string]bool)
+	err := walkRepoBuildFiles(c, func(_ string, f *build.File) error {
+		build.Walk(f, func(x build.Expr, _ []build.Expr) {
+			str, ok := x.(*build.StringExpr)
+			if !ok || !strings.HasPrefix(str.Value, "@") {
+				return
+			}
+			if l, err := label.Parse(str.Value); err == nil && l.Repo != "" && l.Repo != "@" && !l.Canonical {
+				repos[l.Repo] = true
+			}
+		})
+		return nil
+	})
+	return repos, err
+}
+
+// walkRepoBuildFiles parses each build file and .bzl file in the main
+// repository and calls fn with its path. Directories excluded with
+// "# gazelle:exclude", .bazelignore or REPO.bazel are not searched, nor are
+// directories that contain their own repository boundary files. Files that
+// can't be parsed are logged and skipped.
+func walkRepoBuildFiles(c *config.Config, fn func(p string, f *build.File) error) error {
+	// Walk with a copy of the configuration that has the walk extension set up,
+	// so that build_file_name and exclusion rules apply as in update.
+	wc := c.Clone()
+	cexts := []config.Configurer{&walk.Configurer{}, newDirectiveNamesConfigurer()}
+	fs := flag.NewFlagSet("walk", flag.ContinueOnError)
+	for _, cext := range cexts {
+		cext.RegisterFlags(fs, "update-repos", wc)
+	}
+	if err := fs.Parse([]string{"-build_file_name=" + strings.Join(c.ValidBuildFileNames, ",")}); err != nil {
+		return err
+	}
+	for _, cext := range cexts {
+		if err := cext.CheckFlags(fs, wc); err != nil {
+			return err
+		}
+	}
+
+	// The walk visits subdirectories before their parents, so nested
+	// repositories are only known once it's done. Collect files first.
+	type repoFile struct {
+		rel, path string
+		f         *build.File
+	}
+	var files []repoFile
+	var boundaries []string
+	walkErr := walk.Walk2(wc, cexts, []string{c.RepoRoot}, walk.VisitAllUpdateSubdirsMode, func(args walk.Walk2FuncArgs) walk.Walk2FuncResult {
+		if args.File != nil {
+			files = append(files, repoFile{rel: args.Rel, path: args.File.Path, f: args.File.File})
+		}
+		for _, name := range args.RegularFiles {
+			if strings.Contains(name, "/") {
+				// Files from subdirectories without build files are listed here
+				// in update_only generation mode. They're reported by the walk
+				// callback for their own directory too, so skip them.
+				continue
+			}
+			if args.Rel != "" && isRepoBoundaryFile(name) {
+				boundaries = append(boundaries, args.Rel)
+			}
+			if !strings.HasSuffix(name, ".bzl") {
+				continue
+			}
+			p := filepath.Join(args.Dir, name)
+			data, err := os.ReadFile(p)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			f, err := build.ParseBzl(p, data)
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			files = append(files, repoFile{rel: args.Rel, path: p, f: f})
+		}
+		return walk.Walk2FuncResult{}
+	})
+	if walkErr != nil {
+		// Errors here are build files that couldn't be read or parsed. They
+		// don't prevent the rest of the repository from being searched.
+		log.Print(walkErr)
+		if c.Strict {
+			return walkErr
+		}
+	}
+
+files:
+	for _, file := range files {
+		for _, b := range boundaries {
+			if pathtools.HasPrefix(file.rel, b) {
+				continue files
+			}
+		}
+		if err := fn(file.path, file.f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isRepoBoundaryFile returns whether a file with the given name marks the
+// root of a repository.
+func isRepoBoundaryFile(name string) bool {
+	switch name {
+	case "MODULE.bazel", "REPO.bazel", "WORKSPACE", "WORKSPACE.bazel":
+		return true
+	}
+	return false
+}
+
+// directiveNamesConfigurer accepts the directives known by gazelle's other
+// configuration extensions without applying them. walkRepoBuildFiles only
+// configures the walk, and this keeps other directives from being reported
+// as unknown.
+type directiveNamesConfigurer struct {
+	directives []string
+}
+
+func newDirectiveNamesConfigurer() *directiveNamesConfigurer {
+	cr := &directiveNamesConfigurer{}
+	cexts := []config.Configurer{&config.CommonConfigurer{}, &updateConfigurer{}, &resolve.Configurer{}}
+	for _, lang := range languages {
+		cexts = append(cexts, lang)
+	}
+	for _, cext := range cexts {
+		cr.directives = append(cr.directives, cext.KnownDirectives()...)
+	}
+	return cr
+}
+
+func (*directiveNamesConfigurer) RegisterFlags(*flag.FlagSet, string, *config.Config) {}
+
+func (*directiveNamesConfigurer) CheckFlags(*flag.FlagSet, *config.Config) error { return nil }
+
+func (cr *directiveNamesConfigurer) KnownDirectives() []string { return cr.directives }
+
+func (*directiveNamesConfigurer) Configure(*config.Config, string, *rule.File) {}
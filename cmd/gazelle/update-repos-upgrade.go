@@ -0,0 +1,227 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/repo"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bmatcuk/doublestar/v4"
+	"golang.org/x/mod/semver"
+	"golang.org/x/sync/errgroup"
+)
+
+// moduleUpgrade describes a planned change to the version of a module
+// declared with a go_repository rule. before is empty for modules that
+// aren't declared yet and are added because an upgraded module requires them.
+type moduleUpgrade struct {
+	path, before, after string
+
+	// requiredBy is set when the module is upgraded because another upgraded
+	// module requires a newer version. It's in "path@version" form.
+	requiredBy string
+}
+
+// planUpgrades finds newer versions of modules declared with go_repository
+// rules according to the -upgrade and -only flags. Modules that don't match
+// -only are upgraded too when an upgraded module requires a newer version of
+// them, so the set of versions is consistent with minimal version selection.
+// Required modules that aren't declared at all are added.
+//
+// Rules without a version, with a replace attribute, or declared with
+// directives are not considered. The returned upgrades are sorted by module
+// path.
+func planUpgrades(c *config.Config, rc *repo.RemoteCache) ([]*moduleUpgrade, error) {
+	uc := getUpdateReposConfig(c)
+	known := make(map[string]*moduleUpgrade)
+	declared := make(map[string]bool)
+	var targets []*moduleUpgrade
+	for _, r := range c.Repos {
+		if r.Kind() != "go_repository" {
+			continue
+		}
+		declared[r.AttrString("importpath")] = true
+		if repo.IsFromDirective(r) || r.AttrString("replace") != "" {
+			continue
+		}
+		modPath, version := r.AttrString("importpath"), r.AttrString("version")
+		if modPath == "" || !semver.IsValid(version) {
+			continue
+		}
+		u := &moduleUpgrade{path: modPath, before: version, after: version}
+		known[modPath] = u
+		if uc.upgradeOnly != "" {
+			if ok, _ := doublestar.Match(uc.upgradeOnly, modPath); !ok {
+				continue
+			}
+		}
+		targets = append(targets, u)
+	}
+
+	var eg errgroup.Group
+	for _, u := range targets {
+		u := u
+		eg.Go(func() error {
+			versions, err := rc.ModVersions(u.path)
+			if err != nil {
+				return err
+			}
+			u.after = selectUpgrade(uc.upgrade, u.before, versions)
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	// Raise the versions of known modules required by upgraded modules until
+	// nothing changes. Modules that aren't declared yet are added. Versions
+	// only go up, so this terminates.
+	var queue []*moduleUpgrade
+	for _, u := range targets {
+		if u.after != u.before {
+			queue = append(queue, u)
+		}
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		requires, err := rc.ModRequires(u.path, u.after)
+		if err != nil {
+			return nil, err
+		}
+		for reqPath, reqVersion := range requires {
+			dep := known[reqPath]
+			if dep == nil {
+				if declared[reqPath] {
+					// Declared without a version we can compare, for example,
+					// with a commit or a replace attribute.
+					continue
+				}
+				dep = &moduleUpgrade{path: reqPath}
+				known[reqPath] = dep
+				declared[reqPath] = true
+			} else if semver.Compare(reqVersion, dep.after) <= 0 {
+				continue
+			}
+			dep.after = reqVersion
+			dep.requiredBy = u.path + "@" + u.after
+			queue = append(queue, dep)
+		}
+	}
+
+	var upgrades []*moduleUpgrade
+	for _, u := range known {
+		if u.after != u.before {
+			upgrades = append(upgrades, u)
+		}
+	}
+	sort.Slice(upgrades, func(i, j int) bool { return upgrades[i].path < upgrades[j].path })
+	return upgrades, nil
+}
+
+// selectUpgrade returns the highest release version in versions that the
+// mode allows as an upgrade from current, or current if there's none.
+// "patch" keeps the major and minor versions, "minor" keeps the major
+// version, and "latest" allows any version with the same module path.
+// +incompatible versions are only considered if current is one.
+func selectUpgrade(mode, current string, versions []string) string {
+	best := current
+	for _, v := range versions {
+		if !semver.IsValid(v) || semver.Prerelease(v) != "" || semver.Compare(v, best) <= 0 {
+			continue
+		}
+		if strings.HasSuffix(v, "+incompatible") != strings.HasSuffix(current, "+incompatible") {
+			continue
+		}
+		switch mode {
+		case "patch":
+			if semver.MajorMinor(v) != semver.MajorMinor(current) {
+				continue
+			}
+		case "minor":
+			if semver.Major(v) != semver.Major(current) {
+				continue
+			}
+		}
+		best = v
+	}
+	return best
+}
+
+// printUpgrades writes a table of planned upgrades.
+func printUpgrades(w io.Writer, upgrades []*moduleUpgrade) error {
+	if len(upgrades) == 0 {
+		_, err := fmt.Fprintln(w, "all modules are up to date")
+		return err
+	}
+	buf := &bytes.Buffer{}
+	tw := tabwriter.NewWriter(buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tBEFORE\tAFTER\tNOTE")
+	for _, u := range upgrades {
+		note := ""
+		if u.requiredBy != "" {
+			note = "required by " + u.requiredBy
+		}
+		before := u.before
+		if before == "" {
+			before = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", u.path, before, u.after, note)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	// Rows without a note are padded up to the last column.
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " \n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upgradeRepos plans module upgrades, prints them, and generates
+// go_repository rules with the new versions and sums.
+func upgradeRepos(c *config.Config, rc *repo.RemoteCache) ([]*rule.Rule, error) {
+	upgrades, err := planUpgrades(c, rc)
+	if err != nil {
+		return nil, err
+	}
+	if err := printUpgrades(os.Stdout, upgrades); err != nil {
+		return nil, err
+	}
+	if len(upgrades) == 0 {
+		return nil, nil
+	}
+	uc := getUpdateReposConfig(c)
+	uc.importPaths = make([]string, len(upgrades))
+	for i, u := range upgrades {
+		uc.importPaths[i] = u.path + "@" + u.after
+	}
+	return updateRepoImports(c, rc)
+}
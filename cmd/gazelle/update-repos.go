@@ -27,12 +27,14 @@ import (
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
+	gzflag "github.com/bazelbuild/bazel-gazelle/flag"
 	"github.com/bazelbuild/bazel-gazelle/internal/wspace"
 	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/language"
 	"github.com/bazelbuild/bazel-gazelle/merger"
 	"github.com/bazelbuild/bazel-gazelle/repo"
 	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bmatcuk/doublestar/v4"
 )
 
 type updateReposConfig struct {
@@ -40,12 +42,16 @@ type updateReposConfig struct {
 	importPaths   []string
 	macroFileName string
 	macroDefName  string
+	toModule      bool
 	pruneRules    bool
+	upgrade       string
+	upgradeOnly   string
 	workspace     *rule.File
 	repoFileMap   map[string]*rule.File
 	cpuProfile    string
 	memProfile    string
 	profile       profiler
+	remoteCache   remoteCacheFlags
 }
 
 const updateReposName = "_update-repos"
@@ -85,10 +91,15 @@ func (*updateReposConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *con
 	c.Exts[updateReposName] = uc
 	fs.StringVar(&uc.repoFilePath, "from_file", "", "Gazelle will translate repositories listed in this file into repository rules in WORKSPACE or a .bzl macro function. Gopkg.lock and go.mod files are supported")
 	fs.Var(macroFlag{macroFileName: &uc.macroFileName, macroDefName: &uc.macroDefName}, "to_macro", "Tells Gazelle to write repository rules into a .bzl macro function rather than the WORKSPACE file. . The expected format is: macroFile%defName")
-	fs.BoolVar(&uc.pruneRules, "prune", false, "When enabled, Gazelle will remove rules that no longer have equivalent repos in the go.mod file. Can only used with -from_file.")
+	fs.BoolVar(&uc.toModule, "to_module", false, "Tells Gazelle to write go_deps tags and use_repo entries into MODULE.bazel rather than repository rules into WORKSPACE or a macro.")
+	fs.BoolVar(&uc.pruneRules, "prune", false, "When enabled, Gazelle will remove rules that no longer have equivalent repos in the go.mod file. With -to_module, Gazelle will instead remove use_repo entries for repos that are not referenced by build files. Can only used with -from_file or -to_module.")
+
+	fs.Var(&gzflag.AllowedStringFlag{Value: &uc.upgrade, Allowed: []string{"patch", "minor", "latest"}}, "upgrade", "Upgrades go_repository rules with versions to newer versions from the module proxy: patch, minor or latest. Modules required by upgraded modules are upgraded as needed.")
+	fs.StringVar(&uc.upgradeOnly, "only", "", "With -upgrade, only modules whose paths match this glob are upgraded, apart from upgrades they require.")
 
 	fs.StringVar(&uc.cpuProfile, "cpuprofile", "", "write cpu profile to `file`")
 	fs.StringVar(&uc.memProfile, "memprofile", "", "write memory profile to `file`")
+	uc.remoteCache.register(fs)
 }
 
 func (*updateReposConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
@@ -98,8 +109,30 @@ func (*updateReposConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) err
 		return err
 	}
 	uc.profile = p
+	uc.remoteCache.check(c)
+
+	if uc.toModule && uc.macroFileName != "" {
+		return fmt.Errorf("the -to_module and -to_macro options cannot be used together")
+	}
+
+	if uc.upgradeOnly != "" {
+		if uc.upgrade == "" {
+			return fmt.Errorf("the -only option can only be used with -upgrade")
+		}
+		if !doublestar.ValidatePattern(uc.upgradeOnly) {
+			return fmt.Errorf("invalid -only pattern: %q", uc.upgradeOnly)
+		}
+	}
 
 	switch {
+	case uc.upgrade != "":
+		if len(fs.Args()) != 0 || uc.repoFilePath != "" {
+			return fmt.Errorf("-upgrade cannot be used with -from_file or positional arguments.\nTry -help for more information.")
+		}
+		if uc.toModule || uc.pruneRules {
+			return fmt.Errorf("-upgrade cannot be used with -to_module or -prune")
+		}
+
 	case uc.repoFilePath != "":
 		if len(fs.Args()) != 0 {
 			return fmt.Errorf("got %d positional arguments with -from_file; wanted 0.\nTry -help for more information.", len(fs.Args()))
@@ -112,8 +145,8 @@ func (*updateReposConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) err
 		if len(fs.Args()) == 0 {
 			return fmt.Errorf("no repositories specified\nTry -help for more information.")
 		}
-		if uc.pruneRules {
-			return fmt.Errorf("the -prune option can only be used with -from_file")
+		if uc.pruneRules && !uc.toModule {
+			return fmt.Errorf("the -prune option can only be used with -from_file or -to_module")
 		}
 		uc.importPaths = fs.Args()
 	}
@@ -121,7 +154,7 @@ func (*updateReposConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) err
 	workspacePath := wspace.FindWORKSPACEFile(c.RepoRoot)
 	uc.workspace, err = rule.LoadWorkspaceFile(workspacePath, "")
 	if err != nil {
-		if c.Bzlmod {
+		if c.Bzlmod || uc.toModule {
 			return nil
 		} else {
 			return fmt.Errorf("loading WORKSPACE file: %v", err)
@@ -190,13 +223,20 @@ func updateRepos(wd string, args []string) (err error) {
 			})
 		}
 	}
-	rc, cleanup := repo.NewRemoteCache(knownRepos)
+	rc, cleanup, err := uc.remoteCache.newRemoteCache(knownRepos)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if cerr := cleanup(); err == nil && cerr != nil {
 			err = cerr
 		}
 	}()
 
+	if uc.toModule {
+		return updateModuleFile(c, rc)
+	}
+
 	// Fix the workspace file with each language.
 	for _, lang := range filterLanguages(c, languages) {
 		lang.Fix(c, uc.workspace)
@@ -204,9 +244,12 @@ func updateRepos(wd string, args []string) (err error) {
 
 	// Generate rules from command language arguments or by importing a file.
 	var gen, empty []*rule.Rule
-	if uc.repoFilePath == "" {
+	switch {
+	case uc.upgrade != "":
+		gen, err = upgradeRepos(c, rc)
+	case uc.repoFilePath == "":
 		gen, err = updateRepoImports(c, rc)
-	} else {
+	default:
 		gen, empty, err = importRepos(c, rc)
 	}
 	if err != nil {
@@ -393,11 +436,27 @@ gazelle update-repos example.com/repo1 example.com/repo2
 # Import repositories from lock file
 gazelle update-repos -from_file=file
 
+# Declare dependencies with the go_deps extension in MODULE.bazel
+gazelle update-repos -to_module -from_file=go.mod
+
+# Upgrade modules to the latest minor or patch versions
+gazelle update-repos -upgrade=minor -only='golang.org/x/**'
+
 The update-repos command updates repository rules in the WORKSPACE file.
 update-repos can add or update repositories explicitly by import path.
 update-repos can also import repository rules from a vendoring tool's lock
 file (currently only deps' Gopkg.lock is supported).
 
+With -to_module, update-repos edits MODULE.bazel instead. go.mod and go.work
+files are named in a go_deps.from_file tag; other repositories are declared
+with go_deps.module tags. Repositories referenced by build files are added
+to use_repo(go_deps, ...).
+
+With -upgrade, update-repos looks up newer versions of modules declared with
+go_repository rules, prints a table of planned upgrades and updates the
+rules. Modules required at newer versions by upgraded modules are upgraded,
+too, so the versions stay consistent.
+
 FLAGS:
 
 `)
@@ -0,0 +1,397 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	gzflag "github.com/bazelbuild/bazel-gazelle/flag"
+	"github.com/bazelbuild/bazel-gazelle/internal/module"
+	"github.com/bazelbuild/bazel-gazelle/internal/wspace"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/repo"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+type verifyReposConfig struct {
+	repoFilePath string
+	format       string
+}
+
+const verifyReposName = "_verify-repos"
+
+func getVerifyReposConfig(c *config.Config) *verifyReposConfig {
+	return c.Exts[verifyReposName].(*verifyReposConfig)
+}
+
+var _ config.Configurer = (*verifyReposConfigurer)(nil)
+
+type verifyReposConfigurer struct{}
+
+func (*verifyReposConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {
+	vc := &verifyReposConfig{format: "text"}
+	c.Exts[verifyReposName] = vc
+	fs.StringVar(&vc.repoFilePath, "from_file", "", "The go.mod or go.work file to verify repositories against. Defaults to go.mod in the repository root.")
+	fs.Var(&gzflag.AllowedStringFlag{Value: &vc.format, Allowed: []string{"text", "json"}}, "format", "Output format: text or json.")
+}
+
+func (*verifyReposConfigurer) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	vc := getVerifyReposConfig(c)
+	if len(fs.Args()) != 0 {
+		return fmt.Errorf("got %d positional arguments; wanted 0.\nTry -help for more information.", len(fs.Args()))
+	}
+	if vc.repoFilePath == "" {
+		vc.repoFilePath = filepath.Join(c.RepoRoot, "go.mod")
+	} else if !filepath.IsAbs(vc.repoFilePath) {
+		vc.repoFilePath = filepath.Join(c.WorkDir, vc.repoFilePath)
+	}
+
+	workspacePath := wspace.FindWORKSPACEFile(c.RepoRoot)
+	if _, err := os.Stat(workspacePath); os.IsNotExist(err) {
+		return nil
+	}
+	workspace, err := rule.LoadWorkspaceFile(workspacePath, "")
+	if err != nil {
+		return fmt.Errorf("loading WORKSPACE file: %v", err)
+	}
+	c.Repos, _, err = repo.ListRepositories(workspace)
+	if err != nil {
+		return fmt.Errorf("loading WORKSPACE file: %v", err)
+	}
+	return nil
+}
+
+func (*verifyReposConfigurer) KnownDirectives() []string { return nil }
+
+func (*verifyReposConfigurer) Configure(c *config.Config, rel string, f *rule.File) {}
+
+// Kinds of repoProblem.
+const (
+	repoMissing         = "missing"
+	repoExtra           = "extra"
+	repoVersionMismatch = "version_mismatch"
+	repoSumMismatch     = "sum_mismatch"
+)
+
+// repoProblem is a difference between a repository declaration and the
+// module graph. Problems are printed as JSON with -format=json.
+type repoProblem struct {
+	Kind string `json:"kind"`
+
+	// Source is "WORKSPACE" for go_repository rules, including those in
+	// repository macros, or "MODULE.bazel" for go_deps.module tags.
+	Source string `json:"source"`
+
+	Module string `json:"module"`
+
+	// Repo is the name of the go_repository rule. It's empty for go_deps
+	// tags and missing modules.
+	Repo string `json:"repo,omitempty"`
+
+	WantVersion string `json:"want_version,omitempty"`
+	GotVersion  string `json:"got_version,omitempty"`
+	WantReplace string `json:"want_replace,omitempty"`
+	GotReplace  string `json:"got_replace,omitempty"`
+	WantSum     string `json:"want_sum,omitempty"`
+	GotSum      string `json:"got_sum,omitempty"`
+}
+
+func (p repoProblem) String() string {
+	name := p.Module
+	if p.Repo != "" {
+		name = fmt.Sprintf("%s (%s)", p.Module, p.Repo)
+	}
+	switch p.Kind {
+	case repoMissing:
+		return fmt.Sprintf("%s: %s: missing; want version %s", p.Source, name, p.WantVersion)
+	case repoExtra:
+		return fmt.Sprintf("%s: %s: not in the module graph", p.Source, name)
+	case repoVersionMismatch:
+		want, got := p.WantVersion, p.GotVersion
+		if p.WantReplace != "" {
+			want = p.WantReplace + " " + want
+		}
+		if p.GotReplace != "" {
+			got = p.GotReplace + " " + got
+		}
+		if got == "" {
+			got = "none"
+		}
+		return fmt.Sprintf("%s: %s: version %s; want %s", p.Source, name, got, want)
+	default:
+		got := p.GotSum
+		if got == "" {
+			got = "none"
+		}
+		return fmt.Sprintf("%s: %s: sum %s; want %s", p.Source, name, got, p.WantSum)
+	}
+}
+
+// verifyRepos compares go_repository rules declared in WORKSPACE and
+// go_deps.module tags in MODULE.bazel against the module graph of a go.mod
+// or go.work file. Differences are printed, and errExit is returned if there
+// are any.
+func verifyRepos(wd string, args []string) error {
+	cexts := make([]config.Configurer, 0, len(languages)+2)
+	cexts = append(cexts, &config.CommonConfigurer{}, &verifyReposConfigurer{})
+	for _, lang := range languages {
+		cexts = append(cexts, lang)
+	}
+	c, err := newVerifyReposConfiguration(wd, args, cexts)
+	if err != nil {
+		return err
+	}
+	vc := getVerifyReposConfig(c)
+
+	want, err := loadModuleGraph(c, vc.repoFilePath)
+	if err != nil {
+		return err
+	}
+	goDeps := c.GoDeps
+
+	var problems []repoProblem
+	checkedWorkspace := false
+	for _, r := range c.Repos {
+		if r.Kind() == "go_repository" && !repo.IsFromDirective(r) {
+			checkedWorkspace = true
+			break
+		}
+	}
+	if checkedWorkspace {
+		problems = append(problems, verifyWorkspaceRepos(c.Repos, want)...)
+	}
+	if goDeps != nil {
+		rel, _ := filepath.Rel(c.RepoRoot, vc.repoFilePath)
+		rel = filepath.ToSlash(rel)
+		fromFile := false
+		for _, f := range append(append([]string(nil), goDeps.GoModFiles...), goDeps.GoWorkFiles...) {
+			if f == rel {
+				fromFile = true
+			}
+		}
+		problems = append(problems, verifyGoDepsModules(goDeps.Modules, want, fromFile)...)
+	} else if !checkedWorkspace {
+		return errors.New("no go_repository rules in WORKSPACE and no go_deps tags in MODULE.bazel to verify")
+	}
+
+	if err := printRepoProblems(os.Stdout, vc.format, problems); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errExit
+	}
+	return nil
+}
+
+// loadModuleGraph imports repositories from a go.mod or go.work file using
+// the first language that can, and returns the rules it generates, indexed
+// by importpath.
+func loadModuleGraph(c *config.Config, path string) (map[string]*rule.Rule, error) {
+	var importer language.RepoImporter
+	for _, lang := range filterLanguages(c, languages) {
+		if i, ok := lang.(language.RepoImporter); ok && i.CanImport(path) {
+			importer = i
+			break
+		}
+	}
+	if importer == nil {
+		return nil, fmt.Errorf("%s: unknown file format", path)
+	}
+	rc, cleanup := repo.NewRemoteCache(nil)
+	defer cleanup()
+	res := importer.ImportRepos(language.ImportReposArgs{
+		Config: c,
+		Path:   path,
+		Cache:  rc,
+	})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	byPath := make(map[string]*rule.Rule)
+	for _, r := range res.Gen {
+		byPath[r.AttrString("importpath")] = r
+	}
+	return byPath, nil
+}
+
+// verifyWorkspaceRepos compares go_repository rules against the module
+// graph. Every module in the graph should have a rule, and every rule
+// should match a module.
+func verifyWorkspaceRepos(repos []*rule.Rule, want map[string]*rule.Rule) []repoProblem {
+	var problems []repoProblem
+	seen := make(map[string]bool)
+	for _, r := range repos {
+		if r.Kind() != "go_repository" || repo.IsFromDirective(r) {
+			continue
+		}
+		importPath := r.AttrString("importpath")
+		seen[importPath] = true
+		p := repoProblem{Source: "WORKSPACE", Module: importPath, Repo: r.Name()}
+		w, ok := want[importPath]
+		if !ok {
+			p.Kind = repoExtra
+			problems = append(problems, p)
+			continue
+		}
+		if p, ok := compareModule(p, w, r.AttrString("version"), r.AttrString("replace"), r.AttrString("sum")); ok {
+			problems = append(problems, p)
+		}
+	}
+	problems = append(problems, missingModules("WORKSPACE", want, seen)...)
+	return problems
+}
+
+// verifyGoDepsModules compares go_deps.module tags against the module
+// graph. If fromFile is true, the file the graph was loaded from is named
+// in a go_deps.from_file tag, so modules without tags aren't missing.
+func verifyGoDepsModules(mods []module.GoDepsModule, want map[string]*rule.Rule, fromFile bool) []repoProblem {
+	var problems []repoProblem
+	seen := make(map[string]bool)
+	for _, m := range mods {
+		seen[m.Path] = true
+		p := repoProblem{Source: "MODULE.bazel", Module: m.Path}
+		w, ok := want[m.Path]
+		if !ok {
+			p.Kind = repoExtra
+			problems = append(problems, p)
+			continue
+		}
+		if p, ok := compareModule(p, w, m.Version, "", m.Sum); ok {
+			problems = append(problems, p)
+		}
+	}
+	if !fromFile {
+		problems = append(problems, missingModules("MODULE.bazel", want, seen)...)
+	}
+	return problems
+}
+
+// compareModule fills in p if the declared version, replacement, or sum
+// differ from the imported rule w. A sum mismatch is only reported if the
+// versions match.
+func compareModule(p repoProblem, w *rule.Rule, version, replace, sum string) (repoProblem, bool) {
+	wantVersion, wantReplace, wantSum := w.AttrString("version"), w.AttrString("replace"), w.AttrString("sum")
+	switch {
+	case version != wantVersion || replace != wantReplace:
+		p.Kind = repoVersionMismatch
+		p.WantVersion, p.GotVersion = wantVersion, version
+		p.WantReplace, p.GotReplace = wantReplace, replace
+	case sum != wantSum:
+		p.Kind = repoSumMismatch
+		p.WantVersion, p.GotVersion = wantVersion, version
+		p.WantSum, p.GotSum = wantSum, sum
+	default:
+		return p, false
+	}
+	return p, true
+}
+
+func missingModules(source string, want map[string]*rule.Rule, seen map[string]bool) []repoProblem {
+	var problems []repoProblem
+	for importPath, w := range want {
+		if !seen[importPath] {
+			problems = append(problems, repoProblem{
+				Kind:        repoMissing,
+				Source:      source,
+				Module:      importPath,
+				WantVersion: w.AttrString("version"),
+				WantReplace: w.AttrString("replace"),
+				WantSum:     w.AttrString("sum"),
+			})
+		}
+	}
+	return problems
+}
+
+func printRepoProblems(w io.Writer, format string, problems []repoProblem) error {
+	sort.Slice(problems, func(i, j int) bool {
+		if problems[i].Source != problems[j].Source {
+			return problems[i].Source > problems[j].Source // WORKSPACE first
+		}
+		return problems[i].Module < problems[j].Module
+	})
+	if format == "json" {
+		if problems == nil {
+			problems = []repoProblem{}
+		}
+		data, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	for _, p := range problems {
+		if _, err := fmt.Fprintln(w, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newVerifyReposConfiguration(wd string, args []string, cexts []config.Configurer) (*config.Config, error) {
+	c := config.New()
+	c.WorkDir = wd
+	fs := flag.NewFlagSet("gazelle", flag.ContinueOnError)
+	// Flag will call this on any parse error. Don't print usage unless
+	// -h or -help were passed explicitly.
+	fs.Usage = func() {}
+	for _, cext := range cexts {
+		cext.RegisterFlags(fs, "verify-repos", c)
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			verifyReposUsage(fs)
+			return nil, err
+		}
+		// flag already prints the error; don't print it again.
+		return nil, errors.New("Try -help for more information")
+	}
+	for _, cext := range cexts {
+		if err := cext.CheckFlags(fs, c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func verifyReposUsage(fs *flag.FlagSet) {
+	fmt.Fprint(os.Stderr, `usage: gazelle verify-repos [-from_file=go.mod] [-format=text|json]
+
+The verify-repos command checks that go_repository rules in the WORKSPACE
+file and go_deps.module tags in MODULE.bazel match the module graph of a
+go.mod or go.work file. It reports modules that are missing, extra, or
+declared with a different version or sum, then exits with a non-zero status
+if there were any.
+
+With -format=json, problems are printed as a JSON array of objects with
+"kind" ("missing", "extra", "version_mismatch" or "sum_mismatch"), "source",
+"module", and, where relevant, "repo", "want_version", "got_version",
+"want_replace", "got_replace", "want_sum" and "got_sum" fields.
+
+FLAGS:
+
+`)
+	fs.PrintDefaults()
+}
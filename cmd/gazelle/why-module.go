@@ -0,0 +1,303 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// depGraph is the graph of resolved dependencies between rules in the
+// repository. Labels of rules in the repository have an empty Repo.
+type depGraph struct {
+	// deps maps each rule in the repository to the labels it depends on or
+	// embeds, in the order they appear.
+	deps map[label.Label][]label.Label
+
+	// test is the set of rules that are tests or are marked testonly.
+	test map[label.Label]bool
+}
+
+// whyModuleTarget identifies the external repository whose users are
+// reported by why-module.
+type whyModuleTarget struct {
+	modPath, repoName string
+}
+
+func (t whyModuleTarget) String() string {
+	if t.modPath == "" {
+		return "@" + t.repoName
+	}
+	return fmt.Sprintf("%s (@%s)", t.modPath, t.repoName)
+}
+
+// findWhyModuleTarget interprets the why-module argument, which may be a
+// module path or a repository name starting with "@".
+func findWhyModuleTarget(uc *updateConfig, arg string) whyModuleTarget {
+	if strings.HasPrefix(arg, "@") {
+		t := whyModuleTarget{repoName: strings.TrimLeft(arg, "@")}
+		for _, r := range uc.repos {
+			if r.Name == t.repoName {
+				t.modPath = r.GoPrefix
+				break
+			}
+		}
+		return t
+	}
+	for _, r := range uc.repos {
+		if r.GoPrefix == arg {
+			return whyModuleTarget{modPath: arg, repoName: r.Name}
+		}
+	}
+	return whyModuleTarget{modPath: arg, repoName: label.ImportPathToBazelRepoName(arg)}
+}
+
+// buildDepGraph collects the resolved dependencies of all rules in the
+// visited build files. Attributes listed in ResolveAttrs of each kind are
+// treated as dependencies, along with labels the rule embeds.
+func buildDepGraph(c *config.Config, mrslv *metaResolver, kinds map[string]rule.KindInfo, visits []visitRecord) *depGraph {
+	g := &depGraph{
+		deps: make(map[label.Label][]label.Label),
+		test: make(map[label.Label]bool),
+	}
+	local := func(l label.Label) label.Label {
+		if l.Repo == c.RepoName || l.Repo == "@" {
+			l.Repo = ""
+		}
+		return l
+	}
+	for _, v := range visits {
+		kindInfo := unionKindInfoMaps(kinds, v.mappedKindInfo)
+		for _, r := range v.file.Rules {
+			from := label.New("", v.pkgRel, r.Name())
+			kind := r.Kind()
+			if underlying, ok := v.c.AliasMap[kind]; ok {
+				kind = underlying
+			}
+			if strings.HasSuffix(kind, "_test") || isTestOnly(r) {
+				g.test[from] = true
+			}
+			var deps []label.Label
+			for attr := range kindInfo[kind].ResolveAttrs {
+				for _, s := range r.AttrStrings(attr) {
+					if l, err := label.Parse(s); err == nil {
+						deps = append(deps, local(l.Abs("", v.pkgRel)))
+					}
+				}
+			}
+			if rslv := mrslv.Resolver(r, v.pkgRel); rslv != nil {
+				for _, l := range rslv.Embeds(r, from) {
+					deps = append(deps, local(l.Abs("", v.pkgRel)))
+				}
+			}
+			sort.Slice(deps, func(i, j int) bool { return deps[i].String() < deps[j].String() })
+			g.deps[from] = deps
+		}
+	}
+	return g
+}
+
+// isTestOnly returns whether r sets testonly = True.
+func isTestOnly(r *rule.Rule) bool {
+	ident, ok := r.Attr("testonly").(*build.Ident)
+	return ok && ident.Name == "True"
+}
+
+// whyModuleResult describes how rules in the repository depend on an
+// external repository.
+type whyModuleResult struct {
+	// importers are the rules that depend directly on a target in the
+	// external repository, and the targets they depend on.
+	importers []whyModuleImporter
+
+	// paths are the shortest dependency paths from each rule that depends on
+	// the repository, directly or indirectly. The last label in each path is
+	// in the external repository.
+	paths [][]label.Label
+
+	// testOnly is the set of rules that only depend on the repository
+	// through tests or testonly rules.
+	testOnly map[label.Label]bool
+}
+
+type whyModuleImporter struct {
+	from    label.Label
+	targets []label.Label
+}
+
+// explain finds the rules that depend on targets in the named repository.
+func (g *depGraph) explain(repoName string) *whyModuleResult {
+	res := &whyModuleResult{testOnly: make(map[label.Label]bool)}
+
+	// Find rules that depend on the repository directly.
+	var froms []label.Label
+	for from := range g.deps {
+		froms = append(froms, from)
+	}
+	sort.Slice(froms, func(i, j int) bool { return froms[i].String() < froms[j].String() })
+	rdeps := make(map[label.Label][]label.Label)
+	next := make(map[label.Label]label.Label)
+	var queue []label.Label
+	for _, from := range froms {
+		var targets []label.Label
+		for _, dep := range g.deps[from] {
+			if dep.Repo == repoName {
+				targets = append(targets, dep)
+			} else if dep.Repo == "" {
+				rdeps[dep] = append(rdeps[dep], from)
+			}
+		}
+		if len(targets) > 0 {
+			res.importers = append(res.importers, whyModuleImporter{from: from, targets: targets})
+			next[from] = targets[0]
+			queue = append(queue, from)
+		}
+	}
+
+	// Breadth-first search from the importers along reverse edges gives the
+	// shortest path from every rule that reaches the repository.
+	for len(queue) > 0 {
+		l := queue[0]
+		queue = queue[1:]
+		for _, from := range rdeps[l] {
+			if _, ok := next[from]; !ok {
+				next[from] = l
+				queue = append(queue, from)
+			}
+		}
+	}
+
+	// A rule needs the repository outside of tests if it reaches an importer
+	// without passing through a test rule.
+	nonTest := make(map[label.Label]bool)
+	queue = queue[:0]
+	for _, imp := range res.importers {
+		if !g.test[imp.from] {
+			nonTest[imp.from] = true
+			queue = append(queue, imp.from)
+		}
+	}
+	for len(queue) > 0 {
+		l := queue[0]
+		queue = queue[1:]
+		for _, from := range rdeps[l] {
+			if !nonTest[from] && !g.test[from] {
+				nonTest[from] = true
+				queue = append(queue, from)
+			}
+		}
+	}
+
+	var reached []label.Label
+	for l := range next {
+		reached = append(reached, l)
+	}
+	sort.Slice(reached, func(i, j int) bool { return reached[i].String() < reached[j].String() })
+	for _, l := range reached {
+		path := []label.Label{l}
+		for cur := l; cur.Repo == ""; {
+			cur = next[cur]
+			path = append(path, cur)
+		}
+		res.paths = append(res.paths, path)
+		if !nonTest[l] {
+			res.testOnly[l] = true
+		}
+	}
+	return res
+}
+
+// whyModule reports which rules in the repository depend on the module or
+// repository named on the command line. It's called by runFixUpdate after
+// dependencies are resolved, instead of writing build files.
+func whyModule(c *config.Config, ix *resolve.RuleIndex, mrslv *metaResolver, kinds map[string]rule.KindInfo, visits []visitRecord) error {
+	uc := getUpdateConfig(c)
+	target := findWhyModuleTarget(uc, uc.whyModule)
+	res := buildDepGraph(c, mrslv, kinds, visits).explain(target.repoName)
+	return printWhyModule(os.Stdout, c, ix, target, res)
+}
+
+func printWhyModule(w io.Writer, c *config.Config, ix *resolve.RuleIndex, target whyModuleTarget, res *whyModuleResult) error {
+	if len(res.importers) == 0 {
+		_, err := fmt.Fprintf(w, "no packages import %s\n", target)
+		return err
+	}
+	testOnly := func(l label.Label) string {
+		if res.testOnly[l] {
+			return " (test only)"
+		}
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "packages importing %s:\n", target)
+	for _, imp := range res.importers {
+		name := imp.from.String()
+		var importPaths []string
+		for _, spec := range ix.ImportedAs(label.New(c.RepoName, imp.from.Pkg, imp.from.Name)) {
+			importPaths = append(importPaths, spec.Imp)
+		}
+		if len(importPaths) > 0 {
+			name += " [" + strings.Join(importPaths, ", ") + "]"
+		}
+		targets := make([]string, len(imp.targets))
+		for i, t := range imp.targets {
+			targets[i] = t.String()
+		}
+		fmt.Fprintf(&b, "\t%s imports %s%s\n", name, strings.Join(targets, ", "), testOnly(imp.from))
+	}
+
+	b.WriteString("\nshortest paths:\n")
+	for _, path := range res.paths {
+		labels := make([]string, len(path))
+		for i, l := range path {
+			labels[i] = l.String()
+		}
+		fmt.Fprintf(&b, "\t%s%s\n", strings.Join(labels, " -> "), testOnly(path[0]))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func whyModuleUsage(fs *flag.FlagSet) {
+	fmt.Fprint(os.Stderr, `usage: gazelle why-module [flags...] module-path|@repo
+
+The why-module command explains why an external module is needed. It
+generates and resolves dependencies of rules in the whole repository the same
+way update does, without writing any files. Then it lists the rules that
+depend directly on targets in the module's repository, and the shortest
+dependency path from each rule in the repository that depends on it,
+directly or indirectly.
+
+The module may be named by its module path or by its repository name,
+starting with "@". Rules that only need the module through tests or
+testonly rules are marked "(test only)".
+
+FLAGS:
+
+`)
+	fs.PrintDefaults()
+}
@@ -99,7 +99,7 @@ func moveLabelsInDir(c *configuration) ([]*build.File, error) {
 			errors = append(errors, err)
 			return nil
 		}
-		moveLabelsInFile(file, c.from, toRel)
+		moveLabelsInFile(file, c.from, toRel, c.repoMapping)
 		files = append(files, file)
 		return nil
 	})
@@ -112,7 +112,7 @@ func moveLabelsInDir(c *configuration) ([]*build.File, error) {
 	return files, nil
 }
 
-func moveLabelsInFile(file *build.File, from, to string) {
+func moveLabelsInFile(file *build.File, from, to string, repoMapping *label.RepoMapping) {
 	build.Edit(file, func(x build.Expr, _ []build.Expr) build.Expr {
 		str, ok := x.(*build.StringExpr)
 		if !ok {
@@ -121,9 +121,9 @@ func moveLabelsInFile(file *build.File, from, to string) {
 		label := str.Value
 		var moved string
 		if strings.Contains(label, "$(location") {
-			moved = moveLocations(from, to, label)
+			moved = moveLocations(from, to, label, repoMapping)
 		} else {
-			moved = moveLabel(from, to, label)
+			moved = moveLabel(from, to, label, repoMapping)
 		}
 		if moved == label {
 			return nil
@@ -132,14 +132,20 @@ func moveLabelsInFile(file *build.File, from, to string) {
 	})
 }
 
-func moveLabel(from, to, str string) string {
+// moveLabel moves a label in the original repository from one directory to
+// another. Labels in the original repository may name it explicitly with an
+// apparent or canonical name; repoMapping tells which names refer to it.
+func moveLabel(from, to, str string, repoMapping *label.RepoMapping) string {
 	l, err := label.Parse(str)
 	if err != nil {
 		return str
 	}
-	if l.Relative || l.Repo != "" ||
+	canonical, ok := repoMapping.Canonical(l)
+	fromPattern := label.Pattern{Pkg: from, Recursive: true}
+	toPattern := label.Pattern{Pkg: to, Recursive: true}
+	if l.Relative || !ok || canonical.Repo != "@" ||
 		l.Pkg == "visibility" || l.Pkg == "conditions" ||
-		pathtools.HasPrefix(l.Pkg, to) || !pathtools.HasPrefix(l.Pkg, from) {
+		toPattern.MatchesPackage(l.Pkg) || !fromPattern.MatchesPackage(l.Pkg) {
 		return str
 	}
 	l.Pkg = path.Join(to, pathtools.TrimPrefix(l.Pkg, from))
@@ -149,14 +155,14 @@ func moveLabel(from, to, str string) string {
 var locationsRegexp = regexp.MustCompile(`\$\(locations?\s*([^)]*)\)`)
 
 // moveLocations fixes labels within $(location) and $(locations) expansions.
-func moveLocations(from, to, str string) string {
+func moveLocations(from, to, str string, repoMapping *label.RepoMapping) string {
 	matches := locationsRegexp.FindAllStringSubmatchIndex(str, -1)
 	buf := new(bytes.Buffer)
 	pos := 0
 	for _, match := range matches {
 		buf.WriteString(str[pos:match[2]])
 		label := str[match[2]:match[3]]
-		moved := moveLabel(from, to, label)
+		moved := moveLabel(from, to, label, repoMapping)
 		buf.WriteString(moved)
 		buf.WriteString(str[match[3]:match[1]])
 		pos = match[1]
@@ -178,6 +184,10 @@ type configuration struct {
 	// to is the new location of the build files, formatted as an absolute
 	// file system path.
 	to string
+
+	// repoMapping translates repository names in labels in the original
+	// repository. It may be nil.
+	repoMapping *label.RepoMapping
 }
 
 func newConfiguration(args []string) (*configuration, error) {
@@ -188,6 +198,8 @@ func newConfiguration(args []string) (*configuration, error) {
 	fs.StringVar(&c.repoRoot, "repo_root", "", "repository root directory; inferred to be parent directory containing WORKSPACE file")
 	fs.StringVar(&c.from, "from", "", "original location of build files, formatted as a slash-separated relative path from the original repository root")
 	fs.StringVar(&c.to, "to", "", "new location of build files, formatted as a file system path")
+	var repoMappingPath string
+	fs.StringVar(&repoMappingPath, "repo_mapping", "", "path to a file written by 'bazel mod dump_repo_mapping \"\"' in the original repository, used to recognize labels that name it explicitly")
 	if err := fs.Parse(args); err != nil {
 		if err == flag.ErrHelp {
 			fmt.Fprint(os.Stderr, usageMessage)
@@ -217,6 +229,13 @@ func newConfiguration(args []string) (*configuration, error) {
 		return nil, err
 	}
 
+	if repoMappingPath != "" {
+		c.repoMapping, err = label.ReadRepoMapping(repoMappingPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if len(fs.Args()) != 0 {
 		return nil, errors.New("No positional arguments expected. Try -help for more information.")
 	}
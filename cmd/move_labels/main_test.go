@@ -16,6 +16,7 @@ limitations under the License.
 package main
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -24,8 +25,8 @@ import (
 
 func TestMoveLabels(t *testing.T) {
 	for _, tc := range []struct {
-		desc, from, to string
-		files, want    []testtools.FileSpec
+		desc, from, to, repoMapping string
+		files, want                 []testtools.FileSpec
 	}{
 		{
 			desc: "move",
@@ -199,6 +200,39 @@ go_test(
 `,
 				},
 			},
+		}, {
+			desc:        "repo_mapping",
+			from:        "old",
+			to:          "new",
+			repoMapping: `{"":"","my_repo":"","c":"c+"}`,
+			files: []testtools.FileSpec{{
+				Path: "new/a/BUILD",
+				Content: `load("@my_repo//old:def.bzl", "x_binary")
+
+x_binary(
+    name = "a",
+    deps = [
+        "@@//old/b:b_lib",
+        "@c//old:c_lib",
+        "@my_repo//old/b",
+    ],
+)
+`,
+			}},
+			want: []testtools.FileSpec{{
+				Path: "new/a/BUILD",
+				Content: `load("@my_repo//new:def.bzl", "x_binary")
+
+x_binary(
+    name = "a",
+    deps = [
+        "@@//new/b:b_lib",
+        "@c//old:c_lib",
+        "@my_repo//new/b",
+    ],
+)
+`,
+			}},
 		},
 	} {
 		t.Run(tc.desc, func(t *testing.T) {
@@ -206,6 +240,13 @@ go_test(
 			defer cleanup()
 
 			args := []string{"-repo_root", dir, "-from", tc.from, "-to", filepath.Join(dir, filepath.FromSlash(tc.to))}
+			if tc.repoMapping != "" {
+				repoMappingPath := filepath.Join(dir, "repo_mapping.json")
+				if err := os.WriteFile(repoMappingPath, []byte(tc.repoMapping), 0o666); err != nil {
+					t.Fatal(err)
+				}
+				args = append(args, "-repo_mapping", repoMappingPath)
+			}
 			if err := run(args); err != nil {
 				t.Fatal(err)
 			}
@@ -32,11 +32,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/internal/module"
 	"github.com/bazelbuild/bazel-gazelle/internal/wspace"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
 )
 
 // Config holds information about how Gazelle should run. This is based on
@@ -102,6 +105,21 @@ type Config struct {
 	// the attrs for the macro calls. Configured via # gazelle:macro.
 	AliasMap map[string]string
 
+	// KindInfos declares or extends metadata for kinds of rules, which tells
+	// Gazelle how to match and merge them. Configured via # gazelle:kind_info.
+	// Values are combined with metadata provided by languages using
+	// rule.KindInfo.Union.
+	KindInfos map[string]rule.KindInfo
+
+	// DefaultAttrs maps kinds of rules to attribute values Gazelle sets on
+	// generated rules of those kinds. Configured via # gazelle:default_attr
+	// and # gazelle:enforce_attr.
+	DefaultAttrs map[string]map[string]DefaultAttr
+
+	// LabelStyle is how labels of targets in this repository are written in
+	// attributes of merged rules. Configured via # gazelle:label_style.
+	LabelStyle label.Style
+
 	// Repos is a list of repository rules declared in the main WORKSPACE file
 	// or in macros called by the main WORKSPACE file. This may affect rule
 	// generation and dependency resolution.
@@ -124,6 +142,26 @@ type Config struct {
 	// to the apparent name (repo_name) specified in the MODULE.bazel file. It
 	// returns the empty string if the module is not found.
 	ModuleToApparentName func(string) string
+
+	// ModuleFiles contains the main MODULE.bazel file and the segments it
+	// includes, parsed once so they may be read by each language. It's nil
+	// if MODULE.bazel doesn't exist. The files should not be modified.
+	ModuleFiles []*bzl.File
+
+	// GoDeps holds the go_deps tags and use_repo calls in ModuleFiles. It's
+	// nil if MODULE.bazel doesn't use go_deps.
+	GoDeps *module.GoDeps
+
+	// GoDepsRepos lists the repositories go_deps creates for modules
+	// required by the main module. The go.mod and go.work files named in
+	// GoDeps are read once, when flags are checked.
+	GoDepsRepos []module.GoDepsRepo
+
+	// RepoMapping translates between apparent and canonical names of
+	// repositories visible from the main repository. It's read from the file
+	// named with -repo_mapping or computed from MODULE.bazel. It's nil if
+	// neither is available.
+	RepoMapping *label.RepoMapping
 }
 
 // MappedKind describes a replacement to use for a built-in kind.
@@ -131,6 +169,18 @@ type MappedKind struct {
 	FromKind, KindName, KindLoad string
 }
 
+// DefaultAttr describes a value set on an attribute of generated rules.
+type DefaultAttr struct {
+	// Expr is the attribute value. It's shared by configurations of
+	// subdirectories, so it must be copied before it's set on a rule.
+	Expr bzl.Expr
+
+	// Enforce indicates the value should replace the value of the attribute
+	// in existing rules. Otherwise, the value is only set on rules Gazelle
+	// creates.
+	Enforce bool
+}
+
 func New() *Config {
 	return &Config{
 		ValidBuildFileNames: DefaultValidBuildFileNames,
@@ -151,6 +201,21 @@ func (c *Config) Clone() *Config {
 	for k, v := range c.KindMap {
 		cc.KindMap[k] = v
 	}
+	if c.KindInfos != nil {
+		cc.KindInfos = make(map[string]rule.KindInfo, len(c.KindInfos))
+		for k, v := range c.KindInfos {
+			cc.KindInfos[k] = v
+		}
+	}
+	if c.DefaultAttrs != nil {
+		cc.DefaultAttrs = make(map[string]map[string]DefaultAttr, len(c.DefaultAttrs))
+		for kind, attrs := range c.DefaultAttrs {
+			cc.DefaultAttrs[kind] = make(map[string]DefaultAttr, len(attrs))
+			for k, v := range attrs {
+				cc.DefaultAttrs[kind][k] = v
+			}
+		}
+	}
 	return &cc
 }
 
@@ -215,6 +280,7 @@ type CommonConfigurer struct {
 	indexLibraries, indexLazy, strict bool
 	langCsv                           string
 	bzlmod                            bool
+	repoMappingPath                   string
 }
 
 func (cc *CommonConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *Config) {
@@ -225,6 +291,7 @@ func (cc *CommonConfigurer) RegisterFlags(fs *flag.FlagSet, cmd string, c *Confi
 	fs.BoolVar(&cc.strict, "strict", false, "when true, gazelle will exit with none-zero value for build file syntax errors or unknown directives")
 	fs.StringVar(&cc.langCsv, "lang", "", "if non-empty, process only these languages (e.g. \"go,proto\")")
 	fs.BoolVar(&cc.bzlmod, "bzlmod", false, "for internal usage only")
+	fs.StringVar(&cc.repoMappingPath, "repo_mapping", "", "path to a file written by 'bazel mod dump_repo_mapping \"\"', used to translate canonical repository names. If unset, the mapping is computed from MODULE.bazel.")
 }
 
 func (cc *CommonConfigurer) CheckFlags(fs *flag.FlagSet, c *Config) error {
@@ -261,15 +328,35 @@ func (cc *CommonConfigurer) CheckFlags(fs *flag.FlagSet, c *Config) error {
 		c.Langs = strings.Split(cc.langCsv, ",")
 	}
 	c.Bzlmod = cc.bzlmod
-	c.ModuleToApparentName, err = module.ExtractModuleToApparentNameMapping(c.RepoRoot)
+	c.ModuleFiles, err = module.ParseModuleFiles(c.RepoRoot)
 	if err != nil {
 		return fmt.Errorf("failed to parse MODULE.bazel: %v", err)
 	}
+	c.ModuleToApparentName = module.ModuleToApparentNameMapping(c.ModuleFiles)
+	if c.GoDeps, err = module.ExtractGoDeps(c.ModuleFiles); err != nil {
+		return fmt.Errorf("failed to parse MODULE.bazel: %v", err)
+	}
+	if c.GoDeps != nil {
+		if c.GoDepsRepos, err = c.GoDeps.Repos(c.RepoRoot); err != nil {
+			return fmt.Errorf("failed to read go_deps modules: %v", err)
+		}
+	}
+	if cc.repoMappingPath != "" {
+		path := cc.repoMappingPath
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.WorkDir, path)
+		}
+		if c.RepoMapping, err = label.ReadRepoMapping(path); err != nil {
+			return fmt.Errorf("failed to read repository mapping: %v", err)
+		}
+	} else if c.RepoMapping, err = module.ExtractRepoMapping(c.RepoRoot, c.ModuleFiles); err != nil {
+		return fmt.Errorf("failed to parse MODULE.bazel: %v", err)
+	}
 	return nil
 }
 
 func (cc *CommonConfigurer) KnownDirectives() []string {
-	return []string{"map_kind", "alias_kind", "lang"}
+	return []string{"map_kind", "alias_kind", "kind_info", "default_attr", "enforce_attr", "label_style", "lang"}
 }
 
 func (cc *CommonConfigurer) Configure(c *Config, rel string, f *rule.File) {
@@ -312,6 +399,43 @@ func (cc *CommonConfigurer) Configure(c *Config, rel string, f *rule.File) {
 			}
 			c.AliasMap[aliasName] = underlyingKind
 
+		case "kind_info":
+			kind, info, err := parseKindInfo(d.Value)
+			if err != nil {
+				log.Printf("%s: gazelle:kind_info %s: %v", f.Path, d.Value, err)
+				continue
+			}
+			if c.KindInfos == nil {
+				c.KindInfos = make(map[string]rule.KindInfo)
+			}
+			c.KindInfos[kind] = c.KindInfos[kind].Union(info)
+
+		case "default_attr", "enforce_attr":
+			kind, attr, expr, err := parseDefaultAttr(d.Value)
+			if err != nil {
+				log.Printf("%s: gazelle:%s %s: %v", f.Path, d.Key, d.Value, err)
+				continue
+			}
+			if c.DefaultAttrs == nil {
+				c.DefaultAttrs = make(map[string]map[string]DefaultAttr)
+			}
+			if expr == nil {
+				delete(c.DefaultAttrs[kind], attr)
+				continue
+			}
+			if c.DefaultAttrs[kind] == nil {
+				c.DefaultAttrs[kind] = make(map[string]DefaultAttr)
+			}
+			c.DefaultAttrs[kind][attr] = DefaultAttr{Expr: expr, Enforce: d.Key == "enforce_attr"}
+
+		case "label_style":
+			style, err := label.ParseStyle(d.Value)
+			if err != nil {
+				log.Printf("%s: gazelle:label_style: %v", f.Path, err)
+				continue
+			}
+			c.LabelStyle = style
+
 		case "lang":
 			if len(d.Value) > 0 {
 				c.Langs = strings.Split(d.Value, ",")
@@ -322,6 +446,83 @@ func (cc *CommonConfigurer) Configure(c *Config, rel string, f *rule.File) {
 	}
 }
 
+// parseKindInfo parses the value of a kind_info directive: a rule kind
+// followed by key=value pairs. Keys other than match_any take
+// comma-separated lists of attribute names.
+func parseKindInfo(value string) (string, rule.KindInfo, error) {
+	var info rule.KindInfo
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return "", info, fmt.Errorf("expected a kind followed by key=value pairs (gazelle:kind_info kind mergeable=attr,...)")
+	}
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return "", info, fmt.Errorf("%q: expected key=value", field)
+		}
+		var attrs []string
+		for _, attr := range strings.Split(value, ",") {
+			if attr != "" {
+				attrs = append(attrs, attr)
+			}
+		}
+		set := func() map[string]bool {
+			m := make(map[string]bool, len(attrs))
+			for _, attr := range attrs {
+				m[attr] = true
+			}
+			return m
+		}
+		switch key {
+		case "mergeable":
+			info.MergeableAttrs = set()
+		case "resolve":
+			info.ResolveAttrs = set()
+		case "nonempty":
+			info.NonEmptyAttrs = set()
+		case "substitute":
+			info.SubstituteAttrs = set()
+		case "match":
+			info.MatchAttrs = attrs
+		case "match_any":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return "", info, fmt.Errorf("match_any: %v", err)
+			}
+			info.MatchAny = b
+		default:
+			return "", info, fmt.Errorf("unknown key %q; expected mergeable, resolve, nonempty, substitute, match, or match_any", key)
+		}
+	}
+	return fields[0], info, nil
+}
+
+// parseDefaultAttr parses the value of a default_attr or enforce_attr
+// directive: a rule kind, an attribute name, and a Starlark expression, which
+// may contain spaces. The expression is nil if the directive clears the
+// value.
+func parseDefaultAttr(value string) (kind, attr string, expr bzl.Expr, err error) {
+	kind, rest, _ := strings.Cut(strings.TrimSpace(value), " ")
+	attr, exprStr, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	if kind == "" || attr == "" {
+		return "", "", nil, fmt.Errorf("expected a kind, an attribute name, and a value (gazelle:default_attr kind attr value)")
+	}
+	if exprStr = strings.TrimSpace(exprStr); exprStr == "" {
+		return kind, attr, nil, nil
+	}
+	f, err := bzl.ParseBuild("", []byte(exprStr))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("parsing value: %v", err)
+	}
+	if len(f.Stmt) != 1 {
+		return "", "", nil, fmt.Errorf("value must be a single expression")
+	}
+	if _, ok := f.Stmt[0].(*bzl.AssignExpr); ok {
+		return "", "", nil, fmt.Errorf("value must be an expression, not an assignment")
+	}
+	return kind, attr, f.Stmt[0], nil
+}
+
 type indexFlag struct {
 	indexLibraries, indexLazy *bool
 }
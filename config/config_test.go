@@ -23,6 +23,7 @@ import (
 	"testing"
 
 	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
 )
 
 func TestCommonConfigurerFlags(t *testing.T) {
@@ -150,3 +151,73 @@ func TestCommonConfigurerRepoName(t *testing.T) {
 		})
 	}
 }
+
+func TestCommonConfigurerKindInfo(t *testing.T) {
+	c := New()
+	cc := &CommonConfigurer{}
+	buildData := []byte(`# gazelle:kind_info my_library mergeable=extra_srcs resolve=runtime_deps
+# gazelle:kind_info my_library nonempty=srcs,extra_srcs match=importpath match_any=true
+# gazelle:kind_info my_library bogus=x
+# gazelle:kind_info my_library
+`)
+	f, err := rule.LoadData(filepath.Join("test", "BUILD.bazel"), "", buildData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.Configure(c, "", f)
+
+	want := map[string]rule.KindInfo{
+		"my_library": {
+			MatchAny:       true,
+			MatchAttrs:     []string{"importpath"},
+			NonEmptyAttrs:  map[string]bool{"srcs": true, "extra_srcs": true},
+			MergeableAttrs: map[string]bool{"extra_srcs": true},
+			ResolveAttrs:   map[string]bool{"runtime_deps": true},
+		},
+	}
+	if !reflect.DeepEqual(c.KindInfos, want) {
+		t.Errorf("for KindInfos, got %#v, want %#v", c.KindInfos, want)
+	}
+}
+
+func TestCommonConfigurerDefaultAttr(t *testing.T) {
+	c := New()
+	cc := &CommonConfigurer{}
+	buildData := []byte(`# gazelle:default_attr go_test size "small"
+# gazelle:enforce_attr go_binary pure "on"
+# gazelle:default_attr go_binary tags [ "manual",  "exclusive" ]
+# gazelle:default_attr go_test timeout "short"
+# gazelle:default_attr go_test timeout
+# gazelle:default_attr go_test
+# gazelle:default_attr go_test bad x = 1
+`)
+	f, err := rule.LoadData(filepath.Join("test", "BUILD.bazel"), "", buildData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.Configure(c, "", f)
+
+	type defaultAttr struct {
+		Value   string
+		Enforce bool
+	}
+	want := map[string]map[string]defaultAttr{
+		"go_test": {
+			"size": {Value: `"small"`},
+		},
+		"go_binary": {
+			"pure": {Value: `"on"`, Enforce: true},
+			"tags": {Value: "[\n    \"manual\",\n    \"exclusive\",\n]"},
+		},
+	}
+	got := make(map[string]map[string]defaultAttr)
+	for kind, attrs := range c.DefaultAttrs {
+		got[kind] = make(map[string]defaultAttr)
+		for key, attr := range attrs {
+			got[kind][key] = defaultAttr{Value: bzl.FormatString(attr.Expr), Enforce: attr.Enforce}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("for DefaultAttrs, got %#v, want %#v", got, want)
+	}
+}
@@ -212,6 +212,9 @@ def _go_repository_impl(ctx):
             "-importpath=" + fetch_path,
             "-version=" + ctx.attr.version,
             "-sum=" + ctx.attr.sum,
+            # Modules are fetched from GOPROXY without the go command when
+            # possible. Private modules and GOPROXY=direct still need it.
+            "-go_mod_download",
         ]
     else:
         fail("one of urls, commit, tag, or version must be specified")
@@ -275,6 +278,14 @@ def _go_repository_impl(ctx):
 
     env.update({k: ctx.os.environ[k] for k in env_keys if k in ctx.os.environ})
 
+    # Apply patches that fetch_repo applies itself. These are applied before
+    # existing build files are cleaned and before Gazelle runs.
+    if ctx.attr.fetch_patches:
+        for patch_file in ctx.attr.fetch_patches:
+            fetch_repo_args.append("-patch=" + str(ctx.path(patch_file)))
+        fetch_repo_args.append("-patch_strip=%d" % ctx.attr.fetch_patch_strip)
+        fetch_repo_args.append("-patch_fuzz=%d" % ctx.attr.fetch_patch_fuzz)
+
     # Clean existing build files if requested
     if ctx.attr.build_file_generation == "clean":
         fetch_repo_args.append("-clean")
@@ -661,6 +672,24 @@ go_repository = repository_rule(
             Gazelle directives.""",
         ),
 
+        # Patches to apply with fetch_repo before running gazelle.
+        "fetch_patches": attr.label_list(
+            doc = """A list of unified diffs that `fetch_repo` applies to the downloaded files,
+            in order, before existing build files are removed in `"clean"` mode and
+            before Gazelle runs. Git-style renames, creations, and deletions are
+            supported. If a hunk doesn't apply, the error names the file and hunk and
+            shows the lines around where it was expected.""",
+        ),
+        "fetch_patch_strip": attr.int(
+            default = 0,
+            doc = "The number of leading path components to strip from file names in `fetch_patches`, like `patch -p`.",
+        ),
+        "fetch_patch_fuzz": attr.int(
+            default = 0,
+            doc = """The number of context lines at the start and end of each hunk in `fetch_patches`
+            that may be ignored if they don't match, like `patch --fuzz`.""",
+        ),
+
         # Patches to apply after running gazelle.
         "patches": attr.label_list(
             doc = "A list of patches to apply to the repository after gazelle runs.",
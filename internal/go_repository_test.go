@@ -94,6 +94,15 @@ go_repository(
     sum ="h1:iURUrRGxPUNPdy5/HRSm+Yj6okJ6UtLINN0Q9M4+h3I=",
 )
 
+go_repository(
+    name = "errors_go_mod_fetch_patched",
+    importpath = "github.com/pkg/errors",
+    version = "v0.8.1",
+    sum ="h1:iURUrRGxPUNPdy5/HRSm+Yj6okJ6UtLINN0Q9M4+h3I=",
+    fetch_patches = ["@bazel_gazelle//internal:repository_rules_test_fetch_patch.patch"],
+    fetch_patch_strip = 1,
+)
+
 go_repository(
     name = "org_golang_x_xerrors",    
 		importpath = "golang.org/x/xerrors",
@@ -162,6 +171,11 @@ go_repository(
     importpath = "github.com/pkg/errors",
 )
 
+go_repository(
+    name = "errors_go_mod_fetch_patched",
+    importpath = "github.com/pkg/errors",
+)
+
 http_archive(
     name = "io_bazel_rules_go",
     urls = [
@@ -179,6 +193,18 @@ go_repository(
 	})
 }
 
+func TestFetchPatches(t *testing.T) {
+	// The patch adds a source file before Gazelle runs, so it's listed in
+	// the generated library.
+	out, err := bazel_testing.BazelOutput("query", "--enable_workspace", "labels(srcs, @errors_go_mod_fetch_patched//:errors)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "patched.go") {
+		t.Errorf("generated library does not contain patched.go; srcs are:\n%s", out)
+	}
+}
+
 func TestModcacheRW(t *testing.T) {
 	if err := bazel_testing.RunBazel("query", "--enable_workspace", "@errors_go_mod//:go_default_library"); err != nil {
 		t.Fatal(err)
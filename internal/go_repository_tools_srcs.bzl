@@ -14,7 +14,9 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//cmd/fetch_repo:go_mod_download.go"),
     Label("//cmd/fetch_repo:main.go"),
     Label("//cmd/fetch_repo:module.go"),
+    Label("//cmd/fetch_repo:patch.go"),
     Label("//cmd/fetch_repo:path.go"),
+    Label("//cmd/fetch_repo:proxy.go"),
     Label("//cmd/fetch_repo:vcs.go"),
     Label("//cmd/gazelle:BUILD.bazel"),
     Label("//cmd/gazelle:diff.go"),
@@ -23,9 +25,16 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//cmd/gazelle:langs.go"),
     Label("//cmd/gazelle:main.go"),
     Label("//cmd/gazelle:metaresolver.go"),
+    Label("//cmd/gazelle:migrate-bzlmod.go"),
+    Label("//cmd/gazelle:move.go"),
     Label("//cmd/gazelle:print.go"),
     Label("//cmd/gazelle:profiler.go"),
+    Label("//cmd/gazelle:remote-cache.go"),
+    Label("//cmd/gazelle:update-repos-module.go"),
+    Label("//cmd/gazelle:update-repos-upgrade.go"),
     Label("//cmd/gazelle:update-repos.go"),
+    Label("//cmd/gazelle:verify-repos.go"),
+    Label("//cmd/gazelle:why-module.go"),
     Label("//cmd/generate_repo_config:BUILD.bazel"),
     Label("//cmd/generate_repo_config:main.go"),
     Label("//cmd/move_labels:BUILD.bazel"),
@@ -42,6 +51,11 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//internal/gazellebinarytest:BUILD.bazel"),
     Label("//internal/gazellebinarytest:xlang.go"),
     Label("//internal/generationtest:BUILD.bazel"),
+    Label("//internal/goproxy:BUILD.bazel"),
+    Label("//internal/goproxy:goproxy.go"),
+    Label("//internal/goproxy/goproxytest:BUILD.bazel"),
+    Label("//internal/goproxy/goproxytest:goproxytest.go"),
+    Label("//internal/goproxy:sumdb.go"),
     Label("//internal/language:BUILD.bazel"),
     Label("//internal/language/test_filegroup:BUILD.bazel"),
     Label("//internal/language/test_filegroup:lang.go"),
@@ -51,13 +65,17 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//internal/language/test_loads_from_flag:lang.go"),
     Label("//internal:list_repository_tools_srcs.go"),
     Label("//internal/module:BUILD.bazel"),
+    Label("//internal/module:go_deps.go"),
     Label("//internal/module:module.go"),
+    Label("//internal/module:repo_mapping.go"),
     Label("//internal/version:BUILD.bazel"),
     Label("//internal/version:version.go"),
     Label("//internal/wspace:BUILD.bazel"),
     Label("//internal/wspace:finder.go"),
     Label("//label:BUILD.bazel"),
     Label("//label:label.go"),
+    Label("//label:pattern.go"),
+    Label("//label:repo_mapping.go"),
     Label("//language:BUILD.bazel"),
     Label("//language:base.go"),
     Label("//language/bazel:BUILD.bazel"),
@@ -78,6 +96,7 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//language/go:kinds.go"),
     Label("//language/go:lang.go"),
     Label("//language/go:modules.go"),
+    Label("//language/go:modules_offline.go"),
     Label("//language/go:package.go"),
     Label("//language/go:platform_info.go"),
     Label("//language/go/platform_info_generator:BUILD.bazel"),
@@ -85,8 +104,10 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//language/go:resolve.go"),
     Label("//language/go:std_package_list.go"),
     Label("//language/go:stdlib_links.go"),
+    Label("//language/go:tools.go"),
     Label("//language/go:update.go"),
     Label("//language/go:utils.go"),
+    Label("//language/go:vendor.go"),
     Label("//language/go:work.go"),
     Label("//language:lang.go"),
     Label("//language:lifecycle.go"),
@@ -103,15 +124,19 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//language/proto:known_imports.go"),
     Label("//language/proto:known_proto_imports.go"),
     Label("//language/proto:lang.go"),
+    Label("//language/proto:lint.go"),
     Label("//language/proto:package.go"),
     Label("//language/proto:resolve.go"),
     Label("//language:update.go"),
     Label("//merger:BUILD.bazel"),
     Label("//merger:fix.go"),
     Label("//merger:merger.go"),
+    Label("//merger:rename.go"),
+    Label("//merger:state.go"),
     Label("//pathtools:BUILD.bazel"),
     Label("//pathtools:path.go"),
     Label("//repo:BUILD.bazel"),
+    Label("//repo:persist.go"),
     Label("//repo:remote.go"),
     Label("//repo:repo.go"),
     Label("//resolve:BUILD.bazel"),
@@ -120,6 +145,7 @@ GO_REPOSITORY_TOOLS_SRCS = [
     Label("//rule:BUILD.bazel"),
     Label("//rule:directives.go"),
     Label("//rule:expr.go"),
+    Label("//rule:format.go"),
     Label("//rule:merge.go"),
     Label("//rule:platform.go"),
     Label("//rule:platform_strings.go"),
@@ -0,0 +1,535 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package goproxy is a client for the module proxy protocol used by Gazelle
+// and fetch_repo to find, download, and hash Go modules without the go
+// command.
+package goproxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// MaxZipFile is the largest module zip file that may be downloaded, matching
+// the limit enforced by the go command in golang.org/x/mod/zip.
+const MaxZipFile = 500 << 20
+
+// ErrUseDirect is returned by Client methods when GOPROXY, GONOPROXY, or
+// GOPRIVATE say a module should be fetched directly from version control.
+// Client can't do that itself, so callers fall back to the go command or
+// version control.
+var ErrUseDirect = errors.New("module must be fetched directly from version control")
+
+// Timeouts for requests to proxies and checksum databases, so a stalled
+// server can't block Gazelle forever. Zip files may be large, so they're
+// given longer.
+const (
+	requestTimeout = time.Minute
+	zipTimeout     = 10 * time.Minute
+)
+
+// ErrNotFound is returned by a single proxy when it doesn't have the
+// requested module or version. The next proxy in GOPROXY is tried.
+var ErrNotFound = errors.New("not found")
+
+// Client is a client for the module proxy protocol, described at
+// https://go.dev/ref/mod#goproxy-protocol. It reads GOPROXY, GONOPROXY,
+// GONOSUMDB, GOPRIVATE, and GOSUMDB the same way the go command does,
+// including values set with "go env -w".
+type Client struct {
+	proxies []proxyEntry
+
+	// noProxy and noSumDB are comma-separated lists of module path prefix
+	// patterns from GONOPROXY and GONOSUMDB. Both default to GOPRIVATE.
+	noProxy, noSumDB string
+
+	// sumDB verifies hashes with the checksum database named by GOSUMDB.
+	// It's nil if GOSUMDB is "off".
+	sumDB *sumdb.Client
+
+	// client is used for most requests. zipClient is used to download zip
+	// files.
+	client, zipClient *http.Client
+}
+
+// proxyEntry is a single element of GOPROXY.
+type proxyEntry struct {
+	// url is the base URL of the proxy, or "direct" or "off".
+	url string
+
+	// fallBackOnError is true if the entry is followed by "|", meaning the
+	// next entry should be tried after any error, not only after a not found
+	// response.
+	fallBackOnError bool
+}
+
+// proxyInfo is the JSON object returned by .info and @latest requests.
+type proxyInfo struct {
+	Version string
+}
+
+// NewFromEnv returns a Client configured from the environment. Like the go
+// command, it reads each variable from the environment if it's set there
+// and not empty, and otherwise from the file written by "go env -w".
+func NewFromEnv() (*Client, error) {
+	fileEnv := readGoEnvFile()
+	getenv := func(key, def string) string {
+		if v := lookupGoEnv(fileEnv, key); v != "" {
+			return v
+		}
+		return def
+	}
+	private := getenv("GOPRIVATE", "")
+	return New(
+		getenv("GOPROXY", "https://proxy.golang.org,direct"),
+		getenv("GONOPROXY", private),
+		getenv("GONOSUMDB", private),
+		getenv("GOSUMDB", "sum.golang.org"),
+	)
+}
+
+// Getenv returns the value of a go environment variable as the go command
+// sees it: from the environment if it's set there and not empty, and
+// otherwise from the file written by "go env -w". Getenv doesn't fill in
+// defaults, so it returns "" for variables that aren't set either way.
+func Getenv(key string) string {
+	return lookupGoEnv(readGoEnvFile(), key)
+}
+
+func lookupGoEnv(fileEnv map[string]string, key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fileEnv[key]
+}
+
+// readGoEnvFile reads the variables set with "go env -w". They're stored in
+// the file named by GOENV, or in go/env in the user's configuration
+// directory. Like the go command, readGoEnvFile ignores a missing file and
+// lines that don't set a variable.
+func readGoEnvFile() map[string]string {
+	file := os.Getenv("GOENV")
+	if file == "off" {
+		return nil
+	}
+	if file == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil || dir == "" {
+			return nil
+		}
+		file = filepath.Join(dir, "go", "env")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	env := make(map[string]string)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		i := bytes.IndexByte(line, '=')
+		if i < 0 || line[0] < 'A' || 'Z' < line[0] {
+			continue
+		}
+		env[string(line[:i])] = string(line[i+1:])
+	}
+	return env
+}
+
+// New parses a GOPROXY list. Entries are separated by "," or "|".
+func New(goproxy, noProxy, noSumDB, sumDB string) (*Client, error) {
+	p := &Client{
+		noProxy:   noProxy,
+		noSumDB:   noSumDB,
+		client:    &http.Client{Timeout: requestTimeout},
+		zipClient: &http.Client{Timeout: zipTimeout},
+	}
+	for goproxy != "" {
+		var entry proxyEntry
+		i := strings.IndexAny(goproxy, ",|")
+		if i < 0 {
+			entry.url, goproxy = goproxy, ""
+		} else {
+			entry.url, entry.fallBackOnError, goproxy = goproxy[:i], goproxy[i] == '|', goproxy[i+1:]
+		}
+		entry.url = strings.TrimSpace(entry.url)
+		if entry.url == "" {
+			continue
+		}
+		if entry.url != "direct" && entry.url != "off" {
+			u, err := url.Parse(entry.url)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "file") {
+				return nil, fmt.Errorf("GOPROXY: invalid proxy URL %q", entry.url)
+			}
+			entry.url = strings.TrimSuffix(entry.url, "/")
+		}
+		p.proxies = append(p.proxies, entry)
+	}
+	if len(p.proxies) == 0 {
+		p.proxies = []proxyEntry{{url: "off"}}
+	}
+	key, sumDBURL, err := parseGOSUMDB(sumDB)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		p.sumDB = sumdb.NewClient(newSumDBOps(p, key, sumDBURL))
+	}
+	return p, nil
+}
+
+// Get fetches a file for modPath from each proxy in turn until one succeeds.
+// rel is the path of the file relative to the module's directory on the
+// proxy, for example, "@v/list".
+func (p *Client) Get(modPath, rel string) ([]byte, error) {
+	if module.MatchPrefixPatterns(p.noProxy, modPath) {
+		return nil, ErrUseDirect
+	}
+	escPath, err := module.EscapePath(modPath)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, entry := range p.proxies {
+		switch entry.url {
+		case "direct":
+			return nil, ErrUseDirect
+		case "off":
+			if firstErr == nil {
+				firstErr = fmt.Errorf("module lookup disabled by GOPROXY=off")
+			}
+			return nil, firstErr
+		}
+		data, err := p.fetch(entry.url + "/" + escPath + "/" + rel)
+		if err == nil {
+			return data, nil
+		}
+		if firstErr == nil || errors.Is(firstErr, ErrNotFound) {
+			firstErr = err
+		}
+		if !entry.fallBackOnError && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, firstErr
+}
+
+// fetch reads a file from a proxy. Missing files are reported with
+// ErrNotFound.
+func (p *Client) fetch(rawURL string) ([]byte, error) {
+	if strings.HasPrefix(rawURL, "file://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.FromSlash(u.Path))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s: %w", rawURL, ErrNotFound)
+		}
+		return data, err
+	}
+	resp, err := p.client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return data, nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, fmt.Errorf("%s: %s: %w", rawURL, strings.TrimSpace(string(data)), ErrNotFound)
+	default:
+		return nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+}
+
+// Versions returns the versions of a module listed by @v/list.
+func (p *Client) Versions(modPath string) ([]string, error) {
+	data, err := p.Get(modPath, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(line); len(f) > 0 && semver.IsValid(f[0]) {
+			versions = append(versions, f[0])
+		}
+	}
+	semver.Sort(versions)
+	return versions, nil
+}
+
+// Latest returns the latest version of a module. It prefers the highest
+// release version in @v/list, then falls back to @latest, which may be a
+// pseudo-version.
+func (p *Client) Latest(modPath string) (string, error) {
+	versions, err := p.Versions(modPath)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if semver.Prerelease(versions[i]) == "" {
+			return versions[i], nil
+		}
+	}
+	if len(versions) > 0 {
+		return versions[len(versions)-1], nil
+	}
+	data, err := p.Get(modPath, "@latest")
+	if err != nil {
+		return "", err
+	}
+	return parseProxyInfo(data)
+}
+
+// Info resolves a query, which may be a version, "latest", or a branch, tag,
+// or revision name, to a canonical version.
+func (p *Client) Info(modPath, query string) (string, error) {
+	if query == "latest" {
+		return p.Latest(modPath)
+	}
+	escVersion, err := module.EscapeVersion(query)
+	if err != nil {
+		return "", err
+	}
+	data, err := p.Get(modPath, "@v/"+escVersion+".info")
+	if err != nil {
+		return "", err
+	}
+	return parseProxyInfo(data)
+}
+
+// Mod returns the go.mod file of a module at a version.
+func (p *Client) Mod(modPath, version string) ([]byte, error) {
+	escVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(modPath, "@v/"+escVersion+".mod")
+}
+
+// Sum returns the h1: hash of a module's zip file. For modules the
+// checksum database covers, the hash is looked up in the database, and the
+// signed tree and inclusion proof are verified. Other modules' zip files are
+// downloaded and hashed, so their hashes are only as trustworthy as the
+// proxy.
+func (p *Client) Sum(modPath, version string) (string, error) {
+	if p.sumDB != nil && !module.MatchPrefixPatterns(p.noSumDB, modPath) {
+		lines, err := p.sumDB.Lookup(modPath, version)
+		if err != nil {
+			return "", fmt.Errorf("verifying module: %v", err)
+		}
+		for _, line := range lines {
+			if f := strings.Fields(line); len(f) == 3 {
+				return f[2], nil
+			}
+		}
+		return "", fmt.Errorf("verifying module: checksum database has no hash for %s@%s", modPath, version)
+	}
+
+	zipPath, cleanup, err := p.DownloadZip(modPath, version)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	return dirhash.HashZip(zipPath, dirhash.Hash1)
+}
+
+// DownloadZip returns the path to the zip file of a module version, fetched
+// from each proxy in turn until one has it. Zip files from file:// proxies
+// are used in place; others are downloaded to a temporary file, which the
+// returned cleanup function removes.
+func (p *Client) DownloadZip(modPath, version string) (zipPath string, cleanup func(), err error) {
+	if module.MatchPrefixPatterns(p.noProxy, modPath) {
+		return "", nil, ErrUseDirect
+	}
+	escPath, err := module.EscapePath(modPath)
+	if err != nil {
+		return "", nil, err
+	}
+	escVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return "", nil, err
+	}
+	var firstErr error
+	for _, entry := range p.proxies {
+		switch entry.url {
+		case "direct":
+			return "", nil, ErrUseDirect
+		case "off":
+			if firstErr == nil {
+				firstErr = fmt.Errorf("module lookup disabled by GOPROXY=off")
+			}
+			return "", nil, firstErr
+		}
+		zipPath, cleanup, err := p.fetchFile(entry.url + "/" + escPath + "/@v/" + escVersion + ".zip")
+		if err == nil {
+			return zipPath, cleanup, nil
+		}
+		if firstErr == nil || errors.Is(firstErr, ErrNotFound) {
+			firstErr = err
+		}
+		if !entry.fallBackOnError && !errors.Is(err, ErrNotFound) {
+			return "", nil, err
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("%s@%s: %w", modPath, version, ErrNotFound)
+	}
+	return "", nil, firstErr
+}
+
+// fetchFile returns the path to a file served by a proxy, like fetch, but
+// without reading it into memory. Files larger than MaxZipFile are rejected.
+func (p *Client) fetchFile(rawURL string) (filePath string, cleanup func(), err error) {
+	if strings.HasPrefix(rawURL, "file://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return "", nil, err
+		}
+		filePath := filepath.FromSlash(u.Path)
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return "", nil, fmt.Errorf("%s: %w", rawURL, ErrNotFound)
+		} else if err != nil {
+			return "", nil, err
+		}
+		return filePath, func() {}, nil
+	}
+
+	resp, err := p.zipClient.Get(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return "", nil, fmt.Errorf("%s: %s: %w", rawURL, strings.TrimSpace(string(msg)), ErrNotFound)
+	default:
+		return "", nil, fmt.Errorf("%s: %s", rawURL, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp("", "goproxy-*.zip")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+	n, err := io.Copy(tmp, io.LimitReader(resp.Body, MaxZipFile+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > MaxZipFile {
+		err = fmt.Errorf("%s: module zip file is larger than %d bytes", rawURL, MaxZipFile)
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp.Name(), cleanup, nil
+}
+
+// ModulePath finds the module that provides the package with the given
+// import path: the module with the longest path prefix of importPath the
+// proxies know about whose latest version contains the package. Like the go
+// command, ModulePath downloads zip files to check, so a package in a
+// nested module isn't attributed to the module containing it, or the other
+// way around.
+func (p *Client) ModulePath(importPath string) (string, error) {
+	var firstErr error
+	foundModule := false
+	for prefix := importPath; prefix != "." && prefix != "/"; prefix = path.Dir(prefix) {
+		if err := module.CheckPath(prefix); err != nil {
+			continue
+		}
+		version, err := p.Latest(prefix)
+		if err == nil {
+			var ok bool
+			if ok, err = p.hasPackage(prefix, version, importPath); ok {
+				return prefix, nil
+			} else if err == nil {
+				err = fmt.Errorf("module %s@%s found, but does not contain package %s: %w", prefix, version, importPath, ErrNotFound)
+			}
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+		// A module without the package is a more useful error than prefixes
+		// that aren't modules.
+		if firstErr == nil || (version != "" && !foundModule) {
+			firstErr, foundModule = err, version != ""
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("%s: %w", importPath, ErrNotFound)
+	}
+	if foundModule {
+		return "", firstErr
+	}
+	return "", fmt.Errorf("no module provides package %s: %w", importPath, firstErr)
+}
+
+// hasPackage reports whether the zip file of modPath at version contains a
+// Go file in the directory of the package importPath.
+func (p *Client) hasPackage(modPath, version, importPath string) (bool, error) {
+	zipPath, cleanup, err := p.DownloadZip(modPath, version)
+	if err != nil {
+		return false, err
+	}
+	defer cleanup()
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return false, fmt.Errorf("%s@%s: %v", modPath, version, err)
+	}
+	defer zr.Close()
+	dir := modPath + "@" + version + strings.TrimPrefix(importPath, modPath) + "/"
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(f.Name, dir)
+		if name != f.Name && !strings.Contains(name, "/") && strings.HasSuffix(name, ".go") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func parseProxyInfo(data []byte) (string, error) {
+	var info proxyInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return "", fmt.Errorf("invalid version info: %v", err)
+	}
+	if !semver.IsValid(info.Version) {
+		return "", fmt.Errorf("invalid version %q", info.Version)
+	}
+	return info.Version, nil
+}
@@ -0,0 +1,282 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goproxy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy/goproxytest"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+func TestClient(t *testing.T) {
+	privateZip := goproxytest.ModuleZip(t, "example.com/private@v0.1.0", map[string]string{
+		"go.mod":     "module example.com/private\n",
+		"private.go": "package private\n",
+	})
+	fooZip := goproxytest.ModuleZip(t, "example.com/foo@v1.1.0", map[string]string{
+		"go.mod":          "module example.com/foo\n",
+		"bar/baz/baz.go":  "package baz\n",
+		"bar/baz/doc.txt": "",
+	})
+	// example.com/nested/inner was split out of example.com/nested, which
+	// still provides example.com/nested/inner/old.
+	nestedZip := goproxytest.ModuleZip(t, "example.com/nested@v1.0.0", map[string]string{
+		"go.mod":           "module example.com/nested\n",
+		"inner/old/old.go": "package old\n",
+	})
+	innerZip := goproxytest.ModuleZip(t, "example.com/nested/inner@v1.0.0", map[string]string{
+		"go.mod":   "module example.com/nested/inner\n",
+		"inner.go": "package inner\n",
+	})
+	proxyURL := goproxytest.WriteFileProxy(t, map[string]string{
+		"example.com/foo/@v/list":                "v1.0.0\nv1.1.0\nv1.2.0-pre\n",
+		"example.com/foo/@v/v1.1.0.info":         `{"Version":"v1.1.0"}`,
+		"example.com/foo/@v/v1.1.0.mod":          "module example.com/foo\n",
+		"example.com/foo/@v/v1.1.0.zip":          fooZip,
+		"example.com/nested/@v/list":             "v1.0.0\n",
+		"example.com/nested/@v/v1.0.0.zip":       nestedZip,
+		"example.com/nested/inner/@v/list":       "v1.0.0\n",
+		"example.com/nested/inner/@v/v1.0.0.zip": innerZip,
+		"example.com/!upper/@v/list":             "v2.0.0+incompatible\n",
+		"example.com/private/@v/list":            "v0.1.0\n",
+		"example.com/private/@v/v0.1.0.zip":      privateZip,
+		"example.com/pseudo/@latest":             `{"Version":"v0.0.0-20260101000000-0123456789ab"}`,
+	})
+	emptyURL := goproxytest.WriteFileProxy(t, nil)
+	sumDB := goproxytest.ServeSumDB(t, map[string]string{
+		"example.com/foo@v1.1.0": "h1:foo=",
+	})
+	p, err := New(emptyURL+","+proxyURL, "", "example.com/private", sumDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("modulePath", func(t *testing.T) {
+		got, err := p.ModulePath("example.com/foo/bar/baz")
+		if err != nil || got != "example.com/foo" {
+			t.Errorf("got %q, %v; want %q, nil", got, err, "example.com/foo")
+		}
+		if _, err := p.ModulePath("example.com/missing/pkg"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing module: got error %v; want not found", err)
+		}
+		if _, err := p.ModulePath("example.com/foo/missing"); !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "does not contain package") {
+			t.Errorf("missing package: got error %v; want not found", err)
+		}
+		for _, tc := range []struct{ importPath, want string }{
+			{"example.com/nested/inner", "example.com/nested/inner"},
+			{"example.com/nested/inner/old", "example.com/nested"},
+		} {
+			if got, err := p.ModulePath(tc.importPath); err != nil || got != tc.want {
+				t.Errorf("%s: got %q, %v; want %q, nil", tc.importPath, got, err, tc.want)
+			}
+		}
+	})
+
+	t.Run("latest", func(t *testing.T) {
+		for _, tc := range []struct{ modPath, want string }{
+			{"example.com/foo", "v1.1.0"},
+			{"example.com/Upper", "v2.0.0+incompatible"},
+			{"example.com/pseudo", "v0.0.0-20260101000000-0123456789ab"},
+		} {
+			if got, err := p.Info(tc.modPath, "latest"); err != nil || got != tc.want {
+				t.Errorf("%s: got %q, %v; want %q, nil", tc.modPath, got, err, tc.want)
+			}
+		}
+	})
+
+	t.Run("info", func(t *testing.T) {
+		if got, err := p.Info("example.com/foo", "v1.1.0"); err != nil || got != "v1.1.0" {
+			t.Errorf("got %q, %v; want %q, nil", got, err, "v1.1.0")
+		}
+		if _, err := p.Info("example.com/foo", "v9.9.9"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("missing version: got error %v; want not found", err)
+		}
+	})
+
+	t.Run("mod", func(t *testing.T) {
+		if got, err := p.Mod("example.com/foo", "v1.1.0"); err != nil || string(got) != "module example.com/foo\n" {
+			t.Errorf("got %q, %v", got, err)
+		}
+	})
+
+	t.Run("sum", func(t *testing.T) {
+		if got, err := p.Sum("example.com/foo", "v1.1.0"); err != nil || got != "h1:foo=" {
+			t.Errorf("public module: got %q, %v; want %q, nil", got, err, "h1:foo=")
+		}
+
+		zipPath := filepath.Join(t.TempDir(), "private.zip")
+		if err := os.WriteFile(zipPath, []byte(privateZip), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		want, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := p.Sum("example.com/private", "v0.1.0"); err != nil || got != want {
+			t.Errorf("private module: got %q, %v; want %q, nil", got, err, want)
+		}
+	})
+}
+
+func TestNewFromEnvGoEnvFile(t *testing.T) {
+	proxyURL := goproxytest.WriteFileProxy(t, map[string]string{
+		"example.com/foo/@v/list": "v1.0.0\n",
+	})
+	envFile := filepath.Join(t.TempDir(), "env")
+	content := "GOPROXY=" + proxyURL + "\nGOPRIVATE=example.com/private\nGOSUMDB=off\n"
+	if err := os.WriteFile(envFile, []byte(content), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOENV", envFile)
+	// Empty variables don't override the file, as with the go command.
+	t.Setenv("GOPROXY", "")
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GOSUMDB", "")
+
+	p, err := NewFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := p.Latest("example.com/foo"); err != nil || got != "v1.0.0" {
+		t.Errorf("public module: got %q, %v; want %q, nil", got, err, "v1.0.0")
+	}
+	if _, err := p.Latest("example.com/private/pkg"); !errors.Is(err, ErrUseDirect) {
+		t.Errorf("private module: got error %v; want %v", err, ErrUseDirect)
+	}
+
+	t.Setenv("GOPRIVATE", "example.com/foo")
+	if p, err = NewFromEnv(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Latest("example.com/foo"); !errors.Is(err, ErrUseDirect) {
+		t.Errorf("with GOPRIVATE set: got error %v; want %v", err, ErrUseDirect)
+	}
+}
+
+func TestClientProxyList(t *testing.T) {
+	proxyURL := goproxytest.WriteFileProxy(t, map[string]string{
+		"example.com/foo/@v/list": "v1.0.0\n",
+	})
+	// A directory where a file is expected causes an error other than
+	// not found.
+	brokenURL := goproxytest.WriteFileProxy(t, map[string]string{
+		"example.com/foo/@v/list/x": "",
+	})
+
+	for _, tc := range []struct {
+		desc, goproxy, noProxy string
+		want                   string
+		wantErr                error
+	}{
+		{desc: "comma falls back on not found", goproxy: goproxytest.WriteFileProxy(t, nil) + "," + proxyURL, want: "v1.0.0"},
+		{desc: "comma stops on error", goproxy: brokenURL + "," + proxyURL, wantErr: errors.New("")},
+		{desc: "pipe falls back on error", goproxy: brokenURL + "|" + proxyURL, want: "v1.0.0"},
+		{desc: "direct", goproxy: goproxytest.WriteFileProxy(t, nil) + ",direct", wantErr: ErrUseDirect},
+		{desc: "off", goproxy: "off", wantErr: errors.New("")},
+		{desc: "private", goproxy: proxyURL, noProxy: "example.com", wantErr: ErrUseDirect},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			p, err := New(tc.goproxy, tc.noProxy, "", "off")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Latest("example.com/foo")
+			if tc.wantErr != nil {
+				if err == nil || (tc.wantErr == ErrUseDirect && !errors.Is(err, ErrUseDirect)) {
+					t.Errorf("got %q, %v; want error %v", got, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tc.want {
+				t.Errorf("got %q, %v; want %q, nil", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestClientSumDBVerification(t *testing.T) {
+	sums := map[string]string{"example.com/foo@v1.1.0": "h1:foo="}
+	sumDB := goproxytest.ServeSumDB(t, sums)
+	otherKey := strings.Fields(goproxytest.ServeSumDB(t, sums))[0]
+	sumDBURL := strings.Fields(sumDB)[1]
+	// A proxy that claims to serve the database, but returns a hash that
+	// isn't in a signed tree.
+	badProxyURL := goproxytest.WriteFileProxy(t, map[string]string{
+		"sumdb/sum.example.com/supported":                     "",
+		"sumdb/sum.example.com/lookup/example.com/foo@v1.1.0": "0\nexample.com/foo v1.1.0 h1:bad=\n\ngo.sum database tree\n1\nAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n\n\u2014 sum.example.com AAAAAAAA\n",
+	})
+
+	for _, tc := range []struct {
+		desc, goproxy, gosumdb string
+		wantErr                bool
+	}{
+		{desc: "direct", goproxy: "off", gosumdb: sumDB},
+		{desc: "unsupported proxy", goproxy: goproxytest.WriteFileProxy(t, nil), gosumdb: sumDB},
+		{desc: "wrong key", goproxy: "off", gosumdb: otherKey + " " + sumDBURL, wantErr: true},
+		{desc: "unsigned proxy", goproxy: badProxyURL, gosumdb: sumDB, wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			p, err := New(tc.goproxy, "", "", tc.gosumdb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.Sum("example.com/foo", "v1.1.0")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("got %q, nil; want error", got)
+				}
+				return
+			}
+			if err != nil || got != "h1:foo=" {
+				t.Errorf("got %q, %v; want %q, nil", got, err, "h1:foo=")
+			}
+		})
+	}
+}
+
+func TestParseGOSUMDB(t *testing.T) {
+	const golangKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8"
+	for _, tc := range []struct {
+		gosumdb, wantKey, wantURL string
+		wantErr                   bool
+	}{
+		{gosumdb: "off"},
+		{gosumdb: "", wantKey: golangKey, wantURL: "https://sum.golang.org"},
+		{gosumdb: "sum.golang.org", wantKey: golangKey, wantURL: "https://sum.golang.org"},
+		{gosumdb: "sum.golang.google.cn", wantKey: golangKey, wantURL: "https://sum.golang.google.cn"},
+		{gosumdb: golangKey + " https://sum.example.com/", wantKey: golangKey, wantURL: "https://sum.example.com"},
+		{gosumdb: "sum.example.com", wantErr: true},
+		{gosumdb: golangKey + " https://a https://b", wantErr: true},
+	} {
+		key, url, err := parseGOSUMDB(tc.gosumdb)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %q, %q, nil; want error", tc.gosumdb, key, url)
+			}
+			continue
+		}
+		if err != nil || key != tc.wantKey || url != tc.wantURL {
+			t.Errorf("%q: got %q, %q, %v; want %q, %q, nil", tc.gosumdb, key, url, err, tc.wantKey, tc.wantURL)
+		}
+	}
+}
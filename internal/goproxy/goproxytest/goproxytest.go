@@ -0,0 +1,96 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package goproxytest provides module proxies and a checksum database for
+// tests of code that uses the goproxy package.
+package goproxytest
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// WriteFileProxy writes a module proxy into a new directory and returns a
+// file:// URL for it. files maps paths relative to the proxy root to their
+// contents.
+func WriteFileProxy(t testing.TB, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return "file://" + filepath.ToSlash(dir)
+}
+
+// ModuleZip returns the content of a module zip file for the module version
+// modVersion, written as "path@version". files maps paths relative to the
+// module root to their contents.
+func ModuleZip(t testing.TB, modVersion string, files map[string]string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		f, err := w.Create(modVersion + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// ServeSumDB starts a checksum database that serves the given hashes and
+// signs its tree with a new key. sums maps "path@version" to an h1: hash.
+// ServeSumDB returns a GOSUMDB value naming the database, its key, and its
+// URL. The database is stopped when the test ends.
+func ServeSumDB(t testing.TB, sums map[string]string) string {
+	t.Helper()
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sum.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gosum := func(path, version string) ([]byte, error) {
+		sum, ok := sums[path+"@"+version]
+		if !ok {
+			// The server only reports errors that os.IsNotExist recognizes
+			// as not found, and it doesn't unwrap them.
+			return nil, os.ErrNotExist
+		}
+		return []byte(fmt.Sprintf("%s %s %s\n", path, version, sum)), nil
+	}
+	srv := httptest.NewServer(sumdb.NewServer(sumdb.NewTestServer(skey, gosum)))
+	t.Cleanup(srv.Close)
+	return vkey + " " + srv.URL
+}
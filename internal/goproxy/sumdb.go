@@ -0,0 +1,174 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package goproxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
+)
+
+// knownSumDBs maps the names of checksum databases the go command knows
+// to their verifier keys, so GOSUMDB may name them without a key.
+var knownSumDBs = map[string]string{
+	"sum.golang.org": "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ux18htTTAD8OuAn8",
+}
+
+// parseGOSUMDB parses GOSUMDB, which may be "off", the name of a known
+// database, "name+key", or "name+key url". It returns the verifier key and
+// the URL used to access the database when no proxy serves it. key is empty
+// if the database is off.
+func parseGOSUMDB(gosumdb string) (key, url string, err error) {
+	if gosumdb == "off" {
+		return "", "", nil
+	}
+	fields := strings.Fields(gosumdb)
+	if len(fields) == 0 {
+		fields = []string{"sum.golang.org"}
+	}
+	if len(fields) > 2 {
+		return "", "", fmt.Errorf("GOSUMDB: invalid value %q", gosumdb)
+	}
+	key = fields[0]
+	if key == "sum.golang.google.cn" {
+		// The go command accepts this mirror of sum.golang.org by name.
+		key, url = knownSumDBs["sum.golang.org"], "https://sum.golang.google.cn"
+	} else if k, ok := knownSumDBs[key]; ok {
+		key = k
+	}
+	if len(fields) == 2 {
+		url = strings.TrimSuffix(fields[1], "/")
+	}
+	verifier, err := note.NewVerifier(key)
+	if err != nil {
+		return "", "", fmt.Errorf("GOSUMDB: %v", err)
+	}
+	if url == "" {
+		url = "https://" + verifier.Name()
+	}
+	return key, url, nil
+}
+
+// sumDBOps implements sumdb.ClientOps for Client. Like the go command, it
+// accesses the checksum database through the first proxy that supports it,
+// or directly if none do. Signed tree heads and tiles are kept in memory,
+// so each Client checks that the trees it sees are consistent with each
+// other, but not with trees seen by earlier runs.
+type sumDBOps struct {
+	p         *Client
+	name, key string
+	url       string
+
+	baseOnce sync.Once
+	base     string
+	baseErr  error
+
+	mu     sync.Mutex
+	config map[string][]byte
+	cache  map[string][]byte
+}
+
+func newSumDBOps(p *Client, key, url string) *sumDBOps {
+	return &sumDBOps{
+		p:      p,
+		name:   strings.SplitN(key, "+", 2)[0],
+		key:    key,
+		url:    url,
+		config: make(map[string][]byte),
+		cache:  make(map[string][]byte),
+	}
+}
+
+// baseURL returns the URL the database's paths are relative to. Proxies are
+// asked whether they support the database in GOPROXY order; the database
+// is accessed directly after "direct", "off", or the end of the list.
+func (o *sumDBOps) baseURL() (string, error) {
+	o.baseOnce.Do(func() {
+		o.base = o.url
+		for _, entry := range o.p.proxies {
+			if entry.url == "direct" || entry.url == "off" {
+				break
+			}
+			proxyBase := entry.url + "/sumdb/" + o.name
+			_, err := o.p.fetch(proxyBase + "/supported")
+			if err == nil {
+				o.base = proxyBase
+				return
+			}
+			if !errors.Is(err, ErrNotFound) && !entry.fallBackOnError {
+				o.baseErr = err
+				return
+			}
+		}
+	})
+	return o.base, o.baseErr
+}
+
+func (o *sumDBOps) ReadRemote(path string) ([]byte, error) {
+	base, err := o.baseURL()
+	if err != nil {
+		return nil, err
+	}
+	return o.p.fetch(base + path)
+}
+
+func (o *sumDBOps) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.key), nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.config[file], nil
+}
+
+func (o *sumDBOps) WriteConfig(file string, old, new []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !bytes.Equal(o.config[file], old) {
+		return sumdb.ErrWriteConflict
+	}
+	o.config[file] = new
+	return nil
+}
+
+func (o *sumDBOps) ReadCache(file string) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if data, ok := o.cache[file]; ok {
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s: %w", file, ErrNotFound)
+}
+
+func (o *sumDBOps) WriteCache(file string, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.cache[file] = data
+}
+
+func (o *sumDBOps) Log(msg string) {}
+
+// SecurityError logs the detailed message. The sumdb.Client operation that
+// found the problem then fails, so the hash in question is never returned.
+func (o *sumDBOps) SecurityError(msg string) {
+	log.Print(msg)
+}
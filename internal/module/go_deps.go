@@ -0,0 +1,710 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/buildtools/build"
+	"golang.org/x/mod/modfile"
+)
+
+// GoDeps describes how the go_deps module extension provided by Gazelle is
+// used in MODULE.bazel and the segments it includes.
+type GoDeps struct {
+	// GoModFiles lists go.mod files named by go_deps.from_file tags, as
+	// slash-separated paths relative to the repository root.
+	GoModFiles []string
+
+	// GoWorkFiles lists go.work files named by go_deps.from_file tags, as
+	// slash-separated paths relative to the repository root.
+	GoWorkFiles []string
+
+	// Modules lists modules declared with go_deps.module tags.
+	Modules []GoDepsModule
+
+	// UseRepo maps the names of repositories created by go_deps to the
+	// apparent names they are visible as in the main module, as declared
+	// with use_repo. A repository may only be referenced from the main
+	// module if it appears here.
+	UseRepo map[string]string
+}
+
+// GoDepsModule is a module declared with a go_deps.module tag.
+type GoDepsModule struct {
+	Path, Version, Sum string
+}
+
+// GoDepsRepo is a repository created by go_deps for a Go module.
+type GoDepsRepo struct {
+	// ModulePath is the path of the module provided by the repository.
+	ModulePath string
+
+	// RepoName is the name go_deps gives the repository, derived from
+	// ModulePath.
+	RepoName string
+
+	// ApparentName is the name the repository is visible as in the main
+	// module. It is empty if the repository is not listed in use_repo.
+	ApparentName string
+}
+
+// ExtractGoDeps reads go_deps tags and use_repo calls from the files
+// returned by ParseModuleFiles. It returns nil and no error if the files
+// don't use go_deps.
+func ExtractGoDeps(files []*build.File) (*GoDeps, error) {
+	var goDeps *GoDeps
+	for _, f := range files {
+		if err := collectGoDepsInFile(f, &goDeps); err != nil {
+			return nil, err
+		}
+	}
+	return goDeps, nil
+}
+
+// Repos returns the repositories go_deps creates for modules required by the
+// main module: those listed in go.mod and go.work files named by
+// go_deps.from_file tags, and those declared with go_deps.module tags.
+// Repositories are sorted by module path.
+func (d *GoDeps) Repos(repoRoot string) ([]GoDepsRepo, error) {
+	modulePaths := make(map[string]bool)
+	goModFiles := d.GoModFiles
+	for _, workFile := range d.GoWorkFiles {
+		uses, err := goWorkUses(repoRoot, workFile)
+		if err != nil {
+			return nil, err
+		}
+		goModFiles = append(goModFiles, uses...)
+	}
+	mainModules := make(map[string]bool)
+	for _, goModFile := range goModFiles {
+		mainPath, reqs, err := goModRequirements(repoRoot, goModFile)
+		if err != nil {
+			return nil, err
+		}
+		mainModules[mainPath] = true
+		for _, req := range reqs {
+			modulePaths[req] = true
+		}
+	}
+	for _, m := range d.Modules {
+		modulePaths[m.Path] = true
+	}
+
+	repos := make([]GoDepsRepo, 0, len(modulePaths))
+	for modulePath := range modulePaths {
+		if mainModules[modulePath] {
+			// Modules in the same go.work file don't get repositories.
+			continue
+		}
+		repoName := label.ImportPathToBazelRepoName(modulePath)
+		repos = append(repos, GoDepsRepo{
+			ModulePath:   modulePath,
+			RepoName:     repoName,
+			ApparentName: d.UseRepo[repoName],
+		})
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		return repos[i].ModulePath < repos[j].ModulePath
+	})
+	return repos, nil
+}
+
+// collectGoDepsInFile records go_deps usage in a single MODULE.bazel segment.
+// Extension proxies are local to the segment that creates them. *goDeps is
+// allocated when the first go_deps proxy is found.
+func collectGoDepsInFile(f *build.File, goDeps **GoDeps) error {
+	proxies := make(map[string]bool)
+	for _, stmt := range f.Stmt {
+		switch stmt := stmt.(type) {
+		case *build.AssignExpr:
+			lhs, ok := stmt.LHS.(*build.Ident)
+			if !ok {
+				continue
+			}
+			call, ok := stmt.RHS.(*build.CallExpr)
+			if !ok || !isGoDepsExtension(call) {
+				continue
+			}
+			proxies[lhs.Name] = true
+			if *goDeps == nil {
+				*goDeps = &GoDeps{UseRepo: make(map[string]string)}
+			}
+
+		case *build.CallExpr:
+			if dot, ok := stmt.X.(*build.DotExpr); ok {
+				if proxy, ok := dot.X.(*build.Ident); !ok || !proxies[proxy.Name] {
+					continue
+				}
+				if err := (*goDeps).addTag(f.Path, dot.Name, stmt); err != nil {
+					return err
+				}
+				continue
+			}
+			if ident, ok := stmt.X.(*build.Ident); !ok || ident.Name != "use_repo" || len(stmt.List) == 0 {
+				continue
+			}
+			if proxy, ok := stmt.List[0].(*build.Ident); !ok || !proxies[proxy.Name] {
+				continue
+			}
+			for _, arg := range stmt.List[1:] {
+				switch arg := arg.(type) {
+				case *build.StringExpr:
+					(*goDeps).UseRepo[arg.Value] = arg.Value
+				case *build.AssignExpr:
+					// use_repo(go_deps, alias = "repo_name")
+					alias, ok := arg.LHS.(*build.Ident)
+					if !ok {
+						continue
+					}
+					if str, ok := arg.RHS.(*build.StringExpr); ok {
+						(*goDeps).UseRepo[str.Value] = alias.Name
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isGoDepsExtension returns whether call is a use_extension call for the
+// go_deps extension defined in Gazelle's extensions.bzl.
+func isGoDepsExtension(call *build.CallExpr) bool {
+	if ident, ok := call.X.(*build.Ident); !ok || ident.Name != "use_extension" {
+		return false
+	}
+	if len(call.List) < 2 {
+		return false
+	}
+	file, ok := call.List[0].(*build.StringExpr)
+	if !ok || !strings.HasSuffix(file.Value, "//:extensions.bzl") {
+		return false
+	}
+	name, ok := call.List[1].(*build.StringExpr)
+	return ok && name.Value == "go_deps"
+}
+
+func (d *GoDeps) addTag(filePath, tag string, call *build.CallExpr) error {
+	attrs := make(map[string]string)
+	for _, arg := range call.List {
+		if assign, ok := arg.(*build.AssignExpr); ok {
+			key, ok := assign.LHS.(*build.Ident)
+			if !ok {
+				continue
+			}
+			if str, ok := assign.RHS.(*build.StringExpr); ok {
+				attrs[key.Name] = str.Value
+			}
+		}
+	}
+	switch tag {
+	case "from_file":
+		if goMod := attrs["go_mod"]; goMod != "" {
+			p, err := labelToPath(goMod)
+			if err != nil {
+				return fmt.Errorf("%s: go_deps.from_file: %v", filePath, err)
+			}
+			d.GoModFiles = append(d.GoModFiles, p)
+		}
+		if goWork := attrs["go_work"]; goWork != "" {
+			p, err := labelToPath(goWork)
+			if err != nil {
+				return fmt.Errorf("%s: go_deps.from_file: %v", filePath, err)
+			}
+			d.GoWorkFiles = append(d.GoWorkFiles, p)
+		}
+	case "module":
+		if attrs["path"] == "" {
+			return fmt.Errorf("%s: go_deps.module: missing path", filePath)
+		}
+		d.Modules = append(d.Modules, GoDepsModule{
+			Path:    attrs["path"],
+			Version: attrs["version"],
+			Sum:     attrs["sum"],
+		})
+	}
+	return nil
+}
+
+// labelToPath converts a label of a file in the main repository to a
+// slash-separated path relative to the repository root.
+func labelToPath(s string) (string, error) {
+	l, err := label.Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if l.Repo != "" && l.Repo != "@" {
+		return "", fmt.Errorf("%s: file must be in the main repository", s)
+	}
+	return path.Join(l.Pkg, l.Name), nil
+}
+
+// goModRequirements returns the module path declared in a go.mod file and
+// the paths of the modules it requires.
+func goModRequirements(repoRoot, relPath string) (modulePath string, reqs []string, err error) {
+	p := filepath.Join(repoRoot, filepath.FromSlash(relPath))
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", nil, err
+	}
+	f, err := modfile.ParseLax(p, data, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if f.Module != nil {
+		modulePath = f.Module.Mod.Path
+	}
+	for _, req := range f.Require {
+		reqs = append(reqs, req.Mod.Path)
+	}
+	return modulePath, reqs, nil
+}
+
+// goWorkUses returns the paths of the go.mod files in directories named by
+// use directives in a go.work file.
+func goWorkUses(repoRoot, relPath string) ([]string, error) {
+	p := filepath.Join(repoRoot, filepath.FromSlash(relPath))
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.ParseWork(p, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	var goModFiles []string
+	for _, use := range f.Use {
+		goModFiles = append(goModFiles, path.Join(path.Dir(relPath), filepath.ToSlash(use.Path), "go.mod"))
+	}
+	return goModFiles, nil
+}
+
+// GoDepsUpdate describes changes UpdateGoDeps makes to go_deps usage in
+// MODULE.bazel.
+type GoDepsUpdate struct {
+	// GoModFiles and GoWorkFiles list go.mod and go.work files that should
+	// be named by go_deps.from_file tags, as slash-separated paths relative
+	// to the repository root.
+	GoModFiles, GoWorkFiles []string
+
+	// Modules lists modules that should be declared with go_deps.module tags.
+	// Existing tags with the same path are updated.
+	Modules []GoDepsModule
+
+	// Overrides lists override tags, such as gazelle_override, that should be
+	// declared. Existing tags of the same kind for the same path are updated.
+	Overrides []GoDepsTag
+
+	// UsedRepos is the set of apparent repository names referenced by build
+	// files in the main module. Repositories created by go_deps are added to
+	// use_repo if they are referenced.
+	UsedRepos map[string]bool
+
+	// Prune indicates that use_repo entries for repositories that are not
+	// referenced should be removed.
+	Prune bool
+
+	// GazelleRepo is the apparent name of the Gazelle module. It's used to
+	// load the go_deps extension if MODULE.bazel doesn't use it yet.
+	GazelleRepo string
+}
+
+// GoDepsTag is a tag on the go_deps extension other than from_file and
+// module, such as gazelle_override or archive_override.
+type GoDepsTag struct {
+	// Name is the name of the tag, for example, "gazelle_override".
+	Name string
+
+	// Path is the path of the module the tag applies to.
+	Path string
+
+	// Attrs contains the other attributes of the tag.
+	Attrs map[string]build.Expr
+}
+
+// UpdateGoDeps edits the go_deps tags and use_repo calls in MODULE.bazel as
+// described by u. Changes are made in the first segment that uses go_deps,
+// or in MODULE.bazel itself if no segment does. UpdateGoDeps returns whether
+// the file was changed.
+func UpdateGoDeps(repoRoot string, u GoDepsUpdate) (bool, error) {
+	files, err := parseModuleSegments(repoRoot, "MODULE.bazel")
+	if err != nil {
+		return false, err
+	}
+	if len(files) == 0 {
+		return false, fmt.Errorf("%s: file does not exist", filepath.Join(repoRoot, "MODULE.bazel"))
+	}
+	segmentIndex := 0
+	for i, segment := range files {
+		if len(goDepsProxies(segment)) > 0 {
+			segmentIndex = i
+			break
+		}
+	}
+	// Load the segment again so that only the statements changed below are
+	// reformatted when it's written.
+	data, err := os.ReadFile(files[segmentIndex].Path)
+	if err != nil {
+		return false, err
+	}
+	rf, err := rule.LoadModuleData(files[segmentIndex].Path, "", data)
+	if err != nil {
+		return false, err
+	}
+	f := rf.File
+	files[segmentIndex] = f
+
+	proxies := goDepsProxies(f)
+	if len(proxies) == 0 {
+		gazelleRepo := u.GazelleRepo
+		if gazelleRepo == "" {
+			gazelleRepo = "gazelle"
+		}
+		f.Stmt = append(f.Stmt, &build.AssignExpr{
+			LHS: &build.Ident{Name: "go_deps"},
+			Op:  "=",
+			RHS: &build.CallExpr{
+				X: &build.Ident{Name: "use_extension"},
+				List: []build.Expr{
+					&build.StringExpr{Value: "@" + gazelleRepo + "//:extensions.bzl"},
+					&build.StringExpr{Value: "go_deps"},
+				},
+			},
+		})
+		proxies = []string{"go_deps"}
+	}
+	proxy := proxies[0]
+	isProxy := make(map[string]bool)
+	for _, p := range proxies {
+		isProxy[p] = true
+	}
+
+	// Add from_file and module tags that are missing and update the versions
+	// of modules that are already declared.
+	fromFiles := make(map[string]bool)
+	moduleTags := make(map[string]*build.CallExpr)
+	overrideTags := make(map[string]*build.CallExpr)
+	for _, stmt := range f.Stmt {
+		call, tag := goDepsTag(stmt, isProxy)
+		switch tag {
+		case "":
+			continue
+		case "from_file":
+			for _, key := range []string{"go_mod", "go_work"} {
+				if v, ok := callAttr(call, key).(*build.StringExpr); ok {
+					fromFiles[key+"="+v.Value] = true
+				}
+			}
+		case "module":
+			if v, ok := callAttr(call, "path").(*build.StringExpr); ok {
+				moduleTags[v.Value] = call
+			}
+		default:
+			if v, ok := callAttr(call, "path").(*build.StringExpr); ok {
+				overrideTags[tag+" "+v.Value] = call
+			}
+		}
+	}
+	var newTags []build.Expr
+	addFromFile := func(key, p string) {
+		l := pathToLabel(p)
+		if fromFiles[key+"="+l] {
+			return
+		}
+		fromFiles[key+"="+l] = true
+		newTags = append(newTags, goDepsTagCall(proxy, "from_file", false, key, l))
+	}
+	for _, p := range u.GoModFiles {
+		addFromFile("go_mod", p)
+	}
+	for _, p := range u.GoWorkFiles {
+		addFromFile("go_work", p)
+	}
+	for _, m := range u.Modules {
+		if call, ok := moduleTags[m.Path]; ok {
+			setCallAttr(call, "sum", m.Sum)
+			setCallAttr(call, "version", m.Version)
+			continue
+		}
+		call := goDepsTagCall(proxy, "module", true, "path", m.Path, "sum", m.Sum, "version", m.Version)
+		moduleTags[m.Path] = call
+		newTags = append(newTags, call)
+	}
+	for _, o := range u.Overrides {
+		call, ok := overrideTags[o.Name+" "+o.Path]
+		if !ok {
+			call = goDepsTagCall(proxy, o.Name, true, "path", o.Path)
+			overrideTags[o.Name+" "+o.Path] = call
+			newTags = append(newTags, call)
+		}
+		keys := make([]string, 0, len(o.Attrs))
+		for key := range o.Attrs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			setCallAttrExpr(call, key, o.Attrs[key])
+		}
+	}
+	if len(newTags) > 0 {
+		i := lastGoDepsStmt(f, isProxy, false) + 1
+		f.Stmt = append(f.Stmt[:i], append(newTags, f.Stmt[i:]...)...)
+	}
+
+	// Find the repositories go_deps creates now that tags are updated, and
+	// make the referenced ones visible.
+	var goDeps *GoDeps
+	for _, segment := range files {
+		if err := collectGoDepsInFile(segment, &goDeps); err != nil {
+			return false, err
+		}
+	}
+	repos, err := goDeps.Repos(repoRoot)
+	if err != nil {
+		return false, err
+	}
+	var useRepoCalls []*build.CallExpr
+	for _, stmt := range f.Stmt {
+		if call, ok := stmt.(*build.CallExpr); ok && isUseRepo(call, isProxy) {
+			useRepoCalls = append(useRepoCalls, call)
+		}
+	}
+	var addRepos []string
+	for _, r := range repos {
+		if r.ApparentName == "" && u.UsedRepos[r.RepoName] {
+			addRepos = append(addRepos, r.RepoName)
+		}
+	}
+	if u.Prune {
+		for _, call := range useRepoCalls {
+			pruneUseRepo(call, u.UsedRepos)
+		}
+	}
+	if len(addRepos) > 0 {
+		var call *build.CallExpr
+		if len(useRepoCalls) > 0 {
+			call = useRepoCalls[0]
+		} else {
+			call = &build.CallExpr{
+				X:    &build.Ident{Name: "use_repo"},
+				List: []build.Expr{&build.Ident{Name: proxy}},
+			}
+			i := lastGoDepsStmt(f, isProxy, true) + 1
+			f.Stmt = append(f.Stmt[:i], append([]build.Expr{call}, f.Stmt[i:]...)...)
+			useRepoCalls = append(useRepoCalls, call)
+		}
+		for _, name := range addRepos {
+			call.List = append(call.List, &build.StringExpr{Value: name})
+		}
+		sortUseRepo(call)
+	}
+
+	// Remove use_repo calls that no longer list any repositories.
+	stmts := f.Stmt[:0]
+	for _, stmt := range f.Stmt {
+		if call, ok := stmt.(*build.CallExpr); ok && isUseRepo(call, isProxy) && len(call.List) == 1 {
+			continue
+		}
+		stmts = append(stmts, stmt)
+	}
+	f.Stmt = stmts
+
+	newContent := rf.FormatMinimal()
+	if bytes.Equal(data, newContent) {
+		return false, nil
+	}
+	if err := os.WriteFile(f.Path, newContent, 0o666); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// goDepsProxies returns the names of variables in f assigned go_deps
+// extension proxies, in order of appearance.
+func goDepsProxies(f *build.File) []string {
+	var proxies []string
+	for _, stmt := range f.Stmt {
+		assign, ok := stmt.(*build.AssignExpr)
+		if !ok {
+			continue
+		}
+		lhs, ok := assign.LHS.(*build.Ident)
+		if !ok {
+			continue
+		}
+		if call, ok := assign.RHS.(*build.CallExpr); ok && isGoDepsExtension(call) {
+			proxies = append(proxies, lhs.Name)
+		}
+	}
+	return proxies
+}
+
+// goDepsTag returns the call and tag name if stmt is a tag on one of the
+// given proxies.
+func goDepsTag(stmt build.Expr, isProxy map[string]bool) (*build.CallExpr, string) {
+	call, ok := stmt.(*build.CallExpr)
+	if !ok {
+		return nil, ""
+	}
+	dot, ok := call.X.(*build.DotExpr)
+	if !ok {
+		return nil, ""
+	}
+	if proxy, ok := dot.X.(*build.Ident); !ok || !isProxy[proxy.Name] {
+		return nil, ""
+	}
+	return call, dot.Name
+}
+
+func isUseRepo(call *build.CallExpr, isProxy map[string]bool) bool {
+	if ident, ok := call.X.(*build.Ident); !ok || ident.Name != "use_repo" || len(call.List) == 0 {
+		return false
+	}
+	proxy, ok := call.List[0].(*build.Ident)
+	return ok && isProxy[proxy.Name]
+}
+
+// lastGoDepsStmt returns the index of the last statement in f that creates
+// or tags one of the given proxies or, if withUseRepo is set, uses repos
+// from one of them.
+func lastGoDepsStmt(f *build.File, isProxy map[string]bool, withUseRepo bool) int {
+	last := len(f.Stmt) - 1
+	for i, stmt := range f.Stmt {
+		switch stmt := stmt.(type) {
+		case *build.AssignExpr:
+			if lhs, ok := stmt.LHS.(*build.Ident); ok && isProxy[lhs.Name] {
+				last = i
+			}
+		case *build.CallExpr:
+			if _, tag := goDepsTag(stmt, isProxy); tag != "" || withUseRepo && isUseRepo(stmt, isProxy) {
+				last = i
+			}
+		}
+	}
+	return last
+}
+
+// goDepsTagCall builds a call to a tag on a go_deps proxy. kvs is a list of
+// alternating keyword argument names and string values.
+func goDepsTagCall(proxy, tag string, multiLine bool, kvs ...string) *build.CallExpr {
+	call := &build.CallExpr{
+		X:              &build.DotExpr{X: &build.Ident{Name: proxy}, Name: tag},
+		ForceMultiLine: multiLine,
+	}
+	for i := 0; i < len(kvs); i += 2 {
+		setCallAttr(call, kvs[i], kvs[i+1])
+	}
+	return call
+}
+
+func callAttr(call *build.CallExpr, key string) build.Expr {
+	for _, arg := range call.List {
+		if assign, ok := arg.(*build.AssignExpr); ok {
+			if lhs, ok := assign.LHS.(*build.Ident); ok && lhs.Name == key {
+				return assign.RHS
+			}
+		}
+	}
+	return nil
+}
+
+// setCallAttr sets a keyword argument of call to a string value, adding the
+// argument if it's not present. Empty values are not added.
+func setCallAttr(call *build.CallExpr, key, value string) {
+	if value == "" && callAttr(call, key) == nil {
+		return
+	}
+	setCallAttrExpr(call, key, &build.StringExpr{Value: value})
+}
+
+// setCallAttrExpr sets a keyword argument of call, adding the argument if
+// it's not present.
+func setCallAttrExpr(call *build.CallExpr, key string, value build.Expr) {
+	for _, arg := range call.List {
+		if assign, ok := arg.(*build.AssignExpr); ok {
+			if lhs, ok := assign.LHS.(*build.Ident); ok && lhs.Name == key {
+				assign.RHS = value
+				return
+			}
+		}
+	}
+	call.List = append(call.List, &build.AssignExpr{
+		LHS: &build.Ident{Name: key},
+		Op:  "=",
+		RHS: value,
+	})
+}
+
+// pruneUseRepo removes repositories from a use_repo call whose apparent names
+// are not in used.
+func pruneUseRepo(call *build.CallExpr, used map[string]bool) {
+	list := call.List[:1]
+	for _, arg := range call.List[1:] {
+		switch arg := arg.(type) {
+		case *build.StringExpr:
+			if !used[arg.Value] {
+				continue
+			}
+		case *build.AssignExpr:
+			if alias, ok := arg.LHS.(*build.Ident); ok && !used[alias.Name] {
+				continue
+			}
+		}
+		list = append(list, arg)
+	}
+	call.List = list
+}
+
+// sortUseRepo sorts the repositories listed in a use_repo call, keeping
+// aliased repositories after the others as Bazel does.
+func sortUseRepo(call *build.CallExpr) {
+	args := call.List[1:]
+	sort.SliceStable(args, func(i, j int) bool {
+		ki, si := useRepoSortKey(args[i])
+		kj, sj := useRepoSortKey(args[j])
+		if si != sj {
+			return si
+		}
+		return ki < kj
+	})
+}
+
+func useRepoSortKey(arg build.Expr) (key string, positional bool) {
+	switch arg := arg.(type) {
+	case *build.StringExpr:
+		return arg.Value, true
+	case *build.AssignExpr:
+		if alias, ok := arg.LHS.(*build.Ident); ok {
+			return alias.Name, false
+		}
+	}
+	return "", false
+}
+
+// pathToLabel converts a slash-separated path relative to the repository root
+// into a label of a file in the main repository.
+func pathToLabel(p string) string {
+	dir, name := path.Split(p)
+	return "//" + strings.TrimSuffix(dir, "/") + ":" + name
+}
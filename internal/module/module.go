@@ -26,19 +26,22 @@ import (
 	"github.com/bazelbuild/buildtools/build"
 )
 
-// ExtractModuleToApparentNameMapping collects the mapping of module names (e.g. "rules_go") to
-// user-configured apparent names (e.g. "my_rules_go") from the repos MODULE.bazel, if it exists.
+// ModuleToApparentNameMapping collects the mapping of module names (e.g. "rules_go") to
+// user-configured apparent names (e.g. "my_rules_go") from the files returned by ParseModuleFiles.
 // See https://bazel.build/external/module#repository_names_and_strict_deps for more information on
 // apparent names.
-func ExtractModuleToApparentNameMapping(repoRoot string) (func(string) string, error) {
-	moduleToApparentName, err := collectApparentNames(repoRoot, "MODULE.bazel")
-	if err != nil {
-		return nil, err
-	}
-
+func ModuleToApparentNameMapping(files []*build.File) func(string) string {
+	moduleToApparentName := collectApparentNames(files)
 	return func(moduleName string) string {
 		return moduleToApparentName[moduleName]
-	}, nil
+	}
+}
+
+// ParseModuleFiles parses the repository's MODULE.bazel file and the
+// segments it includes, transitively. It returns nil and no error if
+// MODULE.bazel does not exist.
+func ParseModuleFiles(repoRoot string) ([]*build.File, error) {
+	return parseModuleSegments(repoRoot, "MODULE.bazel")
 }
 
 // ExtractModuleName collects name of the module from the MODULE.bazel file, if it exists.
@@ -69,11 +72,11 @@ func parseModuleSegment(repoRoot, relPath string) (*build.File, error) {
 	return build.ParseModule(path, bytes)
 }
 
-// Collects the mapping of module names (e.g. "rules_go") to user-configured apparent names (e.g.
-// "my_rules_go"). See https://bazel.build/external/module#repository_names_and_strict_deps for more
-// information on apparent names.
-func collectApparentNames(repoRoot, relPath string) (map[string]string, error) {
-	apparentNames := make(map[string]string)
+// parseModuleSegments parses the MODULE.bazel segment at relPath and all
+// segments it includes, transitively. If relPath is "MODULE.bazel" and the
+// file does not exist, parseModuleSegments returns no files and no error.
+func parseModuleSegments(repoRoot, relPath string) ([]*build.File, error) {
+	var files []*build.File
 	seenFiles := make(map[string]struct{})
 	filesToProcess := []string{relPath}
 
@@ -87,17 +90,14 @@ func collectApparentNames(repoRoot, relPath string) (map[string]string, error) {
 		bf, err := parseModuleSegment(repoRoot, f)
 		if err != nil {
 			if f == "MODULE.bazel" && os.IsNotExist(err) {
-				// If there is no MODULE.bazel file, return an empty map but no error.
+				// If there is no MODULE.bazel file, return no files but no error.
 				// Languages will know to fall back to the WORKSPACE names of repos.
 				return nil, nil
 			}
 			return nil, err
 		}
-		names, includeLabels := collectApparentNamesAndIncludes(bf)
-		for name, apparentName := range names {
-			apparentNames[name] = apparentName
-		}
-		for _, includeLabel := range includeLabels {
+		files = append(files, bf)
+		for _, includeLabel := range collectIncludes(bf) {
 			l, err := label.Parse(includeLabel)
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse include label %q: %v", includeLabel, err)
@@ -107,24 +107,49 @@ func collectApparentNames(repoRoot, relPath string) (map[string]string, error) {
 		}
 	}
 
-	return apparentNames, nil
+	return files, nil
 }
 
-func collectApparentNamesAndIncludes(f *build.File) (map[string]string, []string) {
+// Collects the mapping of module names (e.g. "rules_go") to user-configured apparent names (e.g.
+// "my_rules_go"). See https://bazel.build/external/module#repository_names_and_strict_deps for more
+// information on apparent names.
+func collectApparentNames(files []*build.File) map[string]string {
+	if files == nil {
+		return nil
+	}
 	apparentNames := make(map[string]string)
+	for _, bf := range files {
+		for name, apparentName := range collectApparentNamesInFile(bf) {
+			apparentNames[name] = apparentName
+		}
+	}
+	return apparentNames
+}
+
+func collectIncludes(f *build.File) []string {
 	var includeLabels []string
+	for _, dep := range f.Rules("") {
+		if dep.ExplicitName() != "" {
+			continue
+		}
+		if ident, ok := dep.Call.X.(*build.Ident); !ok || ident.Name != "include" {
+			continue
+		}
+		if len(dep.Call.List) != 1 {
+			continue
+		}
+		if str, ok := dep.Call.List[0].(*build.StringExpr); ok {
+			includeLabels = append(includeLabels, str.Value)
+		}
+	}
+	return includeLabels
+}
+
+func collectApparentNamesInFile(f *build.File) map[string]string {
+	apparentNames := make(map[string]string)
 
 	for _, dep := range f.Rules("") {
 		if dep.ExplicitName() == "" {
-			if ident, ok := dep.Call.X.(*build.Ident); !ok || ident.Name != "include" {
-				continue
-			}
-			if len(dep.Call.List) != 1 {
-				continue
-			}
-			if str, ok := dep.Call.List[0].(*build.StringExpr); ok {
-				includeLabels = append(includeLabels, str.Value)
-			}
 			continue
 		}
 		if dep.Kind() != "module" && dep.Kind() != "bazel_dep" {
@@ -141,5 +166,5 @@ func collectApparentNamesAndIncludes(f *build.File) (map[string]string, []string
 		}
 	}
 
-	return apparentNames, includeLabels
+	return apparentNames
 }
@@ -1,9 +1,11 @@
 package module
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/rules_go/go/runfiles"
 	"github.com/google/go-cmp/cmp"
 )
@@ -14,10 +16,11 @@ func TestCollectApparent(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	apparentNames, err := collectApparentNames(filepath.Dir(moduleFile), "MODULE.bazel")
+	files, err := ParseModuleFiles(filepath.Dir(moduleFile))
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	apparentNames := collectApparentNames(files)
 
 	expected := map[string]string{
 		"rules_bar":   "rules_bar",
@@ -33,12 +36,289 @@ func TestCollectApparent(t *testing.T) {
 }
 
 func TestCollectApparent_fileDoesNotExist(t *testing.T) {
-	_, err := collectApparentNames(t.TempDir(), "MODULE.bazel")
+	_, err := parseModuleSegments(t.TempDir(), "MODULE.bazel")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	_, err = collectApparentNames(t.TempDir(), "segment.MODULE.bazel")
+	_, err = parseModuleSegments(t.TempDir(), "segment.MODULE.bazel")
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
 }
+
+func TestExtractGoDeps(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"MODULE.bazel": `
+module(name = "test_module")
+
+bazel_dep(name = "gazelle", version = "0.40.0")
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.from_file(go_mod = "//:go.mod")
+go_deps.module(
+    path = "example.com/extra",
+    sum = "h1:abc=",
+    version = "v1.0.0",
+)
+use_repo(
+    go_deps,
+    "com_example_a",
+    my_extra = "com_example_extra",
+)
+
+include("//bazel:deps.MODULE.bazel")
+`,
+		"bazel/deps.MODULE.bazel": `
+go_deps_dev = use_extension("@gazelle//:extensions.bzl", "go_deps", dev_dependency = True)
+go_deps_dev.from_file(go_work = "//:go.work")
+use_repo(go_deps_dev, "com_example_c")
+
+other = use_extension("//:other.bzl", "other")
+use_repo(other, "com_example_b")
+`,
+		"go.mod": `module example.com/test
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+)
+`,
+		"go.work": `go 1.22
+
+use ./sub
+`,
+		"sub/go.mod": `module example.com/test/sub
+
+require (
+	example.com/c v1.0.0
+	example.com/test v0.0.0
+)
+`,
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	goDeps, err := extractGoDeps(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantGoDeps := &GoDeps{
+		GoModFiles:  []string{"go.mod"},
+		GoWorkFiles: []string{"go.work"},
+		Modules:     []GoDepsModule{{Path: "example.com/extra", Version: "v1.0.0", Sum: "h1:abc="}},
+		UseRepo: map[string]string{
+			"com_example_a":     "com_example_a",
+			"com_example_c":     "com_example_c",
+			"com_example_extra": "my_extra",
+		},
+	}
+	if diff := cmp.Diff(wantGoDeps, goDeps); diff != "" {
+		t.Errorf("unexpected go_deps (-want +got):\n%s", diff)
+	}
+
+	repos, err := goDeps.Repos(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRepos := []GoDepsRepo{
+		{ModulePath: "example.com/a", RepoName: "com_example_a", ApparentName: "com_example_a"},
+		{ModulePath: "example.com/b", RepoName: "com_example_b"},
+		{ModulePath: "example.com/c", RepoName: "com_example_c", ApparentName: "com_example_c"},
+		{ModulePath: "example.com/extra", RepoName: "com_example_extra", ApparentName: "my_extra"},
+	}
+	if diff := cmp.Diff(wantRepos, repos); diff != "" {
+		t.Errorf("unexpected repos (-want +got):\n%s", diff)
+	}
+}
+
+// extractGoDeps parses the MODULE.bazel files in dir and reads go_deps
+// from them.
+func extractGoDeps(dir string) (*GoDeps, error) {
+	files, err := ParseModuleFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractGoDeps(files)
+}
+
+func TestExtractGoDeps_noGoDeps(t *testing.T) {
+	dir := t.TempDir()
+	if goDeps, err := extractGoDeps(dir); err != nil || goDeps != nil {
+		t.Errorf("without MODULE.bazel: got %v, %v; want nil, nil", goDeps, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "MODULE.bazel"), []byte(`bazel_dep(name = "rules_go", version = "0.50.0")`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if goDeps, err := extractGoDeps(dir); err != nil || goDeps != nil {
+		t.Errorf("without go_deps: got %v, %v; want nil, nil", goDeps, err)
+	}
+}
+
+func TestUpdateGoDeps(t *testing.T) {
+	dir := t.TempDir()
+	moduleFile := filepath.Join(dir, "MODULE.bazel")
+	if err := os.WriteFile(moduleFile, []byte(`module(name = "test_module")
+bazel_dep(name='rules_go',version = "0.50.0")  # hand-written
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.module(
+    path = "example.com/a",
+    sum = "h1:old=",
+    version = "v1.0.0",
+)
+use_repo(go_deps, "com_example_a")
+`), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := UpdateGoDeps(dir, GoDepsUpdate{
+		Modules: []GoDepsModule{
+			{Path: "example.com/a", Version: "v1.1.0", Sum: "h1:new="},
+			{Path: "example.com/b", Version: "v2.0.0", Sum: "h1:b="},
+		},
+		UsedRepos: map[string]bool{"com_example_b": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("got changed = false; want true")
+	}
+	got, err := os.ReadFile(moduleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Statements other than the updated tags and use_repo keep their
+	// original formatting.
+	want := `module(name = "test_module")
+bazel_dep(name='rules_go',version = "0.50.0")  # hand-written
+
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+go_deps.module(
+    path = "example.com/a",
+    sum = "h1:new=",
+    version = "v1.1.0",
+)
+go_deps.module(
+    path = "example.com/b",
+    sum = "h1:b=",
+    version = "v2.0.0",
+)
+use_repo(go_deps, "com_example_a", "com_example_b")
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("unexpected MODULE.bazel (-want +got):\n%s", diff)
+	}
+
+	// Without changes, the file is left alone.
+	changed, err = UpdateGoDeps(dir, GoDepsUpdate{UsedRepos: map[string]bool{"com_example_b": true}})
+	if err != nil || changed {
+		t.Errorf("second update: got %v, %v; want false, nil", changed, err)
+	}
+}
+
+func extractRepoMapping(dir string) (*label.RepoMapping, error) {
+	files, err := ParseModuleFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return ExtractRepoMapping(dir, files)
+}
+
+func TestExtractRepoMapping(t *testing.T) {
+	dir := t.TempDir()
+	if m, err := extractRepoMapping(dir); err != nil || m != nil {
+		t.Errorf("without MODULE.bazel: got %v, %v; want nil, nil", m, err)
+	}
+	files := map[string]string{
+		"MODULE.bazel": `
+module(name = "test_module", repo_name = "my_module")
+
+bazel_dep(name = "gazelle", version = "0.40.0")
+bazel_dep(name = "rules_go", version = "0.50.0", repo_name = "io_bazel_rules_go")
+
+include("//:deps.MODULE.bazel")
+
+go_sdk = use_extension("@io_bazel_rules_go//go:extensions.bzl", "go_sdk")
+use_repo(go_sdk, "go_toolchains", sdk = "go_default_sdk")
+
+local_ext = use_extension("//:ext.bzl", "local_ext")
+use_repo(local_ext, "local_repo")
+`,
+		"deps.MODULE.bazel": `
+go_deps = use_extension("@gazelle//:extensions.bzl", "go_deps")
+use_repo(go_deps, "com_example_foo")
+
+http_archive = use_repo_rule("@bazel_tools//tools/build_defs/repo:http.bzl", "http_archive")
+http_archive(name = "archive")
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		desc, lock string
+		want       map[string]string
+	}{
+		{
+			desc: "bazel8",
+			want: map[string]string{
+				"my_module":         "",
+				"gazelle":           "gazelle+",
+				"io_bazel_rules_go": "rules_go+",
+				"go_toolchains":     "rules_go++go_sdk+go_toolchains",
+				"sdk":               "rules_go++go_sdk+go_default_sdk",
+				"local_repo":        "+local_ext+local_repo",
+				"com_example_foo":   "gazelle++go_deps+com_example_foo",
+				"archive":           "+_repo_rules+archive",
+			},
+		},
+		{
+			desc: "bazel7",
+			lock: `{"lockFileVersion": 13}`,
+			want: map[string]string{
+				"my_module":         "",
+				"gazelle":           "gazelle~",
+				"io_bazel_rules_go": "rules_go~",
+				"go_toolchains":     "rules_go~~go_sdk~go_toolchains",
+				"sdk":               "rules_go~~go_sdk~go_default_sdk",
+				"local_repo":        "_main~local_ext~local_repo",
+				"com_example_foo":   "gazelle~~go_deps~com_example_foo",
+				"archive":           "_main~_repo_rules~archive",
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			lockFile := filepath.Join(dir, "MODULE.bazel.lock")
+			os.Remove(lockFile)
+			if tc.lock != "" {
+				if err := os.WriteFile(lockFile, []byte(tc.lock), 0o666); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m, err := extractRepoMapping(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for apparent, want := range tc.want {
+				if got, ok := m.CanonicalName(apparent); !ok || got != want {
+					t.Errorf("CanonicalName(%q) = %q, %v; want %q, true", apparent, got, ok, want)
+				}
+			}
+			if got, ok := m.ApparentName("rules_go" + tc.want["gazelle"][len("gazelle"):]); !ok || got != "io_bazel_rules_go" {
+				t.Errorf("ApparentName of rules_go = %q, %v; want io_bazel_rules_go, true", got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package module
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/buildtools/build"
+)
+
+// plusLockFileVersion is the first version of MODULE.bazel.lock written by
+// a Bazel release (8.0) that separates parts of canonical repository names
+// with "+" instead of "~".
+const plusLockFileVersion = 16
+
+// ExtractRepoMapping computes the repository mapping of the main repository
+// from the files returned by ParseModuleFiles. It returns nil if there are
+// no files because MODULE.bazel doesn't exist.
+//
+// Canonical names are computed the way Bazel 7.1 and later compute them:
+// "name+" for modules and "module++extension+repo" for repositories created
+// by module extensions. "~" is used instead of "+" if MODULE.bazel.lock was
+// written by Bazel 7. Canonical names may still differ from Bazel's, for
+// example, when a module has multiple versions; a mapping written by
+// `bazel mod dump_repo_mapping ""` is more accurate (see
+// label.ReadRepoMapping).
+func ExtractRepoMapping(repoRoot string, files []*build.File) (*label.RepoMapping, error) {
+	if files == nil {
+		return nil, nil
+	}
+	sep := canonicalNameSeparator(repoRoot)
+
+	apparentToCanonical := map[string]string{"bazel_tools": "bazel_tools"}
+	moduleOfApparent := make(map[string]string)
+	for _, f := range files {
+		for _, r := range f.Rules("") {
+			name := r.AttrString("name")
+			if name == "" || (r.Kind() != "module" && r.Kind() != "bazel_dep") {
+				continue
+			}
+			apparent := name
+			if repoName := r.AttrString("repo_name"); repoName != "" {
+				apparent = repoName
+			}
+			if r.Kind() == "module" {
+				apparentToCanonical[apparent] = ""
+				moduleOfApparent[apparent] = ""
+			} else {
+				apparentToCanonical[apparent] = name + sep
+				moduleOfApparent[apparent] = name
+			}
+		}
+	}
+
+	// Repositories created by module extensions and repository rules are
+	// named after the module that owns the extension or rule.
+	for _, f := range files {
+		extPrefixes := make(map[string]string)
+		for _, stmt := range f.Stmt {
+			assign, ok := stmt.(*build.AssignExpr)
+			if !ok {
+				continue
+			}
+			lhs, ok := assign.LHS.(*build.Ident)
+			if !ok {
+				continue
+			}
+			call, ok := assign.RHS.(*build.CallExpr)
+			if !ok || len(call.List) < 2 {
+				continue
+			}
+			fn, ok := call.X.(*build.Ident)
+			if !ok || (fn.Name != "use_extension" && fn.Name != "use_repo_rule") {
+				continue
+			}
+			bzlFile, ok1 := call.List[0].(*build.StringExpr)
+			extName, ok2 := call.List[1].(*build.StringExpr)
+			if !ok1 || !ok2 {
+				continue
+			}
+			// Repository rules belong to the module that uses them, which is
+			// the main module. Extensions belong to the module defining them.
+			module := ""
+			if fn.Name == "use_extension" {
+				l, err := label.Parse(bzlFile.Value)
+				if err != nil {
+					continue
+				}
+				if l.Repo != "" && l.Repo != "@" {
+					if module, ok = moduleOfApparent[l.Repo]; !ok {
+						continue
+					}
+				}
+			}
+			// The main module's canonical name is empty in Bazel 8 and "_main"
+			// in Bazel 7.
+			owner := module + sep
+			if module == "" {
+				owner = ""
+				if sep == "~" {
+					owner = "_main"
+				}
+			}
+			if fn.Name == "use_extension" {
+				extPrefixes[lhs.Name] = owner + sep + extName.Value + sep
+			} else {
+				extPrefixes[lhs.Name] = owner + sep + "_repo_rules" + sep
+			}
+		}
+
+		for _, stmt := range f.Stmt {
+			call, ok := stmt.(*build.CallExpr)
+			if !ok {
+				continue
+			}
+			ident, ok := call.X.(*build.Ident)
+			if !ok {
+				continue
+			}
+			if prefix, ok := extPrefixes[ident.Name]; ok {
+				// A repository rule called directly.
+				if name, ok := callAttr(call, "name").(*build.StringExpr); ok {
+					apparentToCanonical[name.Value] = prefix + name.Value
+				}
+				continue
+			}
+			if ident.Name != "use_repo" || len(call.List) == 0 {
+				continue
+			}
+			proxy, ok := call.List[0].(*build.Ident)
+			if !ok {
+				continue
+			}
+			prefix, ok := extPrefixes[proxy.Name]
+			if !ok {
+				continue
+			}
+			for _, arg := range call.List[1:] {
+				switch arg := arg.(type) {
+				case *build.StringExpr:
+					apparentToCanonical[arg.Value] = prefix + arg.Value
+				case *build.AssignExpr:
+					lhs, ok1 := arg.LHS.(*build.Ident)
+					rhs, ok2 := arg.RHS.(*build.StringExpr)
+					if ok1 && ok2 {
+						apparentToCanonical[lhs.Name] = prefix + rhs.Value
+					}
+				}
+			}
+		}
+	}
+	return label.NewRepoMapping(apparentToCanonical), nil
+}
+
+// canonicalNameSeparator returns the separator Bazel uses in canonical
+// repository names, based on the version of MODULE.bazel.lock. If there's no
+// lock file, the current separator, "+", is returned.
+func canonicalNameSeparator(repoRoot string) string {
+	data, err := os.ReadFile(filepath.Join(repoRoot, "MODULE.bazel.lock"))
+	if err != nil {
+		return "+"
+	}
+	var lock struct {
+		LockFileVersion int `json:"lockFileVersion"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil || lock.LockFileVersion >= plusLockFileVersion {
+		return "+"
+	}
+	return "~"
+}
@@ -235,6 +235,79 @@ func (l Label) BzlExpr() bzl.Expr {
 	}
 }
 
+// Style is a way of writing labels of targets in the main repository.
+type Style int
+
+const (
+	// NoStyle leaves labels as they are written.
+	NoStyle Style = iota
+
+	// ShortStyle writes labels as briefly as possible: ":name" for targets in
+	// the same package, and "//pkg" instead of "//pkg:pkg".
+	ShortStyle
+
+	// RelativeStyle writes ":name" for targets in the same package and
+	// "//pkg:name" for targets in other packages.
+	RelativeStyle
+
+	// AbsoluteStyle writes "//pkg:name" for all targets.
+	AbsoluteStyle
+)
+
+var styleNames = map[Style]string{
+	NoStyle:       "",
+	ShortStyle:    "short",
+	RelativeStyle: "relative",
+	AbsoluteStyle: "absolute",
+}
+
+// ParseStyle returns the Style with the given name: "short", "relative", or
+// "absolute". The empty string is NoStyle.
+func ParseStyle(s string) (Style, error) {
+	for style, name := range styleNames {
+		if s == name {
+			return style, nil
+		}
+	}
+	return NoStyle, fmt.Errorf("unknown label style %q; expected short, relative, or absolute", s)
+}
+
+func (s Style) String() string {
+	return styleNames[s]
+}
+
+// Normalize writes s, a label in a build file in the package pkg of the main
+// repository, in the given style. repo is the name of the main repository;
+// labels that name it explicitly, like "@repo//pkg:name", are written without
+// it. s is returned unchanged if style is NoStyle, if s doesn't begin with
+// ":", "//", or "@" (it may be a file name), or if it refers to a target in
+// another repository.
+func Normalize(s, repo, pkg string, style Style) string {
+	if style == NoStyle || !(strings.HasPrefix(s, ":") || strings.HasPrefix(s, "//") || strings.HasPrefix(s, "@")) {
+		return s
+	}
+	l, err := Parse(s)
+	if err != nil {
+		return s
+	}
+	if l.Relative {
+		l = l.Abs("", pkg)
+	} else if l.Repo == "" || l.Repo == "@" || (l.Repo == repo && !l.Canonical) {
+		l = New("", l.Pkg, l.Name)
+	} else {
+		return s
+	}
+
+	if style != AbsoluteStyle && l.Pkg == pkg {
+		return l.Rel("", pkg).String()
+	}
+	str := l.String()
+	if style != ShortStyle && path.Base(l.Pkg) == l.Name {
+		str += ":" + l.Name
+	}
+	return str
+}
+
 var nonWordRe = regexp.MustCompile(`\W+`)
 
 // ImportPathToBazelRepoName converts a Go import path into a bazel repo name
@@ -152,3 +152,29 @@ func TestParseStringRoundtrip(t *testing.T) {
 		}
 	}
 }
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		s, pkg string
+		style  Style
+		want   string
+	}{
+		{s: "//foo:foo", pkg: "bar", style: NoStyle, want: "//foo:foo"},
+		{s: "//foo:foo", pkg: "bar", style: ShortStyle, want: "//foo"},
+		{s: "@repo//foo", pkg: "bar", style: ShortStyle, want: "//foo"},
+		{s: "@//foo:baz", pkg: "bar", style: ShortStyle, want: "//foo:baz"},
+		{s: "//bar:baz", pkg: "bar", style: ShortStyle, want: ":baz"},
+		{s: "//bar", pkg: "bar", style: RelativeStyle, want: ":bar"},
+		{s: "//foo", pkg: "bar", style: RelativeStyle, want: "//foo:foo"},
+		{s: ":baz", pkg: "bar", style: AbsoluteStyle, want: "//bar:baz"},
+		{s: ":bar", pkg: "bar", style: AbsoluteStyle, want: "//bar:bar"},
+		{s: "@repo", pkg: "", style: ShortStyle, want: ":repo"},
+		{s: "@other//foo:foo", pkg: "bar", style: ShortStyle, want: "@other//foo:foo"},
+		{s: "@@repo//foo:foo", pkg: "bar", style: ShortStyle, want: "@@repo//foo:foo"},
+		{s: "baz.go", pkg: "bar", style: AbsoluteStyle, want: "baz.go"},
+	} {
+		if got := Normalize(tc.s, "repo", tc.pkg, tc.style); got != tc.want {
+			t.Errorf("Normalize(%q, %q, %q, %v) = %q; want %q", tc.s, "repo", tc.pkg, tc.style, got, tc.want)
+		}
+	}
+}
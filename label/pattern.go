@@ -0,0 +1,222 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package label
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+)
+
+// A Pattern is a Bazel target pattern, which names a set of targets. For
+// example, "//foo/..." matches all targets in the package foo and packages
+// beneath it, and "//foo:all" matches all targets in the package foo.
+// See https://bazel.build/run/build#specifying-build-targets.
+type Pattern struct {
+	// Repo is the repository name, as in Label. If omitted, the pattern
+	// matches targets in the current repository.
+	Repo string
+
+	// Pkg is the package name or, if Recursive is set, the name of the
+	// directory containing the packages the pattern matches.
+	Pkg string
+
+	// Name is the name of the target the pattern matches. It may be a
+	// wildcard, "all", "*", or "all-targets", which matches every target in
+	// the package. Name is empty if Recursive is set and the pattern doesn't
+	// name targets, as in "//foo/...".
+	Name string
+
+	// Recursive indicates the pattern matches targets in Pkg and all packages
+	// beneath it, as in "//foo/...".
+	Recursive bool
+
+	// Relative indicates the package is relative to the current directory,
+	// as in "foo/..." or ":all".
+	Relative bool
+
+	// Canonical indicates whether the repository name is canonical, as in
+	// Label.
+	Canonical bool
+
+	// Negative indicates the pattern removes targets from the set named by
+	// earlier patterns, as in "-//foo/bar/...". Matches ignores it; see
+	// MatchesPatterns.
+	Negative bool
+}
+
+// ParsePattern reads a target pattern from a string. Labels are valid
+// patterns that match one target.
+func ParsePattern(s string) (Pattern, error) {
+	origStr := s
+	var p Pattern
+	if strings.HasPrefix(s, "-") {
+		p.Negative = true
+		s = s[len("-"):]
+	}
+	if strings.HasPrefix(s, "@@") {
+		p.Canonical = true
+		s = s[len("@"):]
+	}
+	if strings.HasPrefix(s, "@") {
+		endRepo := strings.Index(s, "//")
+		if endRepo < 0 {
+			// "@repo" is the same as "@repo//:repo".
+			endRepo = len(s)
+			s += "//:" + s[len("@"):]
+		}
+		p.Repo = s[len("@"):endRepo]
+		if p.Repo == "" {
+			p.Repo = "@"
+		}
+		if !labelRepoRegexp.MatchString(p.Repo) {
+			return Pattern{}, fmt.Errorf("pattern parse error: repository has invalid characters: %q", origStr)
+		}
+		s = s[endRepo:]
+	}
+	if strings.HasPrefix(s, "//") {
+		s = s[len("//"):]
+	} else if p.Repo != "" {
+		return Pattern{}, fmt.Errorf("pattern parse error: expected // after repository name: %q", origStr)
+	} else {
+		p.Relative = true
+	}
+
+	pkg, name, hasName := strings.Cut(s, ":")
+	if pkg == "..." || strings.HasSuffix(pkg, "/...") {
+		p.Recursive = true
+		pkg = strings.TrimSuffix(strings.TrimSuffix(pkg, "..."), "/")
+	}
+	if !labelPkgRegexp.MatchString(pkg) || strings.Contains(pkg, "...") {
+		return Pattern{}, fmt.Errorf("pattern parse error: package has invalid characters: %q", origStr)
+	}
+	if hasName && (name == "" || !labelNameRegexp.MatchString(name)) {
+		return Pattern{}, fmt.Errorf("pattern parse error: invalid target name: %q", origStr)
+	}
+	if !hasName && !p.Recursive {
+		if pkg == "" {
+			return Pattern{}, fmt.Errorf("pattern parse error: empty package and name: %q", origStr)
+		}
+		name = path.Base(pkg)
+	}
+	if p.Recursive && name != "" && !isWildcardName(name) {
+		return Pattern{}, fmt.Errorf("pattern parse error: recursive patterns must match all targets in each package: %q", origStr)
+	}
+	p.Pkg = pkg
+	p.Name = name
+	return p, nil
+}
+
+func (p Pattern) String() string {
+	var b strings.Builder
+	if p.Negative {
+		b.WriteString("-")
+	}
+	if !p.Relative {
+		repo := p.Repo
+		if repo != "" && repo != "@" {
+			repo = "@" + repo
+		}
+		if p.Canonical && strings.HasPrefix(repo, "@") {
+			repo = "@" + repo
+		}
+		b.WriteString(repo + "//")
+	}
+	b.WriteString(p.Pkg)
+	if p.Recursive {
+		if p.Pkg != "" {
+			b.WriteString("/")
+		}
+		b.WriteString("...")
+	}
+	if p.Name != "" && (p.Recursive || path.Base(p.Pkg) != p.Name) {
+		b.WriteString(":" + p.Name)
+	}
+	return b.String()
+}
+
+// Abs returns an absolute pattern for a relative pattern, where repo and pkg
+// are the repository and directory the pattern is relative to. If p is
+// already absolute, it's returned unchanged.
+func (p Pattern) Abs(repo, pkg string) Pattern {
+	if !p.Relative {
+		return p
+	}
+	p.Repo = repo
+	p.Pkg = path.Join(pkg, p.Pkg)
+	if p.Pkg == "." {
+		p.Pkg = ""
+	}
+	p.Relative = false
+	return p
+}
+
+// Matches returns whether the pattern matches the target named by l,
+// ignoring p.Negative. Neither p nor l may be relative. Repository names
+// "" and "@" both refer to the main repository; other repository names must
+// be the same, and both must be canonical or apparent.
+func (p Pattern) Matches(l Label) bool {
+	if p.Relative {
+		log.Panicf("p must not be relative: %s", p)
+	}
+	if l.Relative {
+		log.Panicf("l must not be relative: %s", l)
+	}
+	return p.matchesRepo(l.Repo, l.Canonical) &&
+		p.MatchesPackage(l.Pkg) &&
+		(isWildcardName(p.Name) || p.Name == l.Name)
+}
+
+// MatchesPackage returns whether the pattern may match targets in the
+// package pkg in the pattern's repository. p must not be relative.
+func (p Pattern) MatchesPackage(pkg string) bool {
+	if p.Relative {
+		log.Panicf("p must not be relative: %s", p)
+	}
+	if p.Recursive {
+		return pathtools.HasPrefix(pkg, p.Pkg)
+	}
+	return pkg == p.Pkg
+}
+
+func (p Pattern) matchesRepo(repo string, canonical bool) bool {
+	isMain := func(r string) bool { return r == "" || r == "@" }
+	if isMain(p.Repo) || isMain(repo) {
+		return isMain(p.Repo) && isMain(repo)
+	}
+	return p.Repo == repo && p.Canonical == canonical
+}
+
+// MatchesPatterns returns whether l is in the set of targets named by
+// patterns, evaluated in order as Bazel evaluates patterns on the command
+// line: each pattern adds the targets it matches to the set, or removes them
+// if it's negative. None of the patterns may be relative.
+func MatchesPatterns(patterns []Pattern, l Label) bool {
+	matched := false
+	for _, p := range patterns {
+		if p.Matches(l) {
+			matched = !p.Negative
+		}
+	}
+	return matched
+}
+
+func isWildcardName(name string) bool {
+	return name == "" || name == "all" || name == "*" || name == "all-targets"
+}
@@ -0,0 +1,159 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package label
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	for _, tc := range []struct {
+		str     string
+		want    Pattern
+		wantErr bool
+	}{
+		{str: "", wantErr: true},
+		{str: "-", wantErr: true},
+		{str: "@a:b", wantErr: true},
+		{str: "@a//", wantErr: true},
+		{str: "//a/...:b", wantErr: true},
+		{str: "//a/.../b", wantErr: true},
+		{str: "//a:", wantErr: true},
+		{str: "//a", want: Pattern{Pkg: "a", Name: "a"}},
+		{str: "//a:b", want: Pattern{Pkg: "a", Name: "b"}},
+		{str: "//a:all", want: Pattern{Pkg: "a", Name: "all"}},
+		{str: "//a:*", want: Pattern{Pkg: "a", Name: "*"}},
+		{str: "//...", want: Pattern{Recursive: true}},
+		{str: "//a/b/...", want: Pattern{Pkg: "a/b", Recursive: true}},
+		{str: "//a/...:all-targets", want: Pattern{Pkg: "a", Name: "all-targets", Recursive: true}},
+		{str: "-//a/...", want: Pattern{Pkg: "a", Recursive: true, Negative: true}},
+		{str: "@r//...", want: Pattern{Repo: "r", Recursive: true}},
+		{str: "@r", want: Pattern{Repo: "r", Name: "r"}},
+		{str: "@//a:all", want: Pattern{Repo: "@", Pkg: "a", Name: "all"}},
+		{str: "@@r+//a/...", want: Pattern{Repo: "r+", Pkg: "a", Recursive: true, Canonical: true}},
+		{str: "...", want: Pattern{Recursive: true, Relative: true}},
+		{str: "a/...", want: Pattern{Pkg: "a", Recursive: true, Relative: true}},
+		{str: ":all", want: Pattern{Name: "all", Relative: true}},
+		{str: "a:b", want: Pattern{Pkg: "a", Name: "b", Relative: true}},
+		{str: "-a/...", want: Pattern{Pkg: "a", Recursive: true, Relative: true, Negative: true}},
+	} {
+		got, err := ParsePattern(tc.str)
+		if err != nil && !tc.wantErr {
+			t.Errorf("for string %q: got error %s ; want success", tc.str, err)
+			continue
+		}
+		if err == nil && tc.wantErr {
+			t.Errorf("for string %q: got pattern %s ; want error", tc.str, got)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("for string %q: got %#v ; want %#v", tc.str, got, tc.want)
+		}
+		if tc.wantErr {
+			continue
+		}
+		if again, err := ParsePattern(got.String()); err != nil || !reflect.DeepEqual(again, got) {
+			t.Errorf("for string %q: String() = %q, which doesn't parse to the same pattern", tc.str, got.String())
+		}
+	}
+}
+
+func TestPatternAbs(t *testing.T) {
+	for _, tc := range []struct {
+		str, repo, pkg, want string
+	}{
+		{str: "...", pkg: "", want: "//..."},
+		{str: "...", pkg: "a", want: "//a/..."},
+		{str: "b/...", repo: "r", pkg: "a", want: "@r//a/b/..."},
+		{str: ":all", pkg: "a", want: "//a:all"},
+		{str: "//c/...", pkg: "a", want: "//c/..."},
+	} {
+		p, err := ParsePattern(tc.str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Abs(tc.repo, tc.pkg).String(); got != tc.want {
+			t.Errorf("%q.Abs(%q, %q) = %q; want %q", tc.str, tc.repo, tc.pkg, got, tc.want)
+		}
+	}
+}
+
+func TestPatternMatches(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, label string
+		want           bool
+	}{
+		{pattern: "//...", label: "//a/b:c", want: true},
+		{pattern: "//...", label: "@r//a:b", want: false},
+		{pattern: "@//...", label: "//a:b", want: true},
+		{pattern: "//a/...", label: "//a:b", want: true},
+		{pattern: "//a/...", label: "//a/b/c:d", want: true},
+		{pattern: "//a/...", label: "//ab:c", want: false},
+		{pattern: "//a:all", label: "//a:b", want: true},
+		{pattern: "//a:*", label: "//a/b:c", want: false},
+		{pattern: "//a:b", label: "//a:b", want: true},
+		{pattern: "//a:b", label: "//a:c", want: false},
+		{pattern: "//a", label: "//a", want: true},
+		{pattern: "@r//...", label: "@r//a:b", want: true},
+		{pattern: "@r//...", label: "@s//a:b", want: false},
+		{pattern: "@@r+//...", label: "@r+//a:b", want: false},
+		{pattern: "@@r+//...", label: "@@r+//a:b", want: true},
+		{pattern: "-//a/...", label: "//a:b", want: true},
+	} {
+		p, err := ParsePattern(tc.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := Parse(tc.label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Matches(l); got != tc.want {
+			t.Errorf("%q.Matches(%q) = %v; want %v", tc.pattern, tc.label, got, tc.want)
+		}
+	}
+}
+
+func TestMatchesPatterns(t *testing.T) {
+	var patterns []Pattern
+	for _, s := range []string{"//a/...", "-//a/b/...", "//a/b/c:d"} {
+		p, err := ParsePattern(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patterns = append(patterns, p)
+	}
+	for _, tc := range []struct {
+		label string
+		want  bool
+	}{
+		{label: "//a:x", want: true},
+		{label: "//a/c:x", want: true},
+		{label: "//a/b:x", want: false},
+		{label: "//a/b/c:x", want: false},
+		{label: "//a/b/c:d", want: true},
+		{label: "//z:x", want: false},
+	} {
+		l, err := Parse(tc.label)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := MatchesPatterns(patterns, l); got != tc.want {
+			t.Errorf("MatchesPatterns(%q) = %v; want %v", tc.label, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package label
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RepoMapping translates between the apparent repository names used in
+// labels written in the main repository (for example, "@rules_go//go") and
+// the canonical repository names Bazel assigns to the same repositories
+// (for example, "@@rules_go+//go"). The main repository's canonical name is
+// empty.
+//
+// See https://bazel.build/external/overview#apparent-repo-name for more
+// information on repository names.
+//
+// A nil *RepoMapping is valid and has no mappings.
+type RepoMapping struct {
+	apparentToCanonical map[string]string
+	canonicalToApparent map[string]string
+}
+
+// NewRepoMapping returns a RepoMapping that maps apparent repository names
+// to the canonical names in apparentToCanonical. If several apparent names
+// map to the same canonical name, the lexically smallest is used when
+// translating labels back.
+func NewRepoMapping(apparentToCanonical map[string]string) *RepoMapping {
+	m := &RepoMapping{
+		apparentToCanonical: make(map[string]string, len(apparentToCanonical)),
+		canonicalToApparent: make(map[string]string, len(apparentToCanonical)),
+	}
+	for apparent, canonical := range apparentToCanonical {
+		m.apparentToCanonical[apparent] = canonical
+		if prev, ok := m.canonicalToApparent[canonical]; !ok || apparent < prev {
+			m.canonicalToApparent[canonical] = apparent
+		}
+	}
+	// Labels in the main repository are written without a repository name.
+	m.apparentToCanonical[""] = ""
+	m.canonicalToApparent[""] = ""
+	return m
+}
+
+// ReadRepoMapping reads a repository mapping written by
+// `bazel mod dump_repo_mapping ""`. The file contains a JSON object mapping
+// apparent names to canonical names for each repository the command was
+// given; only the first object, which describes the main repository, is used.
+func ReadRepoMapping(path string) (*RepoMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var apparentToCanonical map[string]string
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(&apparentToCanonical); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewRepoMapping(apparentToCanonical), nil
+}
+
+// CanonicalName returns the canonical name of the repository with the given
+// apparent name and whether the name is known.
+func (m *RepoMapping) CanonicalName(apparent string) (string, bool) {
+	if apparent == "" || apparent == "@" {
+		return "", true
+	}
+	if m == nil {
+		return "", false
+	}
+	canonical, ok := m.apparentToCanonical[apparent]
+	return canonical, ok
+}
+
+// ApparentName returns an apparent name of the repository with the given
+// canonical name and whether the repository is visible from the main
+// repository.
+func (m *RepoMapping) ApparentName(canonical string) (string, bool) {
+	if canonical == "" || canonical == "@" {
+		return "", true
+	}
+	if m == nil {
+		return "", false
+	}
+	apparent, ok := m.canonicalToApparent[canonical]
+	return apparent, ok
+}
+
+// Canonical returns l with a canonical repository name. Labels in the main
+// repository are returned as "@@//pkg:name". Relative labels and labels that
+// already have canonical names are returned unchanged. The second result is
+// false, and l is returned unchanged, if the repository isn't known.
+func (m *RepoMapping) Canonical(l Label) (Label, bool) {
+	if l.Relative || l.Canonical {
+		return l, true
+	}
+	canonical, ok := m.CanonicalName(l.Repo)
+	if !ok {
+		return l, false
+	}
+	if canonical == "" {
+		canonical = "@"
+	}
+	return Label{Repo: canonical, Pkg: l.Pkg, Name: l.Name, Canonical: true}, true
+}
+
+// Apparent returns l with an apparent repository name, as it would be
+// written in the main repository. Labels in the main repository are
+// returned without a repository name. Labels that don't have canonical
+// names are returned unchanged. The second result is false, and l is
+// returned unchanged, if the repository isn't visible from the main
+// repository.
+func (m *RepoMapping) Apparent(l Label) (Label, bool) {
+	if !l.Canonical {
+		return l, true
+	}
+	apparent, ok := m.ApparentName(l.Repo)
+	if !ok {
+		return l, false
+	}
+	return Label{Repo: apparent, Pkg: l.Pkg, Name: l.Name}, true
+}
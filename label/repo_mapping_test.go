@@ -0,0 +1,70 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package label
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepoMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo_mapping.json")
+	data := `{"":"","my_module":"","bazel_tools":"bazel_tools","my_rules_go":"rules_go+","com_example_foo":"gazelle++go_deps+com_example_foo"}
+{"":"rules_go+","io_bazel_rules_go":"rules_go+"}
+`
+	if err := os.WriteFile(path, []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadRepoMapping(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		apparent, canonical string
+	}{
+		{apparent: "//foo:bar", canonical: "@@//foo:bar"},
+		{apparent: "@my_rules_go//go", canonical: "@@rules_go+//go"},
+		{apparent: "@com_example_foo//:foo", canonical: "@@gazelle++go_deps+com_example_foo//:foo"},
+	} {
+		l, err := Parse(tc.apparent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := m.Canonical(l); !ok || got.String() != tc.canonical {
+			t.Errorf("Canonical(%q) = %q, %v; want %q, true", tc.apparent, got, ok, tc.canonical)
+		}
+		c, err := Parse(tc.canonical)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := m.Apparent(c); !ok || got.String() != tc.apparent {
+			t.Errorf("Apparent(%q) = %q, %v; want %q, true", tc.canonical, got, ok, tc.apparent)
+		}
+	}
+
+	if got, ok := m.Apparent(Label{Repo: "other+", Name: "x", Canonical: true}); ok {
+		t.Errorf("Apparent of invisible repository = %q, true; want false", got)
+	}
+	if got, ok := m.Canonical(Label{Repo: "io_bazel_rules_go", Name: "x"}); ok {
+		t.Errorf("Canonical of unknown repository = %q, true; want false", got)
+	}
+	var nilMapping *RepoMapping
+	if got, ok := nilMapping.Apparent(Label{Repo: "@", Pkg: "foo", Name: "foo", Canonical: true}); !ok || got.String() != "//foo" {
+		t.Errorf("nil Apparent(@@//foo) = %q, %v; want //foo, true", got, ok)
+	}
+}
@@ -30,9 +30,9 @@ import (
 
 	"github.com/bazelbuild/bazel-gazelle/config"
 	gzflag "github.com/bazelbuild/bazel-gazelle/flag"
-	"github.com/bazelbuild/bazel-gazelle/internal/module"
 	"github.com/bazelbuild/bazel-gazelle/internal/version"
 	"github.com/bazelbuild/bazel-gazelle/language/proto"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
 	"github.com/bazelbuild/bazel-gazelle/repo"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 	bzl "github.com/bazelbuild/buildtools/build"
@@ -125,6 +125,12 @@ type goConfig struct {
 	// in internal packages.
 	submodules []moduleRepo
 
+	// goDepsMissingRepos maps the names of repositories created by the go_deps
+	// module extension but not listed in use_repo in MODULE.bazel to the paths
+	// of the modules they provide. Dependencies on these repositories can't be
+	// built until they are made visible.
+	goDepsMissingRepos map[string]string
+
 	// testMode determines how go_test targets are generated.
 	testMode testMode
 
@@ -134,6 +140,11 @@ type goConfig struct {
 	// line.
 	buildDirectivesAttr, buildExternalAttr, buildExtraArgsAttr, buildFileGenerationAttr, buildFileNamesAttr, buildFileProtoModeAttr, buildTagsAttr string
 
+	// offlineImport is true when go_repository rules are imported from go.mod
+	// without running the go command or accessing the network. Set with
+	// -offline.
+	offlineImport bool
+
 	// goSearch is a list of additional directories that may contain Go libraries.
 	// Subdirectories within these roots may be indexed when lazy indexing
 	// is enabled. Each directory has an associated prefix, specified as part
@@ -141,6 +152,27 @@ type goConfig struct {
 	// '# gazelle:go_search replace/b example.com/b', and Gazelle sees an
 	// import of 'example.com/b/p', Gazelle indexes 'replace/b/p'.
 	goSearch []goSearch
+
+	// toolsGoMod is the path of a go.mod file whose tool directives are
+	// exposed as alias targets in the package toolsRel. toolsGoMod is empty
+	// if no aliases should be generated. Set with # gazelle:go_tool_aliases.
+	toolsGoMod, toolsRel string
+
+	// workModules lists the modules used by the go.work file in the
+	// repository root directory. Each module's directory is a module root:
+	// its prefix is the module path, and imports of packages in these modules
+	// are resolved within the repository.
+	workModules []workModule
+
+	// workModule is the module in workModules whose directory contains the
+	// current directory, or nil. External imports are resolved against its
+	// requirements.
+	workModule *workModule
+
+	// vendor describes the vendor directory of the module containing the
+	// current directory, read from vendor/modules.txt. It's nil if the module
+	// has no vendor directory or modules.txt.
+	vendor *vendorInfo
 }
 
 // testMode determines how go_test rules are generated.
@@ -395,6 +427,7 @@ func (*goLang) KnownDirectives() []string {
 		"go_proto_compilers",
 		"go_search",
 		"go_test",
+		"go_tool_aliases",
 		"go_visibility",
 		"importmap_prefix",
 		"prefix",
@@ -471,6 +504,12 @@ func (*goLang) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config) {
 			"",
 			"Sets the build_tags attribute for the generated go_repository rule(s).")
 	}
+	if cmd == "update-repos" || cmd == "verify-repos" {
+		fs.BoolVar(&gc.offlineImport,
+			"offline",
+			false,
+			"When importing from go.mod, builds the module graph from go.mod and go.sum files in the module cache and file:// proxies without running the go command or accessing the network.")
+	}
 	c.Exts[goName] = gc
 }
 
@@ -500,6 +539,16 @@ func (*goLang) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
 		gc.submodules = append(gc.submodules, m)
 	}
 
+	for _, r := range c.GoDepsRepos {
+		if r.ApparentName != "" {
+			continue
+		}
+		if gc.goDepsMissingRepos == nil {
+			gc.goDepsMissingRepos = make(map[string]string)
+		}
+		gc.goDepsMissingRepos[r.RepoName] = r.ModulePath
+	}
+
 	return nil
 }
 
@@ -513,11 +562,8 @@ func (*goLang) Configure(c *config.Config, rel string, f *rule.File) {
 	c.Exts[goName] = gc
 
 	if rel == "" {
-		moduleToApparentName, err := module.ExtractModuleToApparentNameMapping(c.RepoRoot)
-		if err != nil {
-			log.Print(err)
-		} else {
-			gc.rulesGoRepoName = moduleToApparentName("rules_go")
+		if c.ModuleToApparentName != nil {
+			gc.rulesGoRepoName = c.ModuleToApparentName("rules_go")
 		}
 		if gc.rulesGoRepoName == "" {
 			// The legacy name used in WORKSPACE.
@@ -526,6 +572,7 @@ func (*goLang) Configure(c *config.Config, rel string, f *rule.File) {
 
 		const message = `Gazelle may not be compatible with this version of rules_go.
 Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
+		var err error
 		gc.rulesGoVersion, err = findRulesGoVersion(c)
 		if c.ShouldFix {
 			// Only check the version when "fix" is run. Generated build files
@@ -562,17 +609,69 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 				}
 			}
 		}
+		for _, r := range c.GoDepsRepos {
+			// go_deps generates build files with the import_alias convention
+			// unless a gazelle_override says otherwise.
+			name := r.ApparentName
+			if name == "" {
+				name = r.RepoName
+			}
+			if _, ok := repoNamingConvention[name]; !ok {
+				repoNamingConvention[name] = importAliasNamingConvention
+			}
+		}
 		gc.repoNamingConvention = repoNamingConvention
+
+		gc.workModules, err = readGoWork(c.RepoRoot)
+		if err != nil {
+			log.Print(err)
+		}
+		for _, m := range gc.workModules {
+			// Packages in other modules may be indexed lazily like those in
+			// directories named with go_search.
+			gc.goSearch = append(gc.goSearch, goSearch{rel: m.rel, prefix: m.path})
+		}
 	}
 
-	if !gc.moduleMode {
+	for i := range gc.workModules {
+		if m := &gc.workModules[i]; m.rel == rel {
+			// A prefix directive in this directory may still override this.
+			gc.workModule = m
+			gc.prefix = m.path
+			gc.prefixSet = true
+			gc.prefixRel = rel
+			gc.moduleMode = true
+		}
+	}
+
+	// Once in module mode, go.mod files aren't looked for in each directory.
+	// Modules nested within the main module are found through go.work.
+	isModuleRoot := gc.workModule != nil && gc.workModule.rel == rel
+	if !gc.moduleMode && (gc.vendor == nil || !gc.vendor.contains(rel)) {
 		st, err := os.Stat(filepath.Join(c.RepoRoot, filepath.FromSlash(rel), "go.mod"))
 		if err == nil && !st.IsDir() {
 			gc.moduleMode = true
+			isModuleRoot = true
+		}
+	}
+	if rel == "" || isModuleRoot {
+		// Each module has its own vendor directory.
+		vendor, err := readVendorInfo(c.RepoRoot, path.Join(rel, "vendor"))
+		if err != nil {
+			log.Print(err)
 		}
+		gc.vendor = vendor
 	}
 
-	if path.Base(rel) == "vendor" {
+	if gc.vendor != nil && gc.vendor.contains(rel) {
+		// modules.txt says where each vendored module starts, so directories
+		// named "vendor" within vendored modules aren't treated as vendor
+		// directories.
+		if m := gc.vendor.moduleFor(gc.vendor.packagePath(rel)); m != nil && gc.vendor.packagePath(rel) == m.path {
+			gc.prefix = m.path
+			gc.prefixRel = rel
+		}
+	} else if path.Base(rel) == "vendor" {
 		gc.importMapPrefix = InferImportPath(c, rel)
 		gc.importMapPrefixRel = rel
 		gc.prefix = ""
@@ -673,6 +772,23 @@ Update io_bazel_rules_go to a newer version in your WORKSPACE file.`
 				}
 				gc.testMode = mode
 
+			case "go_tool_aliases":
+				// Special syntax (empty value) to reset directive.
+				if d.Value == "" {
+					gc.toolsGoMod, gc.toolsRel = "", ""
+					continue
+				}
+				toolsRel := path.Join(rel, d.Value)
+				if toolsRel == "." {
+					toolsRel = ""
+				}
+				if pathtools.HasPrefix(toolsRel, "..") {
+					log.Printf("# gazelle:go_tool_aliases: %q is outside the repository", d.Value)
+					continue
+				}
+				gc.toolsGoMod = filepath.Join(c.RepoRoot, filepath.FromSlash(rel), "go.mod")
+				gc.toolsRel = toolsRel
+
 			case "go_visibility":
 				gc.goVisibility = append(gc.goVisibility, strings.TrimSpace(d.Value))
 
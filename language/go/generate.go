@@ -360,6 +360,7 @@ func (gl *goLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 		var libName string
 		if !lib.IsEmpty(goKinds[lib.Kind()]) {
 			libName = lib.Name()
+			g.checkVendored(pkg)
 		}
 		rules = append(rules, lib)
 		g.maybePublishToolLib(lib, pkg)
@@ -373,6 +374,9 @@ func (gl *goLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 		rules = append(rules, g.generateBin(pkg, libName))
 		rules = append(rules, g.generateTests(pkg, libName)...)
 	}
+	if gc.toolsGoMod != "" && args.Rel == gc.toolsRel {
+		rules = append(rules, g.generateToolAliases(args.File)...)
+	}
 
 	for _, r := range rules {
 		if r.IsEmpty(goKinds[r.Kind()]) {
@@ -62,6 +62,10 @@ type goLang struct {
 	// Go code. If the value is false, it means the directory does not contain
 	// buildable Go code, but it has a subdir which does.
 	goPkgRels map[string]bool
+
+	// warnedMissingRepos is the set of go_deps repositories missing from
+	// use_repo that a warning has already been printed for.
+	warnedMissingRepos map[string]bool
 }
 
 func (*goLang) Name() string { return goName }
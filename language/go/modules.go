@@ -26,6 +26,10 @@ import (
 )
 
 func importReposFromModules(args language.ImportReposArgs) language.ImportReposResult {
+	if getGoConfig(args.Config).offlineImport {
+		return importReposFromModulesOffline(args)
+	}
+
 	// run go list in the dir where go.mod is located
 	data, err := goListModules(filepath.Dir(args.Path))
 	if err != nil {
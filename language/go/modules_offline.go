@@ -0,0 +1,363 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// importReposFromModulesOffline is like importReposFromModules, but it
+// doesn't run the go command or access the network. The module graph is
+// built from the main go.mod file and the go.mod files of dependencies,
+// read from the module cache or from file:// proxies in GOPROXY. Sums come
+// only from go.sum.
+//
+// Modules required by the main go.mod file must have sums for their zip
+// files in go.sum, and every go.mod file read must have a sum, too. Other
+// modules in the graph without a zip sum aren't needed to build the main
+// module and are left out. If any sums or go.mod files are missing, the
+// returned error lists all of them.
+func importReposFromModulesOffline(args language.ImportReposArgs) language.ImportReposResult {
+	dir := filepath.Dir(args.Path)
+	mainData, err := os.ReadFile(args.Path)
+	if err != nil {
+		return language.ImportReposResult{Error: err}
+	}
+	mainFile, err := modfile.Parse(args.Path, mainData, nil)
+	if err != nil {
+		return language.ImportReposResult{Error: err}
+	}
+	sums, err := readGoSum(filepath.Join(dir, "go.sum"))
+	if err != nil {
+		return language.ImportReposResult{Error: err}
+	}
+
+	g := &offlineModGraph{
+		dir:      dir,
+		sources:  localModSources(),
+		sums:     sums,
+		replace:  make(map[module.Version]module.Version),
+		exclude:  make(map[module.Version]bool),
+		selected: make(map[string]string),
+		loaded:   make(map[module.Version]bool),
+		unpruned: mainFile.Go == nil || semver.Compare("v"+mainFile.Go.Version, "v1.17") < 0,
+	}
+	if mainFile.Module != nil {
+		g.mainPath = mainFile.Module.Mod.Path
+	}
+	for _, r := range mainFile.Replace {
+		g.replace[r.Old] = r.New
+	}
+	for _, x := range mainFile.Exclude {
+		g.exclude[x.Mod] = true
+	}
+	var direct []module.Version
+	for _, r := range mainFile.Require {
+		direct = append(direct, r.Mod)
+	}
+	// Modules providing tools must be required like any other dependency.
+	// They're direct requirements, so their sums are checked below.
+	for _, t := range mainFile.Tool {
+		if toolModule(mainFile, t.Path) == "" {
+			g.errs = append(g.errs, fmt.Sprintf("tool %s is not provided by the main module or any required module", t.Path))
+		}
+	}
+	g.walk(direct)
+
+	pathToModule := make(map[string]*moduleFromList)
+	for modPath, version := range g.selected {
+		mod := &moduleFromList{Path: modPath, Version: version}
+		actual := g.resolve(module.Version{Path: modPath, Version: version})
+		if actual.Version == "" {
+			log.Printf("go_repository does not support file path replacements for %s -> %s", modPath, actual.Path)
+			continue
+		}
+		if actual.Path != modPath || actual.Version != version {
+			mod.Replace = &struct{ Path, Version string }{Path: actual.Path, Version: actual.Version}
+		}
+		mod.Sum = sums[actual.Path+" "+actual.Version]
+		if mod.Sum == "" {
+			// Every module in the build list needs a sum, whether it's required
+			// directly or not. Collect them all so they can be fixed at once.
+			g.missingSums = append(g.missingSums, actual.Path+" "+actual.Version)
+			continue
+		}
+		pathToModule[actual.Path+"@"+actual.Version] = mod
+	}
+
+	if err := g.err(); err != nil {
+		return language.ImportReposResult{Error: err}
+	}
+	return language.ImportReposResult{Gen: toRepositoryRules(pathToModule)}
+}
+
+// offlineModGraph builds a module graph with minimal version selection.
+type offlineModGraph struct {
+	// dir is the directory containing the main go.mod file. Local path
+	// replacements are relative to it.
+	dir string
+
+	// sources are directories laid out like a module proxy where go.mod
+	// files of dependencies may be found.
+	sources []string
+
+	// sums maps "path version" and "path version/go.mod" to hashes from
+	// go.sum.
+	sums map[string]string
+
+	replace map[module.Version]module.Version
+	exclude map[module.Version]bool
+
+	// selected maps each module path in the graph to the highest version
+	// required.
+	selected map[string]string
+
+	// loaded is the set of modules whose requirements have been added.
+	loaded map[module.Version]bool
+
+	// unpruned is true if the main module's go version is below 1.17, so
+	// the full transitive requirements of every module are loaded. Otherwise,
+	// only the requirements of modules at go 1.17 or higher are added without
+	// loading their go.mod files.
+	unpruned bool
+
+	// mainPath is the path of the main module. Requirements on it are
+	// ignored.
+	mainPath string
+
+	missingSums, missingMods, errs []string
+}
+
+// walk adds mods and their requirements to the graph.
+func (g *offlineModGraph) walk(mods []module.Version) {
+	queue := append([]module.Version(nil), mods...)
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		if m.Path == g.mainPath {
+			continue
+		}
+		if g.exclude[m] {
+			log.Printf("%s@%s is excluded by go.mod; ignoring requirement", m.Path, m.Version)
+			continue
+		}
+		if semver.Compare(m.Version, g.selected[m.Path]) > 0 {
+			g.selected[m.Path] = m.Version
+		}
+		if g.loaded[m] {
+			continue
+		}
+		g.loaded[m] = true
+
+		f := g.loadGoMod(m)
+		if f == nil {
+			continue
+		}
+		// With graph pruning, the requirements of a go 1.17 module are part
+		// of the graph, but their own requirements aren't.
+		pruned := !g.unpruned && f.Go != nil && semver.Compare("v"+f.Go.Version, "v1.17") >= 0
+		for _, r := range f.Require {
+			if pruned {
+				if r.Mod.Path != g.mainPath && !g.exclude[r.Mod] && semver.Compare(r.Mod.Version, g.selected[r.Mod.Path]) > 0 {
+					g.selected[r.Mod.Path] = r.Mod.Version
+				}
+				continue
+			}
+			queue = append(queue, r.Mod)
+		}
+	}
+}
+
+// resolve applies replace directives from the main go.mod file. A version
+// specific replacement takes precedence over one for all versions. The
+// returned version is empty for local path replacements.
+func (g *offlineModGraph) resolve(m module.Version) module.Version {
+	if r, ok := g.replace[m]; ok {
+		return r
+	}
+	if r, ok := g.replace[module.Version{Path: m.Path}]; ok {
+		return r
+	}
+	return m
+}
+
+// loadGoMod reads and verifies the go.mod file of a module. Problems are
+// recorded in g and nil is returned.
+func (g *offlineModGraph) loadGoMod(m module.Version) *modfile.File {
+	actual := g.resolve(m)
+	if actual.Version == "" {
+		// Local path replacement. The directory is part of the workspace, so
+		// there's no sum to check.
+		p := actual.Path
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(g.dir, p)
+		}
+		goModPath := filepath.Join(p, "go.mod")
+		data, err := os.ReadFile(goModPath)
+		if err != nil {
+			g.errs = append(g.errs, fmt.Sprintf("%s => %s: %v", m.Path, actual.Path, err))
+			return nil
+		}
+		return g.parseGoMod(goModPath, data)
+	}
+
+	id := actual.Path + " " + actual.Version
+	data, err := g.readGoMod(actual)
+	if err != nil {
+		g.errs = append(g.errs, err.Error())
+		return nil
+	}
+	if data == nil {
+		g.missingMods = append(g.missingMods, id)
+		return nil
+	}
+	want := g.sums[id+"/go.mod"]
+	if want == "" {
+		g.missingSums = append(g.missingSums, id+"/go.mod")
+		return nil
+	}
+	got, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil {
+		g.errs = append(g.errs, err.Error())
+		return nil
+	}
+	if got != want {
+		g.errs = append(g.errs, fmt.Sprintf("%s/go.mod: checksum mismatch: downloaded %s, go.sum has %s", id, got, want))
+		return nil
+	}
+	return g.parseGoMod(actual.Path+"@"+actual.Version+"/go.mod", data)
+}
+
+func (g *offlineModGraph) parseGoMod(name string, data []byte) *modfile.File {
+	f, err := modfile.ParseLax(name, data, nil)
+	if err != nil {
+		g.errs = append(g.errs, err.Error())
+		return nil
+	}
+	return f
+}
+
+// readGoMod reads the go.mod file of a module version from the first source
+// that has it. It returns nil, nil if no source has it.
+func (g *offlineModGraph) readGoMod(m module.Version) ([]byte, error) {
+	escPath, err := module.EscapePath(m.Path)
+	if err != nil {
+		return nil, err
+	}
+	escVersion, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return nil, err
+	}
+	rel := filepath.Join(filepath.FromSlash(escPath), "@v", escVersion+".mod")
+	for _, dir := range g.sources {
+		data, err := os.ReadFile(filepath.Join(dir, rel))
+		if err == nil {
+			return data, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// err reports all problems found while building the graph.
+func (g *offlineModGraph) err() error {
+	if len(g.missingSums) == 0 && len(g.missingMods) == 0 && len(g.errs) == 0 {
+		return nil
+	}
+	buf := &strings.Builder{}
+	buf.WriteString("importing modules offline:")
+	for _, e := range g.errs {
+		fmt.Fprintf(buf, "\n\t%s", e)
+	}
+	if len(g.missingSums) > 0 {
+		sort.Strings(g.missingSums)
+		buf.WriteString("\nmissing go.sum entries:")
+		for _, s := range g.missingSums {
+			fmt.Fprintf(buf, "\n\t%s", s)
+		}
+	}
+	if len(g.missingMods) > 0 {
+		sort.Strings(g.missingMods)
+		fmt.Fprintf(buf, "\ngo.mod files not found in %s:", strings.Join(g.sources, ", "))
+		for _, s := range g.missingMods {
+			fmt.Fprintf(buf, "\n\t%s", s)
+		}
+	}
+	buf.WriteString("\nrun 'go mod download' to fill the module cache and go.sum")
+	return fmt.Errorf("%s", buf.String())
+}
+
+// localModSources returns the directories where go.mod files of
+// dependencies may be found without accessing the network: the download
+// cache in the module cache, followed by file:// proxies in GOPROXY.
+func localModSources() []string {
+	var dirs []string
+	modCache := os.Getenv("GOMODCACHE")
+	if modCache == "" {
+		gopath := os.Getenv("GOPATH")
+		if gopath == "" {
+			gopath = build.Default.GOPATH
+		}
+		if list := filepath.SplitList(gopath); len(list) > 0 && list[0] != "" {
+			modCache = filepath.Join(list[0], "pkg", "mod")
+		}
+	}
+	if modCache != "" {
+		dirs = append(dirs, filepath.Join(modCache, "cache", "download"))
+	}
+	for _, p := range strings.FieldsFunc(os.Getenv("GOPROXY"), func(r rune) bool { return r == ',' || r == '|' }) {
+		if u, err := url.Parse(strings.TrimSpace(p)); err == nil && u.Scheme == "file" {
+			dirs = append(dirs, filepath.FromSlash(u.Path))
+		}
+	}
+	return dirs
+}
+
+// readGoSum reads a go.sum file into a map from "path version" and
+// "path version/go.mod" to hashes. A missing file is treated as empty.
+func readGoSum(path string) (map[string]string, error) {
+	sums := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return sums, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if f := strings.Fields(line); len(f) == 3 {
+			sums[f[0]+" "+f[1]] = f[2]
+		}
+	}
+	return sums, nil
+}
@@ -68,6 +68,10 @@ func (gl *goLang) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remo
 		// may not be set in tests.
 		return
 	}
+	if imp, ok := importsRaw.(toolImport); ok {
+		gl.resolveToolAlias(c, ix, rc, r, string(imp), from)
+		return
+	}
 	imports := importsRaw.(rule.PlatformStrings)
 	r.DelAttr("deps")
 	var resolve func(*config.Config, *resolve.RuleIndex, *repo.RemoteCache, string, label.Label) (label.Label, error)
@@ -84,6 +88,7 @@ func (gl *goLang) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remo
 		} else if err != nil {
 			return "", err
 		}
+		gl.checkRepoVisible(c, imp, l, from)
 		for _, embed := range gl.Embeds(r, from) {
 			if embed.Equal(l) {
 				return "", nil
@@ -107,6 +112,21 @@ func (gl *goLang) Resolve(c *config.Config, ix *resolve.RuleIndex, rc *repo.Remo
 	}
 }
 
+// checkRepoVisible prints a warning if l is in a repository created by the
+// go_deps module extension that is not listed in use_repo. Only one warning
+// is printed for each repository.
+func (gl *goLang) checkRepoVisible(c *config.Config, imp string, l label.Label, from label.Label) {
+	modulePath, ok := getGoConfig(c).goDepsMissingRepos[l.Repo]
+	if !ok || gl.warnedMissingRepos[l.Repo] {
+		return
+	}
+	if gl.warnedMissingRepos == nil {
+		gl.warnedMissingRepos = make(map[string]bool)
+	}
+	gl.warnedMissingRepos[l.Repo] = true
+	log.Printf("%s: import %q resolved to module %s, but its repository @%s is not visible. Add it to use_repo(go_deps, ...) in MODULE.bazel.", from, imp, modulePath, l.Repo)
+}
+
 var (
 	errSkipImport = errors.New("std or self import")
 	errNotFound   = errors.New("rule not found")
@@ -156,6 +176,14 @@ func ResolveGo(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, im
 		}
 	}
 
+	// Packages in modules used by go.work are provided by the workspace, never
+	// by external repositories, even if they aren't indexed.
+	if m := findWorkModule(gc.workModules, imp); m != nil {
+		pkg := path.Join(m.rel, pathtools.TrimPrefix(imp, m.path))
+		libName := libNameByConvention(gc.goNamingConvention, imp, "")
+		return label.New("", pkg, libName), nil
+	}
+
 	if !c.IndexLibraries {
 		// packages in current repo were not indexed, relying on prefix to decide what may have been in
 		// current repo
@@ -167,7 +195,21 @@ func ResolveGo(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, im
 	}
 
 	if gc.depMode == vendorMode {
-		return resolveVendored(gc, imp)
+		return resolveVendored(gc, imp, from)
+	}
+	if gc.workModule != nil {
+		// In a workspace, each module has its own requirements. If the current
+		// module requires a module that provides the import, use that module,
+		// even if another module in the workspace requires a nested module with
+		// a longer path.
+		if modPath := gc.workModule.requiredModule(imp); modPath != "" {
+			return resolveToExternalLabel(c, func(string) (string, string, error) {
+				if root, name, err := rc.RootStatic(modPath); err == nil && root == modPath {
+					return root, name, nil
+				}
+				return modPath, label.ImportPathToBazelRepoName(modPath), nil
+			}, imp)
+		}
 	}
 	var resolveFn func(string) (string, string, error)
 	if gc.depMode == staticMode {
@@ -214,7 +256,8 @@ func resolveWithIndexGo(c *config.Config, ix *resolve.RuleIndex, imp string, fro
 				break
 			}
 		}
-		if isVendored && !label.New(m.Label.Repo, vendorRoot, "").Contains(from) {
+		vendorPattern := label.Pattern{Repo: m.Label.Repo, Canonical: m.Label.Canonical, Pkg: vendorRoot, Recursive: true}
+		if isVendored && !vendorPattern.Matches(from) {
 			// vendor directory not visible
 			continue
 		}
@@ -301,8 +344,19 @@ func resolveToExternalLabel(c *config.Config, resolveFn func(string) (string, st
 	return label.New(repo, pkg, name), nil
 }
 
-func resolveVendored(gc *goConfig, imp string) (label.Label, error) {
+func resolveVendored(gc *goConfig, imp string, from label.Label) (label.Label, error) {
 	name := libNameByConvention(gc.goNamingConvention, imp, "")
+	if vi := gc.vendor; vi != nil {
+		// modules.txt lists every package the go command may load from the
+		// vendor directory. Imports of other packages can't be built.
+		if _, ok := vi.packages[imp]; !ok {
+			if m := vi.moduleFor(imp); m != nil {
+				return label.NoLabel, fmt.Errorf("%s: package %s is provided by module %s, but it's not listed in %s/modules.txt. Run 'go mod vendor' to update the vendor directory", from, imp, m, vi.rel)
+			}
+			return label.NoLabel, fmt.Errorf("%s: no module in %s/modules.txt provides package %s", from, vi.rel, imp)
+		}
+		return label.New("", path.Join(vi.rel, imp), name), nil
+	}
 	return label.New("", path.Join("vendor", imp), name), nil
 }
 
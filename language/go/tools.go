@@ -0,0 +1,157 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/merger"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"github.com/bazelbuild/bazel-gazelle/repo"
+	"github.com/bazelbuild/bazel-gazelle/resolve"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"golang.org/x/mod/modfile"
+)
+
+// toolImport is stored as the imports of an alias generated for a tool
+// directive. It's the package path of the tool. Resolve sets the alias's
+// actual attribute to the go_binary built from that package.
+type toolImport string
+
+// toolAliasComment starts the comment added above each alias generated for
+// a tool directive. Only aliases with this comment are deleted when their
+// tools are removed, so hand-written aliases are left alone.
+const toolAliasComment = "# Alias for the go.mod tool "
+
+// readGoModTools returns the package paths named by tool directives in a
+// go.mod file.
+func readGoModTools(goModPath string) ([]string, error) {
+	data, err := os.ReadFile(goModPath)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse(goModPath, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	tools := make([]string, len(f.Tool))
+	for i, t := range f.Tool {
+		tools[i] = t.Path
+	}
+	return tools, nil
+}
+
+// toolModule returns the path of the module in f that provides a tool
+// package: the main module or the required module with the longest path
+// that is a prefix of the package path. It returns "" if there's none.
+func toolModule(f *modfile.File, pkgPath string) string {
+	best := ""
+	if f.Module != nil && pathtools.HasPrefix(pkgPath, f.Module.Mod.Path) {
+		best = f.Module.Mod.Path
+	}
+	for _, r := range f.Require {
+		if pathtools.HasPrefix(pkgPath, r.Mod.Path) && len(r.Mod.Path) > len(best) {
+			best = r.Mod.Path
+		}
+	}
+	return best
+}
+
+var majorVersionSuffixRe = regexp.MustCompile(`^v[0-9]+$`)
+
+// toolName returns the name "go tool" uses for a tool package: the last
+// element of its path, or the one before it if the last element is a major
+// version suffix.
+func toolName(pkgPath string) string {
+	name := path.Base(pkgPath)
+	if majorVersionSuffixRe.MatchString(name) && path.Dir(pkgPath) != "." {
+		name = path.Base(path.Dir(pkgPath))
+	}
+	return name
+}
+
+// generateToolAliases returns an alias for each tool directive in the go.mod
+// file named with # gazelle:go_tool_aliases, and empty aliases for existing
+// aliases of tools that were removed. Existing aliases are only treated as
+// tool aliases if they have the comment generated aliases start with.
+func (g *generator) generateToolAliases(f *rule.File) []*rule.Rule {
+	gc := g.gc
+	tools, err := readGoModTools(gc.toolsGoMod)
+	if err != nil {
+		log.Printf("# gazelle:go_tool_aliases: %v", err)
+		return nil
+	}
+	var rules []*rule.Rule
+	names := make(map[string]bool)
+	for _, t := range tools {
+		name := toolName(t)
+		if names[name] {
+			log.Printf("%s: tools %s and others have the same name %q; only one alias is generated", gc.toolsGoMod, t, name)
+			continue
+		}
+		names[name] = true
+		r := rule.NewRule("alias", name)
+		r.AddComment(toolAliasComment + t + ".")
+		// The actual target is set during resolution. This placeholder keeps
+		// the rule from being treated as empty until then.
+		r.SetAttr("actual", t)
+		r.SetPrivateAttr(config.GazelleImportsKey, toolImport(t))
+		// actual is merged after resolution for tool aliases only. Other
+		// aliases are resolved by their own languages, if at all.
+		r.SetPrivateAttr(merger.MergeableAttrsKey, map[string]bool{"actual": true})
+		rules = append(rules, r)
+	}
+	if f != nil {
+		for _, r := range f.Rules {
+			if r.Kind() != "alias" || names[r.Name()] {
+				continue
+			}
+			for _, c := range r.Comments() {
+				if strings.HasPrefix(c, toolAliasComment) {
+					rules = append(rules, rule.NewRule("alias", r.Name()))
+					break
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// resolveToolAlias sets the actual attribute of an alias generated for a
+// tool to the go_binary built from the tool's package. The go_binary is in
+// the same package as the library the import path resolves to, and it's
+// named after the package's directory.
+func (gl *goLang) resolveToolAlias(c *config.Config, ix *resolve.RuleIndex, rc *repo.RemoteCache, r *rule.Rule, imp string, from label.Label) {
+	l, err := ResolveGo(c, ix, rc, imp, from)
+	if err != nil {
+		log.Printf("%s: resolving tool %s: %v", from, imp, err)
+		r.DelAttr("actual")
+		return
+	}
+	gl.checkRepoVisible(c, imp, l, from)
+	name := path.Base(l.Pkg)
+	if l.Pkg == "" {
+		name = path.Base(imp)
+	}
+	bin := label.New(l.Repo, l.Pkg, name)
+	r.SetAttr("actual", bin.Rel(from.Repo, from.Pkg).String())
+}
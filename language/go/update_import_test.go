@@ -16,7 +16,10 @@ limitations under the License.
 package golang
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -26,6 +29,7 @@ import (
 	"github.com/bazelbuild/bazel-gazelle/repo"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"golang.org/x/mod/sumdb/dirhash"
 )
 
 func TestImports(t *testing.T) {
@@ -645,3 +649,164 @@ go_repository(
 		})
 	}
 }
+
+func TestImportReposOffline(t *testing.T) {
+	goMods := map[string]string{
+		"example.com/a@v1.0.0":     "module example.com/a\n\ngo 1.21\n\nrequire (\n\texample.com/b v1.0.0\n\texample.com/d v1.0.0\n)\n",
+		"example.com/b@v1.1.0":     "module example.com/b\n\nrequire example.com/e v1.0.0\n",
+		"example.com/cfork@v1.2.0": "module example.com/c\n\ngo 1.21\n",
+		"example.com/e@v1.0.0":     "module example.com/e\n",
+	}
+	modCache := t.TempDir()
+	var goSum strings.Builder
+	for modVersion, content := range goMods {
+		modPath, version, _ := strings.Cut(modVersion, "@")
+		p := filepath.Join(modCache, "cache", "download", filepath.FromSlash(modPath), "@v", version+".mod")
+		if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(content)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&goSum, "%s %s/go.mod %s\n", modPath, version, sum)
+	}
+	goSum.WriteString("example.com/a v1.0.0 h1:a=\nexample.com/b v1.1.0 h1:b=\nexample.com/cfork v1.2.0 h1:c=\nexample.com/d v1.0.0 h1:d=\nexample.com/e v1.0.0 h1:e=\n")
+	goMod := `module example.com/main
+
+go 1.21
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.1.0
+	example.com/c v1.0.0
+)
+
+replace example.com/c => example.com/cfork v1.2.0
+`
+	t.Setenv("GOMODCACHE", modCache)
+	t.Setenv("GOPROXY", "off")
+	previousGoListModules, previousGoModDownload := goListModules, goModDownload
+	goListModules = func(string) ([]byte, error) { return nil, errors.New("go list must not be called") }
+	goModDownload = func(string, []string) ([]byte, error) { return nil, errors.New("go mod download must not be called") }
+	defer func() { goListModules, goModDownload = previousGoListModules, previousGoModDownload }()
+
+	importOffline := func(t *testing.T, goMod, goSum string) language.ImportReposResult {
+		dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+			{Path: "go.mod", Content: goMod},
+			{Path: "go.sum", Content: goSum},
+		})
+		t.Cleanup(cleanup)
+		c := &config.Config{Exts: map[string]interface{}{}}
+		gl := NewLanguage()
+		gl.Configure(c, "", nil)
+		getGoConfig(c).offlineImport = true
+		return gl.(language.RepoImporter).ImportRepos(language.ImportReposArgs{
+			Config: c,
+			Path:   filepath.Join(dir, "go.mod"),
+		})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		result := importOffline(t, goMod, goSum.String())
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		f := rule.EmptyFile("test", "")
+		for _, r := range result.Gen {
+			r.Insert(f)
+		}
+		got := strings.TrimSpace(string(f.Format()))
+		want := strings.TrimSpace(`
+go_repository(
+    name = "com_example_a",
+    importpath = "example.com/a",
+    sum = "h1:a=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "com_example_b",
+    importpath = "example.com/b",
+    sum = "h1:b=",
+    version = "v1.1.0",
+)
+
+go_repository(
+    name = "com_example_c",
+    importpath = "example.com/c",
+    replace = "example.com/cfork",
+    sum = "h1:c=",
+    version = "v1.2.0",
+)
+
+go_repository(
+    name = "com_example_d",
+    importpath = "example.com/d",
+    sum = "h1:d=",
+    version = "v1.0.0",
+)
+
+go_repository(
+    name = "com_example_e",
+    importpath = "example.com/e",
+    sum = "h1:e=",
+    version = "v1.0.0",
+)
+`)
+		if got != want {
+			t.Errorf("got:\n%s\n\nwant:\n%s\n", got, want)
+		}
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		var lines []string
+		for _, line := range strings.Split(goSum.String(), "\n") {
+			if !strings.HasPrefix(line, "example.com/b v1.1.0 ") && !strings.HasPrefix(line, "example.com/d ") && !strings.HasPrefix(line, "example.com/e v1.0.0/go.mod ") {
+				lines = append(lines, line)
+			}
+		}
+		result := importOffline(t, goMod, strings.Join(lines, "\n"))
+		if result.Error == nil {
+			t.Fatal("got success; want error")
+		}
+		want := `importing modules offline:
+missing go.sum entries:
+	example.com/b v1.1.0
+	example.com/d v1.0.0
+	example.com/e v1.0.0/go.mod
+run 'go mod download' to fill the module cache and go.sum`
+		if got := result.Error.Error(); got != want {
+			t.Errorf("got error:\n%s\nwant:\n%s", got, want)
+		}
+	})
+	t.Run("tools", func(t *testing.T) {
+		toolGoMod := goMod + "\ntool (\n\texample.com/a/cmd/gen\n\texample.com/main/cmd/x\n)\n"
+		result := importOffline(t, toolGoMod, goSum.String())
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		if len(result.Gen) != 5 || result.Gen[0].AttrString("importpath") != "example.com/a" {
+			t.Errorf("got %d rules; want rules for example.com/a through example.com/e", len(result.Gen))
+		}
+	})
+
+	t.Run("tool_not_required", func(t *testing.T) {
+		toolGoMod := goMod + "\ntool example.com/f/cmd/f\n"
+		result := importOffline(t, toolGoMod, goSum.String())
+		if result.Error == nil {
+			t.Fatal("got success; want error")
+		}
+		want := `importing modules offline:
+	tool example.com/f/cmd/f is not provided by the main module or any required module
+run 'go mod download' to fill the module cache and go.sum`
+		if got := result.Error.Error(); got != want {
+			t.Errorf("got error:\n%s\nwant:\n%s", got, want)
+		}
+	})
+}
@@ -0,0 +1,200 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+)
+
+// vendorInfo describes a vendor directory created by "go mod vendor", as
+// recorded in its modules.txt file.
+type vendorInfo struct {
+	// rel is the slash-separated path of the vendor directory, relative to
+	// the repository root.
+	rel string
+
+	// modules lists the vendored modules in the order they appear in
+	// modules.txt. Modules that are replaced but not used are not included.
+	modules []*vendoredModule
+
+	// packages maps the path of each vendored package to the module that
+	// provides it.
+	packages map[string]*vendoredModule
+}
+
+// vendoredModule is a module listed in vendor/modules.txt.
+type vendoredModule struct {
+	path, version string
+
+	// replace is the replacement module path or directory, and replaceVersion
+	// is its version. Both are empty if the module isn't replaced.
+	replace, replaceVersion string
+
+	// explicit is true if the module is required explicitly in go.mod.
+	explicit bool
+
+	// packages lists the packages copied from the module.
+	packages []string
+}
+
+func (m *vendoredModule) String() string {
+	if m.version == "" {
+		return m.path
+	}
+	return m.path + " " + m.version
+}
+
+// readVendorInfo reads modules.txt in the vendor directory vendorRel. It
+// returns nil if there's no modules.txt.
+func readVendorInfo(repoRoot, vendorRel string) (*vendorInfo, error) {
+	modulesTxtPath := filepath.Join(repoRoot, filepath.FromSlash(vendorRel), "modules.txt")
+	data, err := os.ReadFile(modulesTxtPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	vi, err := parseModulesTxt(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", modulesTxtPath, err)
+	}
+	vi.rel = vendorRel
+	return vi, nil
+}
+
+// parseModulesTxt parses the content of a vendor/modules.txt file. Lines
+// starting with "# " name a module, its version, and its replacement, if
+// any. Lines starting with "## " annotate the preceding module. Other lines
+// name packages in the preceding module.
+func parseModulesTxt(data []byte) (*vendorInfo, error) {
+	vi := &vendorInfo{packages: make(map[string]*vendoredModule)}
+	var mod *vendoredModule
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNum := i + 1
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+
+		case strings.HasPrefix(line, "## "):
+			if mod == nil {
+				return nil, fmt.Errorf("%d: annotation outside of a module", lineNum)
+			}
+			for _, a := range strings.Split(strings.TrimPrefix(line, "## "), ";") {
+				if strings.TrimSpace(a) == "explicit" {
+					mod.explicit = true
+				}
+			}
+
+		case strings.HasPrefix(line, "# "):
+			fields := strings.Fields(strings.TrimPrefix(line, "# "))
+			var replace []string
+			for j, f := range fields {
+				if f == "=>" {
+					fields, replace = fields[:j], fields[j+1:]
+					break
+				}
+			}
+			if len(fields) == 0 || len(fields) > 2 || len(replace) > 2 {
+				return nil, fmt.Errorf("%d: malformed module line: %q", lineNum, line)
+			}
+			mod = &vendoredModule{path: fields[0]}
+			if len(fields) == 2 {
+				mod.version = fields[1]
+			}
+			if len(replace) > 0 {
+				mod.replace = replace[0]
+			}
+			if len(replace) > 1 {
+				mod.replaceVersion = replace[1]
+			}
+			if mod.version == "" {
+				// A wildcard replacement that isn't used by any module in the
+				// build list. Nothing is vendored from it.
+				mod = nil
+				continue
+			}
+			vi.modules = append(vi.modules, mod)
+
+		case strings.HasPrefix(line, "#"):
+			// Comments and annotations from future versions of Go.
+			continue
+
+		default:
+			if mod == nil {
+				return nil, fmt.Errorf("%d: package %s outside of a module", lineNum, line)
+			}
+			mod.packages = append(mod.packages, line)
+			vi.packages[line] = mod
+		}
+	}
+	return vi, nil
+}
+
+// moduleFor returns the vendored module whose path is the longest prefix of
+// the package path pkgPath, or nil if there's none. The package itself
+// doesn't need to be vendored.
+func (vi *vendorInfo) moduleFor(pkgPath string) *vendoredModule {
+	if m, ok := vi.packages[pkgPath]; ok {
+		return m
+	}
+	var best *vendoredModule
+	for _, m := range vi.modules {
+		if pathtools.HasPrefix(pkgPath, m.path) && (best == nil || len(m.path) > len(best.path)) {
+			best = m
+		}
+	}
+	return best
+}
+
+// contains returns whether rel is inside the vendor directory, not counting
+// the vendor directory itself.
+func (vi *vendorInfo) contains(rel string) bool {
+	return rel != vi.rel && label.Pattern{Pkg: vi.rel, Recursive: true}.MatchesPackage(rel)
+}
+
+// packagePath returns the path of the package in the directory rel, which
+// must be inside the vendor directory.
+func (vi *vendorInfo) packagePath(rel string) string {
+	return pathtools.TrimPrefix(rel, vi.rel)
+}
+
+// checkVendored logs a warning if pkg is in a vendor directory but isn't
+// listed in modules.txt. The go command doesn't build packages like this,
+// which usually means the directory was copied by hand or modules.txt is
+// out of date.
+func (g *generator) checkVendored(pkg *goPackage) {
+	vi := g.gc.vendor
+	if vi == nil || !vi.contains(pkg.rel) {
+		return
+	}
+	pkgPath := vi.packagePath(pkg.rel)
+	if _, ok := vi.packages[pkgPath]; ok {
+		return
+	}
+	if m := vi.moduleFor(pkgPath); m != nil {
+		log.Printf("%s: package %s is not listed under module %s in %s/modules.txt. Run 'go mod vendor' to update the vendor directory.", pkg.rel, pkgPath, m, vi.rel)
+	} else {
+		log.Printf("%s: package %s is not provided by any module in %s/modules.txt. Run 'go mod vendor' to update the vendor directory.", pkg.rel, pkgPath, vi.rel)
+	}
+}
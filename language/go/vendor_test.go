@@ -0,0 +1,81 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseModulesTxt(t *testing.T) {
+	vi, err := parseModulesTxt([]byte(`# example.com/a v1.0.0
+## explicit; go 1.21
+example.com/a
+example.com/a/sub
+# example.com/a/nested v0.1.0
+example.com/a/nested/pkg
+# example.com/b v1.1.0 => example.com/bfork v1.2.0
+## explicit
+example.com/b
+# example.com/c v1.0.0 => ../c
+## explicit; go 1.22
+example.com/c
+# example.com/unused => ../unused
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type module struct {
+		Path, Version, Replace, ReplaceVersion string
+		Explicit                               bool
+		Packages                               []string
+	}
+	var got []module
+	for _, m := range vi.modules {
+		got = append(got, module{m.path, m.version, m.replace, m.replaceVersion, m.explicit, m.packages})
+	}
+	want := []module{
+		{"example.com/a", "v1.0.0", "", "", true, []string{"example.com/a", "example.com/a/sub"}},
+		{"example.com/a/nested", "v0.1.0", "", "", false, []string{"example.com/a/nested/pkg"}},
+		{"example.com/b", "v1.1.0", "example.com/bfork", "v1.2.0", true, []string{"example.com/b"}},
+		{"example.com/c", "v1.0.0", "../c", "", true, []string{"example.com/c"}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("modules (-want,+got):\n%s", diff)
+	}
+
+	for pkgPath, wantMod := range map[string]string{
+		"example.com/a/sub":        "example.com/a",
+		"example.com/a/other":      "example.com/a",
+		"example.com/a/nested/pkg": "example.com/a/nested",
+		"example.com/a/nested/new": "example.com/a/nested",
+		"example.com/d":            "",
+	} {
+		gotMod := ""
+		if m := vi.moduleFor(pkgPath); m != nil {
+			gotMod = m.path
+		}
+		if gotMod != wantMod {
+			t.Errorf("moduleFor(%q): got %q; want %q", pkgPath, gotMod, wantMod)
+		}
+	}
+
+	if _, err := parseModulesTxt([]byte("example.com/a\n")); err == nil {
+		t.Error("package outside of a module: got success; want error")
+	}
+}
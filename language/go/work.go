@@ -17,11 +17,103 @@ package golang
 
 import (
 	"fmt"
+	"os"
+	"path"
 	"path/filepath"
 
 	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"golang.org/x/mod/modfile"
 )
 
+// workModule is a module listed in a use directive in the go.work file in
+// the repository root directory.
+type workModule struct {
+	// rel is the slash-separated path of the module's directory, relative to
+	// the repository root.
+	rel string
+
+	// path is the module path declared in the module's go.mod file.
+	path string
+
+	// requires lists the paths of modules required by the module's go.mod file.
+	requires []string
+}
+
+// readGoWork reads the go.work file in the repository root directory and the
+// go.mod files of the modules it uses. It returns nil if there's no go.work
+// file. Modules outside the repository are skipped.
+func readGoWork(repoRoot string) ([]workModule, error) {
+	workPath := filepath.Join(repoRoot, "go.work")
+	data, err := os.ReadFile(workPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	wf, err := modfile.ParseWork(workPath, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var mods []workModule
+	for _, u := range wf.Use {
+		if filepath.IsAbs(u.Path) {
+			continue
+		}
+		rel := path.Clean(filepath.ToSlash(u.Path))
+		if rel == "." {
+			rel = ""
+		} else if pathtools.HasPrefix(rel, "..") {
+			continue
+		}
+		goModPath := filepath.Join(repoRoot, filepath.FromSlash(rel), "go.mod")
+		data, err := os.ReadFile(goModPath)
+		if err != nil {
+			return nil, err
+		}
+		f, err := modfile.ParseLax(goModPath, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		if f.Module == nil {
+			return nil, fmt.Errorf("%s: no module directive", goModPath)
+		}
+		m := workModule{rel: rel, path: f.Module.Mod.Path}
+		for _, r := range f.Require {
+			m.requires = append(m.requires, r.Mod.Path)
+		}
+		mods = append(mods, m)
+	}
+	return mods, nil
+}
+
+// findWorkModule returns the module in mods whose path is the longest prefix
+// of the import path imp, or nil if there's none.
+func findWorkModule(mods []workModule, imp string) *workModule {
+	var best *workModule
+	for i := range mods {
+		m := &mods[i]
+		if pathtools.HasPrefix(imp, m.path) && (best == nil || len(m.path) > len(best.path)) {
+			best = m
+		}
+	}
+	return best
+}
+
+// requiredModule returns the path of the module required by m that provides
+// the import path imp, or "" if there's none. Like the go command, the
+// module with the longest matching path is chosen.
+func (m *workModule) requiredModule(imp string) string {
+	best := ""
+	for _, modPath := range m.requires {
+		if pathtools.HasPrefix(imp, modPath) && len(modPath) > len(best) {
+			best = modPath
+		}
+	}
+	return best
+}
+
 func importReposFromWork(args language.ImportReposArgs) language.ImportReposResult {
 	// run go list in the dir where go.work is located
 	data, err := goListModules(filepath.Dir(args.Path))
@@ -110,6 +110,16 @@ type FinishableLanguage interface {
 	DoneGeneratingRules()
 }
 
+// CheckingLanguage allows a Language to validate the sources it has seen
+// once rules have been generated and dependencies have been resolved.
+type CheckingLanguage interface {
+	// Check is called after dependencies have been resolved and before build
+	// files are emitted. c is the configuration for the repository root.
+	// Problems should be reported by the language itself. A non-nil error
+	// causes Gazelle to exit with an error after build files are emitted.
+	Check(c *config.Config) error
+}
+
 type ModuleAwareLanguage interface {
 	// ApparentLoads returns .bzl files and symbols they define. Every rule
 	// generated by GenerateRules, now or in the past, should be loadable from
@@ -20,10 +20,11 @@ import (
 	"fmt"
 	"log"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/bazelbuild/bazel-gazelle/config"
-	"github.com/bazelbuild/bazel-gazelle/pathtools"
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 )
 
@@ -58,6 +59,14 @@ type ProtoConfig struct {
 	// If set, Gazelle will apply this value to the import_prefix attribute
 	// within the proto_library_rule.
 	ImportPrefix string
+
+	// lintMode determines whether the lint pass runs for .proto files in
+	// this directory and how its findings are reported.
+	lintMode lintMode
+
+	// lintOut is the file where lint diagnostics are written as JSON. Only
+	// meaningful in the root configuration.
+	lintOut string
 }
 
 // GetProtoConfig returns the proto language configuration. If the proto
@@ -194,14 +203,20 @@ func (*protoLang) RegisterFlags(fs *flag.FlagSet, cmd string, c *config.Config)
 	fs.Var(&modeFlag{&pc.Mode}, "proto", "default: generates a proto_library rule for one package\n\tpackage: generates a proto_library rule for for each package\n\tdisable: does not touch proto rules\n\tdisable_global: does not touch proto rules and does not use special cases for protos in dependency resolution")
 	fs.StringVar(&pc.groupOption, "proto_group", "", "option name used to group .proto files into proto_library rules")
 	fs.StringVar(&pc.ImportPrefix, "proto_import_prefix", "", "When set, .proto source files in the srcs attribute of the rule are accessible at their path with this prefix appended on.")
+	fs.Var(&lintModeFlag{&pc.lintMode}, "proto_lint", "off: does not check .proto files\n\twarn: reports proto layout and consistency problems\n\terror: reports problems and fails the run if any are found")
+	fs.StringVar(&pc.lintOut, "proto_lint_out", "", "file where proto lint diagnostics are written as JSON")
 }
 
 func (*protoLang) CheckFlags(fs *flag.FlagSet, c *config.Config) error {
+	pc := GetProtoConfig(c)
+	if pc.lintOut != "" && !filepath.IsAbs(pc.lintOut) {
+		pc.lintOut = filepath.Join(c.WorkDir, pc.lintOut)
+	}
 	return nil
 }
 
 func (*protoLang) KnownDirectives() []string {
-	return []string{"proto", "proto_group", "proto_strip_import_prefix", "proto_import_prefix"}
+	return []string{"proto", "proto_group", "proto_strip_import_prefix", "proto_import_prefix", "proto_lint"}
 }
 
 func (*protoLang) Configure(c *config.Config, rel string, f *rule.File) {
@@ -228,6 +243,13 @@ func (*protoLang) Configure(c *config.Config, rel string, f *rule.File) {
 				}
 			case "proto_import_prefix":
 				pc.ImportPrefix = d.Value
+			case "proto_lint":
+				mode, err := lintModeFromString(d.Value)
+				if err != nil {
+					log.Print(err)
+					continue
+				}
+				pc.lintMode = mode
 			}
 		}
 	}
@@ -296,7 +318,7 @@ func checkStripImportPrefix(prefix, rel string) error {
 	if !strings.HasPrefix(prefix, "/") {
 		return fmt.Errorf("proto_strip_import_prefix should start with '/' for a prefix relative to the repository root")
 	}
-	if rel != "" && !pathtools.HasPrefix(rel, prefix[1:]) {
+	if rel != "" && !(label.Pattern{Pkg: prefix[1:], Recursive: true}).MatchesPackage(rel) {
 		return fmt.Errorf("proto_strip_import_prefix %q not in directory %s", prefix, rel)
 	}
 	return nil
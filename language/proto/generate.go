@@ -29,7 +29,7 @@ import (
 	"github.com/bazelbuild/bazel-gazelle/rule"
 )
 
-func (*protoLang) GenerateRules(args language.GenerateArgs) language.GenerateResult {
+func (l *protoLang) GenerateRules(args language.GenerateArgs) language.GenerateResult {
 	c := args.Config
 	pc := GetProtoConfig(c)
 	if !pc.Mode.ShouldGenerateRules() {
@@ -83,6 +83,9 @@ func (*protoLang) GenerateRules(args language.GenerateArgs) language.GenerateRes
 				r.SetName(previous.Name())
 			}
 		}
+		if pc.lintMode != lintOff {
+			l.lint.addLibrary(pc, args.Rel, r.Name(), pkg)
+		}
 		if r.IsEmpty(protoKinds[r.Kind()]) {
 			res.Empty = append(res.Empty, r)
 		} else {
@@ -57,13 +57,24 @@ limitations under the License.
 // Gazelle has special cases for Well Known Types (i.e., imports of the form
 // google/protobuf/*.proto). These are resolved to rules in
 // @com_google_protobuf.
+//
+// Lint
+//
+// When enabled with -proto_lint or the "# gazelle:proto_lint" directive,
+// Gazelle checks the .proto files it generated rules for after dependencies
+// are resolved. It reports packages that don't match their directories,
+// conflicting go_package options within a proto_library, unused imports, and
+// import cycles between proto_library rules.
 package proto
 
 import "github.com/bazelbuild/bazel-gazelle/language"
 
 const protoName = "proto"
 
-type protoLang struct{}
+type protoLang struct {
+	// lint collects generated libraries for the lint pass run by Check.
+	lint linter
+}
 
 func (*protoLang) Name() string { return protoName }
 
@@ -0,0 +1,505 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/label"
+)
+
+// lintMode determines whether the proto lint pass runs and how its findings
+// are treated.
+type lintMode int
+
+const (
+	// lintOff disables the lint pass. This is the default.
+	lintOff lintMode = iota
+
+	// lintWarn reports problems without failing the run.
+	lintWarn
+
+	// lintError reports problems and causes Gazelle to exit with an error
+	// if any are found.
+	lintError
+)
+
+func lintModeFromString(s string) (lintMode, error) {
+	switch s {
+	case "off":
+		return lintOff, nil
+	case "warn":
+		return lintWarn, nil
+	case "error":
+		return lintError, nil
+	default:
+		return 0, fmt.Errorf("unrecognized proto lint mode: %q", s)
+	}
+}
+
+func (m lintMode) String() string {
+	switch m {
+	case lintOff:
+		return "off"
+	case lintWarn:
+		return "warn"
+	case lintError:
+		return "error"
+	default:
+		log.Panicf("unknown lint mode %d", m)
+		return ""
+	}
+}
+
+type lintModeFlag struct {
+	mode *lintMode
+}
+
+func (f *lintModeFlag) Set(value string) error {
+	mode, err := lintModeFromString(value)
+	if err != nil {
+		return err
+	}
+	*f.mode = mode
+	return nil
+}
+
+func (f *lintModeFlag) String() string {
+	var mode lintMode
+	if f != nil && f.mode != nil {
+		mode = *f.mode
+	}
+	return mode.String()
+}
+
+// Names of the checks performed by the lint pass. These appear in the Check
+// field of diagnostics.
+const (
+	checkPackageDirectory = "package_directory"
+	checkGoPackage        = "go_package"
+	checkUnusedImport     = "unused_import"
+	checkImportCycle      = "import_cycle"
+)
+
+// Diagnostic describes a problem found by the proto lint pass.
+type Diagnostic struct {
+	// Check is the name of the check that found the problem, for example
+	// "unused_import".
+	Check string `json:"check"`
+
+	// Severity is "warning" or "error", depending on the proto_lint mode
+	// in effect where the problem was found.
+	Severity string `json:"severity"`
+
+	// File is the slash-separated path to the .proto file with the problem,
+	// relative to the repository root. It is empty for problems that concern
+	// whole libraries, like import cycles.
+	File string `json:"file,omitempty"`
+
+	// Label is the proto_library the problem was found in.
+	Label string `json:"label"`
+
+	// Message is a human-readable description of the problem.
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	where := d.File
+	if where == "" {
+		where = d.Label
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", where, d.Severity, d.Message, d.Check)
+}
+
+// linter collects proto_library rules as they are generated, so they can be
+// checked together once generation is complete.
+type linter struct {
+	libs []*lintLibrary
+}
+
+// lintLibrary is a generated proto_library seen by the linter.
+type lintLibrary struct {
+	label label.Label
+	rel   string
+
+	// importDir is the directory part of the strings used to import files
+	// in this library, after strip_import_prefix and import_prefix are applied.
+	importDir string
+
+	pkg  *Package
+	mode lintMode
+}
+
+// lintFile is a .proto file seen by the linter, along with the identifiers
+// it refers to.
+type lintFile struct {
+	lib           *lintLibrary
+	info          FileInfo
+	refs          []string
+	hasExtend     bool
+	publicImports bool
+}
+
+func (lt *linter) addLibrary(pc *ProtoConfig, rel, name string, pkg *Package) {
+	lt.libs = append(lt.libs, &lintLibrary{
+		label:     label.New("", rel, name),
+		rel:       rel,
+		importDir: getPrefix(pc, rel),
+		pkg:       pkg,
+		mode:      pc.lintMode,
+	})
+}
+
+// Check runs the lint pass over the proto_library rules generated in this
+// run. Diagnostics are logged and, if -proto_lint_out is set, written to
+// a file as JSON. An error is returned if any diagnostic was found in a
+// directory where proto_lint is set to "error".
+func (l *protoLang) Check(c *config.Config) error {
+	pc := GetProtoConfig(c)
+	diags := l.lint.run()
+	for _, d := range diags {
+		log.Print(d)
+	}
+	if pc.lintOut != "" {
+		data, err := json.MarshalIndent(diags, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if err := os.WriteFile(pc.lintOut, data, 0o666); err != nil {
+			return err
+		}
+	}
+	errCount := 0
+	for _, d := range diags {
+		if d.Severity == "error" {
+			errCount++
+		}
+	}
+	if errCount > 0 {
+		return fmt.Errorf("proto lint found %d error(s)", errCount)
+	}
+	return nil
+}
+
+// run performs all checks and returns diagnostics in a stable order. The
+// result is never nil, so it's written as an empty JSON list when there are
+// no problems.
+func (lt *linter) run() []Diagnostic {
+	diags := []Diagnostic{}
+	files := make(map[string]*lintFile)
+	var fileList []*lintFile
+	for _, lib := range lt.libs {
+		for _, info := range lib.pkg.Files {
+			if _, err := os.Stat(info.Path); os.IsNotExist(err) {
+				// Generated files don't exist until build time.
+				continue
+			}
+			f := &lintFile{lib: lib, info: info}
+			if err := f.scan(); err != nil {
+				log.Print(err)
+				continue
+			}
+			files[path.Join(lib.importDir, info.Name)] = f
+			fileList = append(fileList, f)
+		}
+	}
+
+	for _, lib := range lt.libs {
+		diags = append(diags, checkGoPackageConsistency(lib)...)
+	}
+	for _, f := range fileList {
+		diags = append(diags, f.checkPackageDirectory()...)
+		diags = append(diags, f.checkUnusedImports(files)...)
+	}
+	diags = append(diags, lt.checkImportCycles(files)...)
+
+	sort.Slice(diags, func(i, j int) bool {
+		di, dj := diags[i], diags[j]
+		if di.Label != dj.Label {
+			return di.Label < dj.Label
+		}
+		if di.File != dj.File {
+			return di.File < dj.File
+		}
+		if di.Check != dj.Check {
+			return di.Check < dj.Check
+		}
+		return di.Message < dj.Message
+	})
+	return diags
+}
+
+func (lib *lintLibrary) diagnostic(check, file, msg string) Diagnostic {
+	severity := "warning"
+	if lib.mode == lintError {
+		severity = "error"
+	}
+	if file != "" {
+		file = path.Join(lib.rel, file)
+	}
+	return Diagnostic{
+		Check:    check,
+		Severity: severity,
+		File:     file,
+		Label:    lib.label.String(),
+		Message:  msg,
+	}
+}
+
+// checkGoPackageConsistency reports libraries whose files declare different
+// go_package options. Code generators for Go expect one package per library.
+func checkGoPackageConsistency(lib *lintLibrary) []Diagnostic {
+	filesByGoPackage := make(map[string][]string)
+	for _, info := range lib.pkg.Files {
+		for _, opt := range info.Options {
+			if opt.Key == "go_package" {
+				filesByGoPackage[opt.Value] = append(filesByGoPackage[opt.Value], info.Name)
+				break
+			}
+		}
+	}
+	if len(filesByGoPackage) < 2 {
+		return nil
+	}
+	goPackages := make([]string, 0, len(filesByGoPackage))
+	for goPackage, names := range filesByGoPackage {
+		sort.Strings(names)
+		goPackages = append(goPackages, fmt.Sprintf("%q (%s)", goPackage, strings.Join(names, ", ")))
+	}
+	sort.Strings(goPackages)
+	msg := fmt.Sprintf("files in the same proto_library declare different go_package options: %s", strings.Join(goPackages, ", "))
+	return []Diagnostic{lib.diagnostic(checkGoPackage, "", msg)}
+}
+
+// checkPackageDirectory reports files whose proto package does not match the
+// directory they are imported from. For example, a file in package foo.bar.v1
+// should be imported as ".../foo/bar/v1/name.proto".
+func (f *lintFile) checkPackageDirectory() []Diagnostic {
+	pkg := f.info.PackageName
+	if pkg == "" {
+		return nil
+	}
+	want := strings.ReplaceAll(pkg, ".", "/")
+	dir := f.lib.importDir
+	if dir == want || strings.HasSuffix(dir, "/"+want) {
+		return nil
+	}
+	msg := fmt.Sprintf("package %q should be in a directory ending with %q, but it is imported from %q", pkg, want, dir)
+	return []Diagnostic{f.lib.diagnostic(checkPackageDirectory, f.info.Name, msg)}
+}
+
+// checkUnusedImports reports imports of files that declare no symbol the
+// importing file refers to. Only imports of files seen by the linter can be
+// checked; others are assumed to be used.
+func (f *lintFile) checkUnusedImports(files map[string]*lintFile) []Diagnostic {
+	var diags []Diagnostic
+	for _, imp := range f.info.Imports {
+		target, ok := files[imp]
+		if !ok || target.publicImports {
+			continue
+		}
+		if f.refersTo(target) {
+			continue
+		}
+		msg := fmt.Sprintf("import %q is not used", imp)
+		diags = append(diags, f.lib.diagnostic(checkUnusedImport, f.info.Name, msg))
+	}
+	return diags
+}
+
+// refersTo returns whether any identifier in f may resolve to a message or
+// enum declared in target, following protobuf scoping rules: a relative name
+// is looked up in each enclosing package, innermost first. If target declares
+// extensions, any reference into its package counts, since extension names
+// aren't recorded.
+func (f *lintFile) refersTo(target *lintFile) bool {
+	targetPkg := target.info.PackageName
+	var decls []string
+	for _, names := range [][]string{target.info.Messages, target.info.Enums} {
+		for _, name := range names {
+			decls = append(decls, qualify(targetPkg, name))
+		}
+	}
+	for _, ref := range f.refs {
+		for _, full := range resolveCandidates(f.info.PackageName, ref) {
+			if target.hasExtend && (targetPkg == "" || strings.HasPrefix(full, targetPkg+".")) {
+				return true
+			}
+			for _, decl := range decls {
+				if full == decl || strings.HasPrefix(full, decl+".") {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// resolveCandidates returns the fully qualified names that ref may refer to
+// when used in a file with the given package.
+func resolveCandidates(pkg, ref string) []string {
+	if strings.HasPrefix(ref, ".") {
+		return []string{ref[1:]}
+	}
+	var names []string
+	for scope := pkg; ; {
+		names = append(names, qualify(scope, ref))
+		if scope == "" {
+			break
+		}
+		if i := strings.LastIndexByte(scope, '.'); i >= 0 {
+			scope = scope[:i]
+		} else {
+			scope = ""
+		}
+	}
+	return names
+}
+
+func qualify(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+// checkImportCycles reports sets of proto_library rules that import each
+// other, directly or transitively. Bazel rejects these.
+func (lt *linter) checkImportCycles(files map[string]*lintFile) []Diagnostic {
+	edges := make(map[*lintLibrary][]*lintLibrary)
+	for _, lib := range lt.libs {
+		seen := make(map[*lintLibrary]bool)
+		for _, info := range lib.pkg.Files {
+			for _, imp := range info.Imports {
+				target, ok := files[imp]
+				if !ok || target.lib == lib || seen[target.lib] {
+					continue
+				}
+				seen[target.lib] = true
+				edges[lib] = append(edges[lib], target.lib)
+			}
+		}
+	}
+
+	// Find strongly connected components with Tarjan's algorithm. Every
+	// component with more than one library is a cycle.
+	index := make(map[*lintLibrary]int)
+	lowLink := make(map[*lintLibrary]int)
+	onStack := make(map[*lintLibrary]bool)
+	var stack []*lintLibrary
+	var cycles [][]*lintLibrary
+	var visit func(lib *lintLibrary)
+	visit = func(lib *lintLibrary) {
+		index[lib] = len(index)
+		lowLink[lib] = index[lib]
+		stack = append(stack, lib)
+		onStack[lib] = true
+		for _, next := range edges[lib] {
+			if _, ok := index[next]; !ok {
+				visit(next)
+				lowLink[lib] = min(lowLink[lib], lowLink[next])
+			} else if onStack[next] {
+				lowLink[lib] = min(lowLink[lib], index[next])
+			}
+		}
+		if lowLink[lib] != index[lib] {
+			return
+		}
+		var component []*lintLibrary
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == lib {
+				break
+			}
+		}
+		if len(component) > 1 {
+			cycles = append(cycles, component)
+		}
+	}
+	for _, lib := range lt.libs {
+		if _, ok := index[lib]; !ok {
+			visit(lib)
+		}
+	}
+
+	var diags []Diagnostic
+	for _, cycle := range cycles {
+		sort.Slice(cycle, func(i, j int) bool {
+			return cycle[i].label.String() < cycle[j].label.String()
+		})
+		labels := make([]string, len(cycle))
+		report := cycle[0]
+		for i, lib := range cycle {
+			labels[i] = lib.label.String()
+			if lib.mode > report.mode {
+				report = lib
+			}
+		}
+		msg := fmt.Sprintf("proto_library targets form an import cycle: %s", strings.Join(labels, ", "))
+		d := report.diagnostic(checkImportCycle, "", msg)
+		d.Label = labels[0]
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+var (
+	// lintTokenRe matches comments and string literals together, so that
+	// comment markers within strings and quotes within comments are ignored.
+	lintTokenRe     = regexp.MustCompile(`(?s)"(?:[^"\\\n]|\\.)*"|'(?:[^'\\\n]|\\.)*'|//[^\n]*|/\*.*?\*/`)
+	lintStatementRe = regexp.MustCompile(`\b(?:package|import|syntax|edition)\b[^;]*;`)
+	lintExtendRe    = regexp.MustCompile(`\bextend\s`)
+	lintPublicRe    = regexp.MustCompile(`\bimport\s+public\b`)
+	lintIdentRe     = regexp.MustCompile(`\.?[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*`)
+)
+
+// scan reads the file and records the identifiers it refers to. Comments,
+// string literals, and package and import statements are ignored.
+func (f *lintFile) scan() error {
+	content, err := os.ReadFile(f.info.Path)
+	if err != nil {
+		return fmt.Errorf("%s: error reading proto file: %v", f.info.Path, err)
+	}
+	content = lintTokenRe.ReplaceAllFunc(content, func(tok []byte) []byte {
+		if tok[0] == '/' {
+			return nil
+		}
+		return []byte(`""`)
+	})
+	f.publicImports = lintPublicRe.Match(content)
+	content = lintStatementRe.ReplaceAll(content, nil)
+	f.hasExtend = lintExtendRe.Match(content)
+	seen := make(map[string]bool)
+	for _, ref := range lintIdentRe.FindAll(content, -1) {
+		if s := string(ref); !seen[s] {
+			seen[s] = true
+			f.refs = append(f.refs, s)
+		}
+	}
+	return nil
+}
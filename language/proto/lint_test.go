@@ -0,0 +1,176 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proto
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/config"
+	"github.com/bazelbuild/bazel-gazelle/language"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	"github.com/bazelbuild/bazel-gazelle/testtools"
+	"github.com/bazelbuild/bazel-gazelle/walk"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLint(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path:    "BUILD.old",
+			Content: "# gazelle:proto_lint warn",
+		},
+		{
+			Path: "foo/a.proto",
+			Content: `syntax = "proto3";
+package foo;
+option go_package = "example.com/repo/foo";
+import "bar/b.proto";
+import "baz/c.proto";
+// bar.B is mentioned here, but comments don't count.
+message A {
+  baz.C c = 1;
+}
+`,
+		},
+		{
+			Path: "foo/a2.proto",
+			Content: `syntax = "proto3";
+package foo;
+option go_package = "example.com/repo/foo;foopb";
+`,
+		},
+		{
+			Path: "bar/b.proto",
+			Content: `syntax = "proto3";
+package wrong.bar;
+message B {}
+`,
+		},
+		{
+			Path:    "baz/BUILD.old",
+			Content: "# gazelle:proto_lint error",
+		},
+		{
+			Path: "baz/c.proto",
+			Content: `syntax = "proto3";
+package baz;
+import "qux/d.proto";
+message C {
+  // The reference after the string counts, even though the string has "//".
+  string url = 2 [json_name = "http://example.com"]; .qux.D d = 1;
+}
+`,
+		},
+		{
+			Path: "qux/d.proto",
+			Content: `syntax = "proto3";
+package qux;
+import "baz/c.proto";
+message D {
+  baz.C c = 1;
+}
+`,
+		},
+	})
+	defer cleanup()
+
+	c, lang, cexts := testConfig(t, dir)
+	outPath := filepath.Join(dir, "lint.json")
+	GetProtoConfig(c).lintOut = outPath
+	walk.Walk(c, cexts, []string{dir}, walk.VisitAllUpdateSubdirsMode, func(dir, rel string, c *config.Config, update bool, oldFile *rule.File, subdirs, regularFiles, genFiles []string) {
+		lang.GenerateRules(language.GenerateArgs{
+			Config:       c,
+			Dir:          dir,
+			Rel:          rel,
+			File:         oldFile,
+			Subdirs:      subdirs,
+			RegularFiles: regularFiles,
+			GenFiles:     genFiles,
+		})
+	})
+
+	err := lang.(language.CheckingLanguage).Check(c)
+	if err == nil || !strings.Contains(err.Error(), "1 error(s)") {
+		t.Errorf("got error %v; want 1 error", err)
+	}
+
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Diagnostic
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []Diagnostic{
+		{
+			Check:    checkPackageDirectory,
+			Severity: "warning",
+			File:     "bar/b.proto",
+			Label:    "//bar:wrong_bar_proto",
+			Message:  `package "wrong.bar" should be in a directory ending with "wrong/bar", but it is imported from "bar"`,
+		},
+		{
+			Check:    checkImportCycle,
+			Severity: "error",
+			Label:    "//baz:baz_proto",
+			Message:  "proto_library targets form an import cycle: //baz:baz_proto, //qux:qux_proto",
+		},
+		{
+			Check:    checkGoPackage,
+			Severity: "warning",
+			Label:    "//foo:foopb_proto",
+			Message:  `files in the same proto_library declare different go_package options: "example.com/repo/foo" (a.proto), "example.com/repo/foo;foopb" (a2.proto)`,
+		},
+		{
+			Check:    checkUnusedImport,
+			Severity: "warning",
+			File:     "foo/a.proto",
+			Label:    "//foo:foopb_proto",
+			Message:  `import "bar/b.proto" is not used`,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("diagnostics (-want, +got):\n%s", diff)
+	}
+}
+
+func TestLintOff(t *testing.T) {
+	dir, cleanup := testtools.CreateFiles(t, []testtools.FileSpec{
+		{
+			Path: "foo/a.proto",
+			Content: `syntax = "proto3";
+package wrong;
+`,
+		},
+	})
+	defer cleanup()
+
+	c, lang, _ := testConfig(t, dir)
+	lang.GenerateRules(language.GenerateArgs{
+		Config:       c,
+		Dir:          filepath.Join(dir, "foo"),
+		Rel:          "foo",
+		RegularFiles: []string{"a.proto"},
+	})
+	if diags := lang.(*protoLang).lint.run(); len(diags) != 0 {
+		t.Errorf("got %d diagnostics with lint disabled; want 0", len(diags))
+	}
+}
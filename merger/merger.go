@@ -41,6 +41,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/rule"
 )
 
@@ -70,6 +71,19 @@ const (
 // TODO(jayconrod): make this stable *or* find a better way to express it.
 const UnstableInsertIndexKey = "_gazelle_insert_index"
 
+// CreateOnlyAttrsKey is the name of an internal attribute that may be set on
+// generated rules. Its value is a map[string]bool of attribute names. When
+// MergeFile merges a generated rule with an existing rule, these attributes
+// are neither copied nor merged into the existing rule, so they're only set
+// on rules MergeFile inserts.
+const CreateOnlyAttrsKey = "_gazelle_create_only_attrs"
+
+// MergeableAttrsKey is the name of an internal attribute that may be set on
+// generated rules. Its value is a map[string]bool of attribute names. When
+// MergeFile merges a generated rule with an existing rule, these attributes
+// are merged along with those in rule.KindInfo for the current phase.
+const MergeableAttrsKey = "_gazelle_mergeable_attrs"
+
 // MergeFile combines information from newly generated rules with matching
 // rules in an existing build file. MergeFile can also delete rules which
 // are empty after merging.
@@ -84,7 +98,10 @@ const UnstableInsertIndexKey = "_gazelle_insert_index"
 // genRules is a list of newly generated rules. These are merged with
 // matching rules. A rule matches if it has the same kind and name or if
 // some other attribute in rule.KindInfo.MatchAttrs matches (e.g.,
-// "importpath" in go_library). Elements of genRules that don't match
+// "importpath" in go_library). Generated rules that still don't match are
+// paired with existing rules of the same kind that share srcs or embed
+// values; those existing rules are renamed in place, and labels referring
+// to them in oldFile are updated. Elements of genRules that don't match
 // any existing rule are appended to the end of oldFile.
 //
 // phase indicates whether this is a pre- or post-resolve merge. Different
@@ -106,14 +123,21 @@ const UnstableInsertIndexKey = "_gazelle_insert_index"
 // If an attribute is marked with a "# keep" comment, it will not be merged.
 // If a rule is marked with a "# keep" comment, the whole rule will not
 // be modified.
+//
+// If values generated the last time were attached to genRules with
+// State.AttachBase, attributes are merged three ways instead, and edits
+// are preserved unless they conflict with changes in generated values.
 func MergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo, aliasedKinds map[string]string) {
-	getMergeAttrs := func(r *rule.Rule) map[string]bool {
+	phaseAttrs := func(info rule.KindInfo) map[string]bool {
 		if phase == PreResolve {
-			return kinds[r.Kind()].MergeableAttrs
+			return info.MergeableAttrs
 		} else {
-			return kinds[r.Kind()].ResolveAttrs
+			return info.ResolveAttrs
 		}
 	}
+	getMergeAttrs := func(r *rule.Rule) map[string]bool {
+		return phaseAttrs(kinds[r.Kind()])
+	}
 
 	// Merge empty rules into the file and delete any rules which become empty.
 	for _, emptyRule := range emptyRules {
@@ -150,6 +174,10 @@ func MergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phas
 		}
 	}
 
+	// Pair the remaining generated rules with existing rules that were
+	// probably renamed.
+	renames := matchRenamed(oldFile, genRules, matchRules, matchErrors, substitutions, aliasedKinds)
+
 	// Rename labels in generated rules that refer to other generated rules.
 	if len(substitutions) > 0 {
 		for _, genRule := range genRules {
@@ -169,9 +197,58 @@ func MergeFile(oldFile *rule.File, emptyRules, genRules []*rule.Rule, phase Phas
 				genRule.Insert(oldFile)
 			}
 		} else {
-			rule.MergeRules(genRule, matchRules[i], getMergeAttrs(genRule), oldFile.Path)
+			if oldName := matchRules[i].Name(); oldName != genRule.Name() && renames[oldName] == genRule.Name() {
+				matchRules[i].SetName(genRule.Name())
+				genRule.SetPrivateAttr(RenamedFromKey, oldName)
+			}
+			info := kinds[genRule.Kind()]
+			if matchRules[i].Kind() != genRule.Kind() {
+				// The existing rule has an aliased kind. Attributes declared for
+				// that kind are merged, too.
+				info = info.Union(kinds[matchRules[i].Kind()])
+			}
+			if extra, ok := genRule.PrivateAttr(MergeableAttrsKey).(map[string]bool); ok {
+				// Attributes marked on the generated rule are merged in both
+				// phases.
+				info = info.Union(rule.KindInfo{MergeableAttrs: extra, ResolveAttrs: extra})
+			}
+			mergeAttrs := phaseAttrs(info)
+			// Attributes only set on new rules are dropped. A rule inserted in
+			// an earlier phase matches itself, so it keeps them.
+			if createOnly, ok := genRule.PrivateAttr(CreateOnlyAttrsKey).(map[string]bool); ok && matchRules[i] != genRule {
+				mergeAttrs = withoutCreateOnlyAttrs(genRule, mergeAttrs, createOnly)
+			}
+			if base, ok := genRule.PrivateAttr(baseAttrsKey).(map[string]string); ok {
+				mergeAttrs = mergeBase(genRule, matchRules[i], base, mergeAttrs, oldFile.Path)
+			}
+			rule.MergeRules(genRule, matchRules[i], mergeAttrs, oldFile.Path)
+		}
+	}
+
+	// Update references to renamed rules in the same file.
+	if len(renames) > 0 {
+		labelRenames := make(map[label.Label]label.Label, len(renames))
+		for from, to := range renames {
+			labelRenames[label.New("", oldFile.Pkg, from)] = label.New("", oldFile.Pkg, to)
 		}
+		RenameLabels(oldFile, labelRenames)
+	}
+}
+
+// withoutCreateOnlyAttrs deletes the attributes in createOnly from the
+// generated rule r and returns the attributes in mergeable that remain, so
+// existing values of those attributes are left alone.
+func withoutCreateOnlyAttrs(r *rule.Rule, mergeable, createOnly map[string]bool) map[string]bool {
+	remaining := make(map[string]bool, len(mergeable))
+	for key, ok := range mergeable {
+		if !createOnly[key] {
+			remaining[key] = ok
+		}
+	}
+	for key := range createOnly {
+		r.DelAttr(key)
 	}
+	return remaining
 }
 
 // substituteRule replaces local labels (those beginning with ":", referring to
@@ -973,6 +973,99 @@ go_library(
 			"my_go_library": "go_library",
 		},
 	},
+	{
+		desc: "rename by srcs",
+		previous: `
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+# Unit tests.
+go_test(
+    name = "go_default_test",
+    size = "small",
+    srcs = ["lib_test.go"],
+    data = glob(["testdata/*"]),
+    deps = ["//extra"],  # keep
+)
+
+test_suite(
+    name = "all_tests",
+    tests = [":go_default_test"],
+)
+`,
+		current: `
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+go_test(
+    name = "lib_test",
+    srcs = ["lib_test.go"],
+)
+`,
+		expected: `
+load("@io_bazel_rules_go//go:def.bzl", "go_test")
+
+# Unit tests.
+go_test(
+    name = "lib_test",
+    size = "small",
+    srcs = ["lib_test.go"],
+    data = glob(["testdata/*"]),
+    deps = ["//extra"],  # keep
+)
+
+test_suite(
+    name = "all_tests",
+    tests = [":lib_test"],
+)
+`,
+	},
+	{
+		desc: "rename by embed",
+		previous: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "old_test",
+    srcs = ["old_test.go"],
+    embed = [":lib"],
+)
+`,
+		current: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "lib_test",
+    srcs = ["new_test.go"],
+    embed = [":lib"],
+)
+`,
+		expected: `
+load("@io_bazel_rules_go//go:def.bzl", "go_library", "go_test")
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "lib_test",
+    srcs = ["new_test.go"],
+    embed = [":lib"],
+)
+`,
+	},
 }
 
 func TestMergeFile(t *testing.T) {
@@ -0,0 +1,170 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merger
+
+import (
+	"strings"
+
+	"github.com/bazelbuild/bazel-gazelle/label"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// RenamedFromKey is the name of an internal attribute that MergeFile sets on
+// existing rules it renamed to match a generated rule. Its value is the
+// rule's previous name. Callers may use it to update references to the rule
+// in other files with RenameLabels.
+const RenamedFromKey = "_gazelle_renamed_from"
+
+// matchRenamed pairs generated rules that didn't match any existing rule
+// with existing rules of the same kind that are probably the same rules
+// under different names, for example, after the naming convention changed.
+// A generated rule is paired with the unmatched existing rule that has the
+// most sources in common with it. Rules that still aren't paired are then
+// compared the same way by the libraries they embed, so tests follow their
+// renamed libraries.
+//
+// matchRenamed updates matchRules with the pairs it finds. It returns a map
+// from the previous names of existing rules to the names of the generated
+// rules they should be renamed to. Existing rules marked with "# keep" are
+// paired but not renamed; instead, their names are added to substitutions.
+func matchRenamed(oldFile *rule.File, genRules, matchRules []*rule.Rule, matchErrors []error, substitutions map[string]string, aliasedKinds map[string]string) map[string]string {
+	claimed := make(map[*rule.Rule]bool)
+	genNames := make(map[string]bool)
+	for i, genRule := range genRules {
+		genNames[genRule.Name()] = true
+		if matchRules[i] != nil {
+			claimed[matchRules[i]] = true
+		}
+	}
+
+	renames := make(map[string]string)
+	pair := func(i int, oldRule *rule.Rule) {
+		matchRules[i] = oldRule
+		claimed[oldRule] = true
+		if oldRule.ShouldKeep() {
+			substitutions[genRules[i].Name()] = oldRule.Name()
+		} else {
+			renames[oldRule.Name()] = genRules[i].Name()
+		}
+	}
+	// Embedded labels are compared by the names the rules they refer to will
+	// have after merging.
+	genFinalName := func(name string) string {
+		if subst, ok := substitutions[name]; ok {
+			return subst
+		}
+		return name
+	}
+	oldFinalName := func(name string) string {
+		if rename, ok := renames[name]; ok {
+			return rename
+		}
+		return name
+	}
+
+	for _, key := range []string{"srcs", "embed"} {
+		for i, genRule := range genRules {
+			if matchRules[i] != nil || matchErrors[i] != nil || genRule.Name() == "" {
+				continue
+			}
+			genValues := make(map[string]bool)
+			for _, v := range genRule.AttrStrings(key) {
+				if key == "embed" {
+					v = ":" + genFinalName(strings.TrimPrefix(v, ":"))
+				}
+				genValues[v] = true
+			}
+			if len(genValues) == 0 {
+				continue
+			}
+
+			var best *rule.Rule
+			bestCount, ambiguous := 0, false
+			for _, oldRule := range oldFile.Rules {
+				if claimed[oldRule] || genNames[oldRule.Name()] ||
+					(oldRule.Kind() != genRule.Kind() && aliasedKinds[oldRule.Kind()] != genRule.Kind()) {
+					continue
+				}
+				count := 0
+				for _, v := range oldRule.AttrStrings(key) {
+					if key == "embed" {
+						v = ":" + oldFinalName(strings.TrimPrefix(v, ":"))
+					}
+					if genValues[v] {
+						count++
+					}
+				}
+				if count > bestCount {
+					best, bestCount, ambiguous = oldRule, count, false
+				} else if count > 0 && count == bestCount {
+					ambiguous = true
+				}
+			}
+			if best != nil && !ambiguous {
+				pair(i, best)
+			}
+		}
+	}
+	return renames
+}
+
+// RenameLabels replaces labels in attributes of rules in f that refer to
+// renamed targets. renames maps absolute labels of targets in the main
+// repository (with an empty Repo) to their new labels. Relative labels are
+// resolved against the package of f. Replacements keep the form of the
+// labels they replace: relative labels stay relative. RenameLabels returns
+// whether any label was replaced.
+func RenameLabels(f *rule.File, renames map[label.Label]label.Label) bool {
+	if len(renames) == 0 {
+		return false
+	}
+	renamed := false
+	for _, r := range f.Rules {
+		for _, key := range r.AttrKeys() {
+			if key == "name" {
+				continue
+			}
+			expr := r.Attr(key)
+			changed := false
+			bzl.Walk(expr, func(x bzl.Expr, _ []bzl.Expr) {
+				str, ok := x.(*bzl.StringExpr)
+				if !ok || (!strings.HasPrefix(str.Value, ":") && !strings.HasPrefix(str.Value, "//")) {
+					return
+				}
+				l, err := label.Parse(str.Value)
+				if err != nil {
+					return
+				}
+				to, ok := renames[l.Abs("", f.Pkg)]
+				if !ok {
+					return
+				}
+				changed = true
+				if l.Relative {
+					str.Value = to.Rel("", f.Pkg).String()
+				} else {
+					str.Value = to.String()
+				}
+			})
+			if changed {
+				r.SetAttr(key, expr)
+				renamed = true
+			}
+		}
+	}
+	return renamed
+}
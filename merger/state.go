@@ -0,0 +1,294 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merger
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/bazelbuild/bazel-gazelle/rule"
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// State records the values Gazelle generated for mergeable attributes the
+// last time it ran. It's stored in a file between runs.
+//
+// When generated rules carry the values recorded for them (see
+// State.AttachBase), MergeFile merges each attribute three ways: the
+// recorded value is the common base of the value in the build file, which
+// may have been edited by hand, and the newly generated value. Edits that
+// don't conflict with changes in the generated value are preserved, even
+// without "# keep" comments.
+type State struct {
+	// Packages maps slash-separated package paths, relative to the repository
+	// root, to the rules generated in each package. Each rule maps attribute
+	// names to formatted values. Attributes that weren't generated are omitted.
+	Packages map[string]map[string]map[string]string `json:"packages"`
+}
+
+// ReadState reads a state file written by State.Write. If the file doesn't
+// exist, ReadState returns an empty state.
+func ReadState(path string) (*State, error) {
+	s := &State{Packages: make(map[string]map[string]map[string]string)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Packages == nil {
+		s.Packages = make(map[string]map[string]map[string]string)
+	}
+	return s, nil
+}
+
+// Write writes the state to a file.
+func (s *State) Write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o666)
+}
+
+// AttachBase sets the values recorded for rules in the package pkg on
+// generated rules with the same names, so MergeFile can merge them three
+// ways. It should be called before Record replaces the recorded values.
+func (s *State) AttachBase(pkg string, genRules []*rule.Rule) {
+	for _, r := range genRules {
+		if base, ok := s.Packages[pkg][r.Name()]; ok {
+			r.SetPrivateAttr(baseAttrsKey, base)
+		}
+	}
+}
+
+// Record records the values of attributes merged in phase for rules generated
+// in the package pkg. Values recorded for the package in earlier runs are
+// discarded in the PreResolve phase, so rules that are no longer generated
+// are forgotten. Record must be called before MergeFile, which may modify the
+// generated rules.
+func (s *State) Record(pkg string, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo) {
+	s.record(pkg, genRules, phase, kinds, phase == PreResolve)
+}
+
+// RecordRules is like Record, but values recorded in earlier runs for rules
+// not in genRules are kept. It's used when only some of the rules in a
+// package are merged.
+func (s *State) RecordRules(pkg string, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo) {
+	s.record(pkg, genRules, phase, kinds, false)
+}
+
+func (s *State) record(pkg string, genRules []*rule.Rule, phase Phase, kinds map[string]rule.KindInfo, reset bool) {
+	rules := s.Packages[pkg]
+	if reset || rules == nil {
+		rules = make(map[string]map[string]string)
+	}
+	for _, r := range genRules {
+		attrs := kinds[r.Kind()].MergeableAttrs
+		if phase == PostResolve {
+			attrs = kinds[r.Kind()].ResolveAttrs
+		}
+		values := rules[r.Name()]
+		if values == nil || phase == PreResolve {
+			values = make(map[string]string)
+		}
+		for key := range attrs {
+			if expr := r.Attr(key); expr != nil {
+				values[key] = bzl.FormatString(expr)
+			}
+		}
+		rules[r.Name()] = values
+	}
+	if len(rules) == 0 {
+		delete(s.Packages, pkg)
+	} else {
+		s.Packages[pkg] = rules
+	}
+}
+
+// baseAttrsKey is the name of a private attribute set by State.AttachBase.
+// Its value is a map from attribute names to values formatted the last time
+// the rule was generated.
+const baseAttrsKey = "_gazelle_base_attrs"
+
+// mergeBase prepares a three-way merge of the attributes in mergeable from
+// the generated rule src into the existing rule dst. base holds the values
+// generated the last time. mergeBase returns the attributes that
+// rule.MergeRules should still replace, and it may modify src.
+//
+// For each attribute edited in dst since it was generated:
+//
+//   - If the generated value didn't change, the edit is preserved.
+//   - If both are lists of strings, strings added or removed by hand are added
+//     to or removed from the generated list.
+//   - Otherwise, the edit conflicts with the new value. The conflict is
+//     logged, and the generated value replaces the edit as it would without
+//     a base.
+func mergeBase(src, dst *rule.Rule, base map[string]string, mergeable map[string]bool, filename string) map[string]bool {
+	if dst.ShouldKeep() {
+		return mergeable
+	}
+	merged := make(map[string]bool, len(mergeable))
+	for key := range mergeable {
+		merged[key] = true
+		dstExpr := dst.Attr(key)
+		if c := dst.AttrComments(key); c != nil && rule.ShouldKeep(&bzl.CommentBlock{Comments: *c}) {
+			continue
+		}
+		var baseExpr bzl.Expr
+		if b, ok := base[key]; ok {
+			f, err := bzl.ParseBuild("", []byte(b))
+			if err != nil || len(f.Stmt) != 1 {
+				continue
+			}
+			baseExpr = f.Stmt[0]
+		}
+		srcExpr := src.Attr(key)
+		if sameValue(dstExpr, baseExpr) || sameValue(dstExpr, srcExpr) {
+			continue
+		}
+		if sameValue(srcExpr, baseExpr) {
+			// Only dst was edited. Keep it.
+			delete(merged, key)
+			if dstExpr == nil {
+				src.DelAttr(key)
+			}
+			continue
+		}
+
+		dstList, dstOk := stringList(dstExpr)
+		baseList, baseOk := stringList(baseExpr)
+		srcList, srcOk := stringList(srcExpr)
+		if dstOk && baseOk && srcOk {
+			if list := mergeStringLists(baseList, dstList, srcList); len(list) == 0 {
+				src.DelAttr(key)
+			} else {
+				src.SetAttr(key, list)
+			}
+			continue
+		}
+
+		where := filename
+		if dstExpr != nil {
+			start, end := dstExpr.Span()
+			where = fmt.Sprintf("%s:%d.%d-%d.%d", filename, start.Line, start.LineRune, end.Line, end.LineRune)
+		}
+		log.Printf("%s: conflict merging attribute %q of %s(%s): it was edited, and the generated value changed from %s to %s. The edit was replaced; mark the attribute with a \"# keep\" comment to preserve it.",
+			where, key, dst.Kind(), dst.Name(), formatValue(baseExpr), formatValue(srcExpr))
+	}
+	return merged
+}
+
+// sameValue returns whether x and y have the same value. Comments and line
+// breaks are ignored. Lists of strings are compared as sets, since Gazelle
+// sorts most of them. nil is only the same as nil.
+func sameValue(x, y bzl.Expr) bool {
+	if x == nil || y == nil {
+		return x == nil && y == nil
+	}
+	xl, xok := stringList(x)
+	yl, yok := stringList(y)
+	if xok && yok {
+		xs := stringSet(xl)
+		ys := stringSet(yl)
+		if len(xs) != len(ys) {
+			return false
+		}
+		for s := range xs {
+			if !ys[s] {
+				return false
+			}
+		}
+		return true
+	}
+	return formatValue(x) == formatValue(y)
+}
+
+// stringList returns the strings in x if it's a list of string literals.
+// A missing attribute (nil) is an empty list.
+func stringList(x bzl.Expr) ([]string, bool) {
+	if x == nil {
+		return nil, true
+	}
+	l, ok := x.(*bzl.ListExpr)
+	if !ok {
+		return nil, false
+	}
+	strs := make([]string, 0, len(l.List))
+	for _, e := range l.List {
+		s, ok := e.(*bzl.StringExpr)
+		if !ok {
+			return nil, false
+		}
+		strs = append(strs, s.Value)
+	}
+	return strs, true
+}
+
+func stringSet(strs []string) map[string]bool {
+	set := make(map[string]bool, len(strs))
+	for _, s := range strs {
+		set[s] = true
+	}
+	return set
+}
+
+// mergeStringLists applies the strings added to and removed from base in
+// edited to generated.
+func mergeStringLists(base, edited, generated []string) []string {
+	baseSet := stringSet(base)
+	editedSet := stringSet(edited)
+	seen := make(map[string]bool)
+	var merged []string
+	for _, s := range generated {
+		if (baseSet[s] && !editedSet[s]) || seen[s] {
+			continue
+		}
+		seen[s] = true
+		merged = append(merged, s)
+	}
+	var added []string
+	for _, s := range edited {
+		if !baseSet[s] && !seen[s] {
+			seen[s] = true
+			added = append(added, s)
+		}
+	}
+	sort.Strings(added)
+	return append(merged, added...)
+}
+
+// formatValue formats x for comparisons and messages. Lists of strings are
+// formatted on one line without comments.
+func formatValue(x bzl.Expr) string {
+	if x == nil {
+		return "nothing"
+	}
+	if strs, ok := stringList(x); ok {
+		l := &bzl.ListExpr{}
+		for _, s := range strs {
+			l.List = append(l.List, &bzl.StringExpr{Value: s})
+		}
+		x = l
+	}
+	return bzl.FormatString(x)
+}
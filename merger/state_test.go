@@ -0,0 +1,148 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package merger_test
+
+import (
+	"bytes"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/merger"
+	"github.com/bazelbuild/bazel-gazelle/rule"
+)
+
+func TestMergeFileWithState(t *testing.T) {
+	const (
+		base = `
+go_library(
+    name = "go_default_library",
+    srcs = [
+        "a.go",
+        "b.go",
+    ],
+    importmap = "example.com/old",
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "go_default_test",
+    srcs = ["a_test.go"],
+)
+`
+		previous = `
+go_library(
+    name = "go_default_library",
+    srcs = [
+        "a.go",
+        "b.go",
+        "extra.go",
+    ],
+    cgo = True,
+    importmap = "example.com/edited",
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "go_default_test",
+    srcs = ["a_test.go"],
+)
+`
+		current = `
+go_library(
+    name = "go_default_library",
+    srcs = [
+        "a.go",
+        "c.go",
+    ],
+    importmap = "example.com/new",
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "go_default_test",
+    srcs = ["c_test.go"],
+)
+`
+		expected = `go_library(
+    name = "go_default_library",
+    srcs = [
+        "a.go",
+        "c.go",
+        "extra.go",
+    ],
+    cgo = True,
+    importmap = "example.com/new",
+    importpath = "example.com/lib",
+)
+
+go_test(
+    name = "go_default_test",
+    srcs = ["c_test.go"],
+)
+`
+	)
+
+	// Record the values generated by the previous run, and check they're
+	// written and read back.
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	s, err := merger.ReadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseFile, err := rule.LoadData("BUILD.bazel", "", []byte(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Record("", baseFile.Rules, merger.PreResolve, testKinds)
+	if err := s.Write(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = merger.ReadState(statePath); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Packages[""]["go_default_library"]["importmap"], `"example.com/old"`; got != want {
+		t.Errorf("recorded importmap: got %s; want %s", got, want)
+	}
+
+	f, err := rule.LoadData("BUILD.bazel", "", []byte(previous))
+	if err != nil {
+		t.Fatal(err)
+	}
+	genFile, err := rule.LoadData("BUILD.bazel", "", []byte(current))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.AttachBase("", genFile.Rules)
+	s.Record("", genFile.Rules, merger.PreResolve, testKinds)
+
+	var logBuf bytes.Buffer
+	logOut := log.Writer()
+	log.SetOutput(&logBuf)
+	merger.MergeFile(f, nil, genFile.Rules, merger.PreResolve, testKinds, nil)
+	log.SetOutput(logOut)
+
+	if got := string(f.Format()); got != expected {
+		t.Errorf("got:\n%s\nwant:\n%s", got, expected)
+	}
+	if got := logBuf.String(); !strings.Contains(got, `conflict merging attribute "importmap" of go_library(go_default_library)`) {
+		t.Errorf("importmap conflict not reported; log:\n%s", got)
+	}
+	if got, want := s.Packages[""]["go_default_test"]["srcs"], `["c_test.go"]`; got != want {
+		t.Errorf("recorded srcs: got %s; want %s", got, want)
+	}
+}
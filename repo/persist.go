@@ -0,0 +1,381 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy"
+	"golang.org/x/mod/semver"
+)
+
+// PersistentCacheConfig configures an on-disk cache for RemoteCache. Results
+// of network lookups are written to the cache when RemoteCache is cleaned up
+// and read back by later runs, until they expire.
+type PersistentCacheConfig struct {
+	// Dir is the directory where the cache is stored. It is created if it
+	// doesn't exist.
+	Dir string
+
+	// Refresh causes entries already in the cache to be ignored. Results
+	// looked up during this run still replace them.
+	Refresh bool
+
+	// StableTTL is how long results that rarely change are kept: repository
+	// roots and remotes, module paths, sums of exact module versions, and
+	// requirements of exact module versions. DefaultStableTTL is used if zero.
+	StableTTL time.Duration
+
+	// VolatileTTL is how long results that change as repositories are
+	// updated are kept: the latest commits of repositories, versions
+	// matching queries like "latest", and lists of module versions.
+	// DefaultVolatileTTL is used if zero.
+	VolatileTTL time.Duration
+
+	// ErrorTTL is how long failed lookups are kept. DefaultErrorTTL is used
+	// if zero.
+	ErrorTTL time.Duration
+}
+
+const (
+	DefaultStableTTL   = 7 * 24 * time.Hour
+	DefaultVolatileTTL = time.Hour
+	DefaultErrorTTL    = 15 * time.Minute
+)
+
+// persistentCacheFile is the name of the file within the cache directory.
+// The version should be changed when the format changes incompatibly.
+const persistentCacheFile = "remote-cache-v2.json"
+
+// persistentCacheEnv lists the go environment variables that affect the
+// results of lookups. Entries are keyed by their values, so runs with
+// different settings don't share results.
+var persistentCacheEnv = []string{"GOPROXY", "GONOPROXY", "GOPRIVATE", "GONOSUMDB", "GOSUMDB", "GOFLAGS"}
+
+// persistedCache is the on-disk format of the cache. Each field maps keys of
+// the corresponding remoteCacheMap, prefixed with a hash of the environment
+// (see persistentCacheEnvKey), to entries.
+type persistedCache struct {
+	Root        map[string]persistedEntry `json:"root,omitempty"`
+	Remote      map[string]persistedEntry `json:"remote,omitempty"`
+	Head        map[string]persistedEntry `json:"head,omitempty"`
+	Mod         map[string]persistedEntry `json:"mod,omitempty"`
+	ModVersion  map[string]persistedEntry `json:"mod_version,omitempty"`
+	ModVersions map[string]persistedEntry `json:"mod_versions,omitempty"`
+	ModRequires map[string]persistedEntry `json:"mod_requires,omitempty"`
+}
+
+// persistedEntry is a cached result. Only the fields relevant to the map it
+// belongs to are set. Err is set for failed lookups, and NotFound is set if
+// that error matched goproxy.ErrNotFound.
+type persistedEntry struct {
+	Expires  time.Time `json:"expires"`
+	Err      string    `json:"err,omitempty"`
+	NotFound bool      `json:"not_found,omitempty"`
+
+	Root    string `json:"root,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Remote  string `json:"remote,omitempty"`
+	VCS     string `json:"vcs,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Sum     string `json:"sum,omitempty"`
+
+	Versions []string          `json:"versions,omitempty"`
+	Requires map[string]string `json:"requires,omitempty"`
+}
+
+// cachedError is a failed lookup loaded from the persistent cache. It has
+// the message of the original error and matches goproxy.ErrNotFound if the
+// original error did.
+type cachedError struct {
+	msg      string
+	notFound bool
+}
+
+func (e *cachedError) Error() string { return e.msg }
+
+func (e *cachedError) Is(target error) bool {
+	return e.notFound && target == goproxy.ErrNotFound
+}
+
+// persistentCacheEnvKey returns a short hash of the go environment variables
+// that affect lookups. It's used as a prefix for keys in the cache.
+func persistentCacheEnvKey() string {
+	h := sha256.New()
+	for _, key := range persistentCacheEnv {
+		fmt.Fprintf(h, "%s=%s\n", key, goproxy.Getenv(key))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// persistentMap describes how entries of one remoteCacheMap are stored.
+type persistentMap struct {
+	m      *remoteCacheMap
+	field  func(*persistedCache) *map[string]persistedEntry
+	encode func(value interface{}) persistedEntry
+	decode func(e persistedEntry) interface{}
+
+	// volatile reports whether a successful result for key may change soon.
+	volatile func(key string) bool
+}
+
+func (r *RemoteCache) persistentMaps() []persistentMap {
+	never := func(string) bool { return false }
+	return []persistentMap{
+		{
+			m:     &r.root,
+			field: func(c *persistedCache) *map[string]persistedEntry { return &c.Root },
+			encode: func(v interface{}) persistedEntry {
+				v2 := v.(rootValue)
+				return persistedEntry{Root: v2.root, Name: v2.name}
+			},
+			decode:   func(e persistedEntry) interface{} { return rootValue{root: e.Root, name: e.Name} },
+			volatile: never,
+		}, {
+			m:     &r.remote,
+			field: func(c *persistedCache) *map[string]persistedEntry { return &c.Remote },
+			encode: func(v interface{}) persistedEntry {
+				v2 := v.(remoteValue)
+				return persistedEntry{Remote: v2.remote, VCS: v2.vcs}
+			},
+			decode:   func(e persistedEntry) interface{} { return remoteValue{remote: e.Remote, vcs: e.VCS} },
+			volatile: never,
+		}, {
+			m:     &r.head,
+			field: func(c *persistedCache) *map[string]persistedEntry { return &c.Head },
+			encode: func(v interface{}) persistedEntry {
+				v2 := v.(headValue)
+				return persistedEntry{Commit: v2.commit, Tag: v2.tag}
+			},
+			decode:   func(e persistedEntry) interface{} { return headValue{commit: e.Commit, tag: e.Tag} },
+			volatile: func(string) bool { return true },
+		}, {
+			m:     &r.mod,
+			field: func(c *persistedCache) *map[string]persistedEntry { return &c.Mod },
+			encode: func(v interface{}) persistedEntry {
+				v2 := v.(modValue)
+				return persistedEntry{Path: v2.path, Name: v2.name}
+			},
+			decode:   func(e persistedEntry) interface{} { return modValue{path: e.Path, name: e.Name} },
+			volatile: never,
+		}, {
+			m:     &r.modVersion,
+			field: func(c *persistedCache) *map[string]persistedEntry { return &c.ModVersion },
+			encode: func(v interface{}) persistedEntry {
+				v2 := v.(modVersionValue)
+				return persistedEntry{Path: v2.path, Version: v2.version, Sum: v2.sum}
+			},
+			decode: func(e persistedEntry) interface{} {
+				return modVersionValue{path: e.Path, version: e.Version, sum: e.Sum}
+			},
+			volatile: func(key string) bool {
+				// Keys are modPath@query. Only exact versions are immutable.
+				query := key[strings.LastIndex(key, "@")+1:]
+				return !semver.IsValid(query) || semver.Canonical(query) != query && !strings.HasSuffix(query, "+incompatible")
+			},
+		}, {
+			m:     &r.modVersions,
+			field: func(c *persistedCache) *map[string]persistedEntry { return &c.ModVersions },
+			encode: func(v interface{}) persistedEntry {
+				return persistedEntry{Versions: v.([]string)}
+			},
+			decode: func(e persistedEntry) interface{} { return e.Versions },
+			// New versions may be published at any time.
+			volatile: func(string) bool { return true },
+		}, {
+			m:     &r.modRequires,
+			field: func(c *persistedCache) *map[string]persistedEntry { return &c.ModRequires },
+			encode: func(v interface{}) persistedEntry {
+				return persistedEntry{Requires: v.(map[string]string)}
+			},
+			decode: func(e persistedEntry) interface{} {
+				if e.Requires == nil {
+					// Modules without requirements have an empty map.
+					return map[string]string{}
+				}
+				return e.Requires
+			},
+			// Keys are modPath@version with canonical versions.
+			volatile: never,
+		},
+	}
+}
+
+// UsePersistentCache loads results of earlier lookups from the cache
+// directory and arranges for results of lookups made from now on to be
+// saved there by the cleanup function returned by NewRemoteCache. It should
+// be called before any lookups are made.
+//
+// Entries for repositories passed to NewRemoteCache take precedence over
+// cached entries and are not saved.
+func (r *RemoteCache) UsePersistentCache(cfg PersistentCacheConfig) error {
+	if cfg.Dir == "" {
+		return errors.New("persistent cache directory not set")
+	}
+	if cfg.StableTTL == 0 {
+		cfg.StableTTL = DefaultStableTTL
+	}
+	if cfg.VolatileTTL == 0 {
+		cfg.VolatileTTL = DefaultVolatileTTL
+	}
+	if cfg.ErrorTTL == 0 {
+		cfg.ErrorTTL = DefaultErrorTTL
+	}
+	r.persist = &cfg
+	r.persistEnvKey = persistentCacheEnvKey()
+
+	if cfg.Refresh {
+		return nil
+	}
+	cached, err := readPersistedCache(cfg.Dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	prefix := r.persistEnvKey + " "
+	for _, pm := range r.persistentMaps() {
+		pm.m.mu.Lock()
+		for key, e := range *pm.field(cached) {
+			key, ok := strings.CutPrefix(key, prefix)
+			if !ok || !e.Expires.After(now) {
+				continue
+			}
+			if _, ok := pm.m.cache[key]; ok {
+				continue
+			}
+			entry := &remoteCacheEntry{}
+			if e.Err != "" {
+				entry.err = &cachedError{msg: e.Err, notFound: e.NotFound}
+			} else {
+				entry.value = pm.decode(e)
+			}
+			pm.m.cache[key] = entry
+		}
+		pm.m.mu.Unlock()
+	}
+	return nil
+}
+
+// savePersistentCache writes entries looked up during this run to the cache
+// directory, merged with unexpired entries already there.
+func (r *RemoteCache) savePersistentCache() error {
+	cfg := r.persist
+	cached, err := readPersistedCache(cfg.Dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	changed := false
+	for _, pm := range r.persistentMaps() {
+		entries := *pm.field(cached)
+		if entries == nil {
+			entries = make(map[string]persistedEntry)
+		}
+		for key, e := range entries {
+			if !e.Expires.After(now) {
+				delete(entries, key)
+				changed = true
+			}
+		}
+
+		pm.m.mu.Lock()
+		for key, e := range pm.m.cache {
+			if !e.loaded || e.ready == nil {
+				// Entries from known repositories, go.mod files, and the
+				// persistent cache itself are not saved.
+				continue
+			}
+			select {
+			case <-e.ready:
+			default:
+				// Still loading.
+				continue
+			}
+			var pe persistedEntry
+			switch {
+			case e.err != nil:
+				pe = persistedEntry{
+					Err:      e.err.Error(),
+					NotFound: errors.Is(e.err, goproxy.ErrNotFound),
+					Expires:  now.Add(cfg.ErrorTTL),
+				}
+			case pm.volatile(key):
+				pe = pm.encode(e.value)
+				pe.Expires = now.Add(cfg.VolatileTTL)
+			default:
+				pe = pm.encode(e.value)
+				pe.Expires = now.Add(cfg.StableTTL)
+			}
+			entries[r.persistEnvKey+" "+key] = pe
+			changed = true
+		}
+		pm.m.mu.Unlock()
+		*pm.field(cached) = entries
+	}
+	if !changed {
+		return nil
+	}
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o777); err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it, so concurrent runs never see
+	// a partially written cache.
+	tmp, err := os.CreateTemp(cfg.Dir, persistentCacheFile+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(cfg.Dir, persistentCacheFile))
+}
+
+func readPersistedCache(dir string) (*persistedCache, error) {
+	c := &persistedCache{}
+	data, err := os.ReadFile(filepath.Join(dir, persistentCacheFile))
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		// A corrupt cache is ignored. It's replaced when the cache is saved.
+		return &persistedCache{}, nil
+	}
+	return c, nil
+}
@@ -0,0 +1,116 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy"
+)
+
+func TestPersistentCache(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GOENV", "off")
+	t.Setenv("GOPROXY", "https://proxy.example.com")
+
+	// lookupCounts counts the lookups a RemoteCache makes.
+	type lookupCounts struct {
+		mod, head, version, versions, requires int
+	}
+
+	// countingCache returns a RemoteCache using the persistent cache in dir
+	// and counters for the lookups it makes.
+	countingCache := func(cfg PersistentCacheConfig) (rc *RemoteCache, cleanup func() error, counts *lookupCounts) {
+		rc, cleanup = NewRemoteCache([]Repo{{Name: "custom_known", GoPrefix: "example.com/known"}})
+		counts = &lookupCounts{}
+		rc.ModInfo = func(importPath string) (string, error) {
+			counts.mod++
+			modPath, err := stubModInfo(importPath)
+			if err != nil {
+				return "", fmt.Errorf("%v: %w", err, goproxy.ErrNotFound)
+			}
+			return modPath, nil
+		}
+		rc.HeadCmd = func(remote, vcs string) (string, error) {
+			counts.head++
+			return stubHeadCmd(remote, vcs)
+		}
+		rc.ModVersionInfo = func(modPath, query string) (string, string, error) {
+			counts.version++
+			return stubModVersionInfo(modPath, query)
+		}
+		rc.ModVersionsInfo = func(modPath string) ([]string, error) {
+			counts.versions++
+			return []string{"v1.0.0", "v1.2.3"}, nil
+		}
+		rc.ModRequiresInfo = func(modPath, version string) (map[string]string, error) {
+			counts.requires++
+			return map[string]string{"example.com/dep": "v1.0.0"}, nil
+		}
+		cfg.Dir = dir
+		if err := rc.UsePersistentCache(cfg); err != nil {
+			t.Fatal(err)
+		}
+		return rc, cleanup, counts
+	}
+
+	lookup := func(rc *RemoteCache) string {
+		modPath, _, err := rc.Mod("example.com/stub/pkg")
+		_, _, missingErr := rc.Mod("example.com/missing")
+		commit, _, headErr := rc.Head("https://example.com/repo", "git")
+		_, version, sum, versionErr := rc.ModVersion("example.com/unknown", "v1.2.3")
+		_, _, known, _ := rc.ModVersion("example.com/known", "latest")
+		versions, versionsErr := rc.ModVersions("example.com/unknown")
+		requires, requiresErr := rc.ModRequires("example.com/unknown", "v1.2.3")
+		return fmt.Sprintf("%s %v %v %s %v %s %s %v %s %v %v %v %v",
+			modPath, err, errors.Is(missingErr, goproxy.ErrNotFound), commit, headErr, version, sum, versionErr, known,
+			versions, versionsErr, requires, requiresErr)
+	}
+	const want = "example.com/stub <nil> true abcdef <nil> v1.2.3 h1:abcdef <nil> h1:abcdef [v1.0.0 v1.2.3] <nil> map[example.com/dep:v1.0.0] <nil>"
+
+	run := func(name string, cfg PersistentCacheConfig, wantCounts lookupCounts) {
+		t.Helper()
+		rc, cleanup, counts := countingCache(cfg)
+		if got := lookup(rc); got != want {
+			t.Errorf("%s: got %q; want %q", name, got, want)
+		}
+		if err := cleanup(); err != nil {
+			t.Fatal(err)
+		}
+		if *counts != wantCounts {
+			t.Errorf("%s: got lookups %+v; want %+v", name, *counts, wantCounts)
+		}
+	}
+	all := lookupCounts{mod: 2, head: 1, version: 2, versions: 1, requires: 1}
+
+	// The first run looks everything up and saves it.
+	run("first run", PersistentCacheConfig{VolatileTTL: time.Nanosecond}, all)
+
+	// The second run uses cached results, including the failed lookup, which
+	// still matches goproxy.ErrNotFound. The head commit, the latest version,
+	// and the version list have expired.
+	run("second run", PersistentCacheConfig{}, lookupCounts{head: 1, version: 1, versions: 1})
+
+	// Results looked up with a different proxy aren't used.
+	t.Setenv("GOPROXY", "https://other.example.com")
+	run("other proxy", PersistentCacheConfig{}, all)
+
+	// Refresh ignores the cache.
+	run("refresh", PersistentCacheConfig{Refresh: true}, all)
+}
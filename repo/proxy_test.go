@@ -0,0 +1,57 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy/goproxytest"
+)
+
+func TestRemoteCacheGoProxy(t *testing.T) {
+	proxyURL := goproxytest.WriteFileProxy(t, map[string]string{
+		"example.com/foo/@v/list":       "v1.0.0\nv0.9.0\n",
+		"example.com/foo/@v/v1.0.0.mod": "module example.com/foo\n\nrequire example.com/bar v1.2.0\n",
+		"example.com/foo/@v/v1.0.0.zip": goproxytest.ModuleZip(t, "example.com/foo@v1.0.0", map[string]string{
+			"go.mod":     "module example.com/foo\n\nrequire example.com/bar v1.2.0\n",
+			"pkg/pkg.go": "package pkg\n",
+		}),
+	})
+	sumDB := goproxytest.ServeSumDB(t, map[string]string{
+		"example.com/foo@v1.0.0": "h1:foo=",
+	})
+	t.Setenv("GOPROXY", proxyURL)
+	t.Setenv("GOPRIVATE", "")
+	t.Setenv("GONOPROXY", "")
+	t.Setenv("GONOSUMDB", "")
+	t.Setenv("GOSUMDB", sumDB)
+
+	rc, cleanup := NewRemoteCache(nil)
+	defer cleanup()
+	if modPath, name, err := rc.Mod("example.com/foo/pkg"); err != nil || modPath != "example.com/foo" || name != "com_example_foo" {
+		t.Errorf("Mod: got %q, %q, %v; want %q, %q, nil", modPath, name, err, "example.com/foo", "com_example_foo")
+	}
+	if name, version, sum, err := rc.ModVersion("example.com/foo", "latest"); err != nil || name != "com_example_foo" || version != "v1.0.0" || sum != "h1:foo=" {
+		t.Errorf("ModVersion: got %q, %q, %q, %v", name, version, sum, err)
+	}
+	if versions, err := rc.ModVersions("example.com/foo"); err != nil || strings.Join(versions, " ") != "v0.9.0 v1.0.0" {
+		t.Errorf("ModVersions: got %q, %v; want [v0.9.0 v1.0.0], nil", versions, err)
+	}
+	if requires, err := rc.ModRequires("example.com/foo", "v1.0.0"); err != nil || len(requires) != 1 || requires["example.com/bar"] != "v1.2.0" {
+		t.Errorf("ModRequires: got %v, %v; want map[example.com/bar:v1.2.0], nil", requires, err)
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/bazelbuild/bazel-gazelle/internal/goproxy"
 	"github.com/bazelbuild/bazel-gazelle/label"
 	"github.com/bazelbuild/bazel-gazelle/pathtools"
 	"golang.org/x/mod/modfile"
@@ -64,11 +65,29 @@ type RemoteCache struct {
 	// This is used by ModVersion. It may be stubbed out for tests.
 	ModVersionInfo func(modPath, query string) (version, sum string, err error)
 
-	root, remote, head, mod, modVersion remoteCacheMap
+	// ModVersionsInfo returns the known versions of a module, sorted in
+	// semantic version order. This is used by ModVersions. It may be stubbed
+	// out for tests.
+	ModVersionsInfo func(modPath string) (versions []string, err error)
+
+	// ModRequiresInfo returns the requirements listed in the go.mod file of
+	// a module at a canonical version, as a map from module path to version.
+	// This is used by ModRequires. It may be stubbed out for tests.
+	ModRequiresInfo func(modPath, version string) (requires map[string]string, err error)
+
+	root, remote, head, mod, modVersion, modVersions, modRequires remoteCacheMap
 
 	tmpOnce sync.Once
 	tmpDir  string
 	tmpErr  error
+
+	proxyOnce sync.Once
+	proxy     *goproxy.Client
+	proxyErr  error
+
+	// persist and persistEnvKey are set by UsePersistentCache.
+	persist       *PersistentCacheConfig
+	persistEnvKey string
 }
 
 // remoteCacheMap is a thread-safe, idempotent cache. It is used to store
@@ -84,6 +103,11 @@ type remoteCacheEntry struct {
 	value interface{}
 	err   error
 
+	// loaded is true for entries added by ensure, i.e., those whose values
+	// were looked up during this run. Only these are saved in the
+	// persistent cache.
+	loaded bool
+
 	// ready is nil for entries that were added when the cache was initialized.
 	// It is non-nil for other entries. It is closed when an entry is ready,
 	// i.e., the operation loading the entry completed.
@@ -139,6 +163,8 @@ func NewRemoteCache(knownRepos []Repo) (r *RemoteCache, cleanup func() error) {
 		head:                  remoteCacheMap{cache: make(map[string]*remoteCacheEntry)},
 		mod:                   remoteCacheMap{cache: make(map[string]*remoteCacheEntry)},
 		modVersion:            remoteCacheMap{cache: make(map[string]*remoteCacheEntry)},
+		modVersions:           remoteCacheMap{cache: make(map[string]*remoteCacheEntry)},
+		modRequires:           remoteCacheMap{cache: make(map[string]*remoteCacheEntry)},
 	}
 	r.ModInfo = func(importPath string) (string, error) {
 		return defaultModInfo(r, importPath)
@@ -146,6 +172,12 @@ func NewRemoteCache(knownRepos []Repo) (r *RemoteCache, cleanup func() error) {
 	r.ModVersionInfo = func(modPath, query string) (string, string, error) {
 		return defaultModVersionInfo(r, modPath, query)
 	}
+	r.ModVersionsInfo = func(modPath string) ([]string, error) {
+		return defaultModVersionsInfo(r, modPath)
+	}
+	r.ModRequiresInfo = func(modPath, version string) (map[string]string, error) {
+		return defaultModRequiresInfo(r, modPath, version)
+	}
 	for _, repo := range knownRepos {
 		r.root.cache[repo.GoPrefix] = &remoteCacheEntry{
 			value: rootValue{
@@ -207,10 +239,16 @@ func NewRemoteCache(knownRepos []Repo) (r *RemoteCache, cleanup func() error) {
 }
 
 func (r *RemoteCache) cleanup() error {
-	if r.tmpDir == "" {
-		return nil
+	var errs []error
+	if r.persist != nil {
+		if err := r.savePersistentCache(); err != nil {
+			errs = append(errs, fmt.Errorf("saving remote cache: %w", err))
+		}
 	}
-	return os.RemoveAll(r.tmpDir)
+	if r.tmpDir != "" {
+		errs = append(errs, os.RemoveAll(r.tmpDir))
+	}
+	return errors.Join(errs...)
 }
 
 // PopulateFromGoMod reads a go.mod file and adds entries to the r.root
@@ -235,11 +273,9 @@ func (r *RemoteCache) PopulateFromGoMod(goModPath string) (err error) {
 		return err
 	}
 	for _, req := range f.Require {
-		r.root.ensure(req.Mod.Path, func() (any, error) {
-			return rootValue{
-				root: req.Mod.Path,
-				name: label.ImportPathToBazelRepoName(req.Mod.Path),
-			}, nil
+		r.root.setDefault(req.Mod.Path, rootValue{
+			root: req.Mod.Path,
+			name: label.ImportPathToBazelRepoName(req.Mod.Path),
 		})
 	}
 	return nil
@@ -424,9 +460,10 @@ func defaultHeadCmd(remote, vcs string) (string, error) {
 // inaccurate results if importPath is in an undeclared nested module. Run
 // "gazelle update-repos -from_file=go.mod" first for best results.
 //
-// If no known repository could provide importPath, Mod will run "go list" to
-// find the module. The special patterns that Root uses are ignored. Results are
-// cached. Use GOPROXY for faster results.
+// If no known repository could provide importPath, Mod will ask the module
+// proxies listed in GOPROXY for the module. If GOPROXY says the module should
+// be fetched directly, Mod will run "go list" instead. The special patterns
+// that Root uses are ignored. Results are cached.
 func (r *RemoteCache) Mod(importPath string) (modPath, name string, err error) {
 	// Check if any of the known repositories is a prefix.
 	prefix := importPath
@@ -450,7 +487,7 @@ func (r *RemoteCache) Mod(importPath string) (modPath, name string, err error) {
 		}
 	}
 
-	// Ask "go list".
+	// Ask the module proxy.
 	v, err := r.mod.ensure(importPath, func() (interface{}, error) {
 		modPath, err := r.ModInfo(importPath)
 		if err != nil {
@@ -469,6 +506,21 @@ func (r *RemoteCache) Mod(importPath string) (modPath, name string, err error) {
 }
 
 func defaultModInfo(rc *RemoteCache, importPath string) (modPath string, err error) {
+	p, err := rc.goProxy()
+	if err != nil {
+		return "", err
+	}
+	modPath, err = p.ModulePath(importPath)
+	if errors.Is(err, goproxy.ErrUseDirect) {
+		return goModInfo(rc, importPath)
+	} else if err != nil {
+		return "", fmt.Errorf("finding module path for import %s: %w", importPath, err)
+	}
+	return modPath, nil
+}
+
+// goModInfo finds the module that provides a package using the go command.
+func goModInfo(rc *RemoteCache, importPath string) (modPath string, err error) {
 	rc.initTmp()
 	if rc.tmpErr != nil {
 		return "", rc.tmpErr
@@ -506,7 +558,7 @@ func defaultModInfo(rc *RemoteCache, importPath string) (modPath string, err err
 // the repository rule providing the module (if any), the true version,
 // and the sum.
 func (r *RemoteCache) ModVersion(modPath, query string) (name, version, sum string, err error) {
-	// Ask "go list".
+	// Ask the module proxy.
 	arg := modPath + "@" + query
 	v, err := r.modVersion.ensure(arg, func() (interface{}, error) {
 		version, sum, err := r.ModVersionInfo(modPath, query)
@@ -537,6 +589,29 @@ func (r *RemoteCache) ModVersion(modPath, query string) (name, version, sum stri
 }
 
 func defaultModVersionInfo(rc *RemoteCache, modPath, query string) (version, sum string, err error) {
+	defer func() {
+		if errors.Is(err, goproxy.ErrUseDirect) {
+			version, sum, err = goModVersionInfo(rc, modPath, query)
+		} else if err != nil {
+			err = fmt.Errorf("finding module version and sum for %s@%s: %w", modPath, query, err)
+		}
+	}()
+	p, err := rc.goProxy()
+	if err != nil {
+		return "", "", err
+	}
+	if version, err = p.Info(modPath, query); err != nil {
+		return "", "", err
+	}
+	if sum, err = p.Sum(modPath, version); err != nil {
+		return "", "", err
+	}
+	return version, sum, nil
+}
+
+// goModVersionInfo finds the version and sum of a module using the go
+// command.
+func goModVersionInfo(rc *RemoteCache, modPath, query string) (version, sum string, err error) {
 	rc.initTmp()
 	if rc.tmpErr != nil {
 		return "", "", rc.tmpErr
@@ -563,6 +638,137 @@ func defaultModVersionInfo(rc *RemoteCache, modPath, query string) (version, sum
 	return result.Version, result.Sum, nil
 }
 
+// ModVersions returns the versions of a module that are available from the
+// module proxy, sorted in semantic version order. Pseudo-versions are not
+// included.
+func (r *RemoteCache) ModVersions(modPath string) ([]string, error) {
+	v, err := r.modVersions.ensure(modPath, func() (interface{}, error) {
+		return r.ModVersionsInfo(modPath)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]string), nil
+}
+
+func defaultModVersionsInfo(rc *RemoteCache, modPath string) (versions []string, err error) {
+	defer func() {
+		if errors.Is(err, goproxy.ErrUseDirect) {
+			versions, err = goModVersionsInfo(rc, modPath)
+		} else if err != nil {
+			err = fmt.Errorf("listing versions of %s: %w", modPath, err)
+		}
+	}()
+	p, err := rc.goProxy()
+	if err != nil {
+		return nil, err
+	}
+	return p.Versions(modPath)
+}
+
+// goModVersionsInfo lists the versions of a module using the go command.
+func goModVersionsInfo(rc *RemoteCache, modPath string) (versions []string, err error) {
+	rc.initTmp()
+	if rc.tmpErr != nil {
+		return nil, rc.tmpErr
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("listing versions of %s: %v", modPath, cleanCmdError(err))
+		}
+	}()
+
+	goTool := findGoTool()
+	cmd := exec.Command(goTool, "list", "-m", "-versions", "-json", "--", modPath)
+	cmd.Dir = rc.tmpDir
+	cmd.Env = append(os.Environ(), "GO111MODULE=on")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct{ Versions []string }
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("invalid output from 'go list': %v", err)
+	}
+	return result.Versions, nil
+}
+
+// ModRequires returns the requirements in the go.mod file of a module at a
+// canonical version, as a map from module path to the required version.
+func (r *RemoteCache) ModRequires(modPath, version string) (map[string]string, error) {
+	v, err := r.modRequires.ensure(modPath+"@"+version, func() (interface{}, error) {
+		return r.ModRequiresInfo(modPath, version)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]string), nil
+}
+
+func defaultModRequiresInfo(rc *RemoteCache, modPath, version string) (requires map[string]string, err error) {
+	var data []byte
+	defer func() {
+		if errors.Is(err, goproxy.ErrUseDirect) {
+			requires, err = goModRequiresInfo(rc, modPath, version)
+		} else if err != nil {
+			err = fmt.Errorf("reading go.mod of %s@%s: %w", modPath, version, err)
+		}
+	}()
+	p, err := rc.goProxy()
+	if err != nil {
+		return nil, err
+	}
+	if data, err = p.Mod(modPath, version); err != nil {
+		return nil, err
+	}
+	return parseRequires(modPath+"@"+version+"/go.mod", data)
+}
+
+// goModRequiresInfo reads the go.mod file of a module using the go command.
+func goModRequiresInfo(rc *RemoteCache, modPath, version string) (requires map[string]string, err error) {
+	rc.initTmp()
+	if rc.tmpErr != nil {
+		return nil, rc.tmpErr
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("reading go.mod of %s@%s: %v", modPath, version, cleanCmdError(err))
+		}
+	}()
+
+	goTool := findGoTool()
+	cmd := exec.Command(goTool, "mod", "download", "-json", "--", modPath+"@"+version)
+	cmd.Dir = rc.tmpDir
+	cmd.Env = append(os.Environ(), "GO111MODULE=on")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct{ GoMod string }
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("invalid output from 'go mod download': %v", err)
+	}
+	data, err := os.ReadFile(result.GoMod)
+	if err != nil {
+		return nil, err
+	}
+	return parseRequires(result.GoMod, data)
+}
+
+func parseRequires(name string, data []byte) (map[string]string, error) {
+	f, err := modfile.ParseLax(name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	requires := make(map[string]string, len(f.Require))
+	for _, r := range f.Require {
+		requires[r.Mod.Path] = r.Mod.Version
+	}
+	return requires, nil
+}
+
 // get retrieves a value associated with the given key from the cache. ok will
 // be true if the key exists in the cache, even if it's in the process of
 // being fetched.
@@ -587,7 +793,7 @@ func (m *remoteCacheMap) ensure(key string, load func() (interface{}, error)) (i
 	m.mu.Lock()
 	e, ok := m.cache[key]
 	if !ok {
-		e = &remoteCacheEntry{ready: make(chan struct{})}
+		e = &remoteCacheEntry{ready: make(chan struct{}), loaded: true}
 		m.cache[key] = e
 		m.mu.Unlock()
 		e.value, e.err = load()
@@ -601,6 +807,16 @@ func (m *remoteCacheMap) ensure(key string, load func() (interface{}, error)) (i
 	return e.value, e.err
 }
 
+// setDefault associates a value with the given key if the key does not
+// exist in the cache.
+func (m *remoteCacheMap) setDefault(key string, value interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.cache[key]; !ok {
+		m.cache[key] = &remoteCacheEntry{value: value}
+	}
+}
+
 func (rc *RemoteCache) initTmp() {
 	rc.tmpOnce.Do(func() {
 		rc.tmpDir, rc.tmpErr = os.MkdirTemp("", "gazelle-remotecache-")
@@ -611,6 +827,14 @@ func (rc *RemoteCache) initTmp() {
 	})
 }
 
+// goProxy returns the module proxy client configured from the environment.
+func (rc *RemoteCache) goProxy() (*goproxy.Client, error) {
+	rc.proxyOnce.Do(func() {
+		rc.proxy, rc.proxyErr = goproxy.NewFromEnv()
+	})
+	return rc.proxy, rc.proxyErr
+}
+
 var semverRex = regexp.MustCompile(`^.*?(/v\d+)(?:/.*)?$`)
 
 // pathWithoutSemver removes a semantic version suffix from path.
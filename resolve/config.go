@@ -166,7 +166,7 @@ func (*Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 				log.Printf("gazelle:resolve %s: %v", d.Value, err)
 				continue
 			}
-			dep = dep.Abs("", rel)
+			dep = apparentLabel(c, dep).Abs("", rel)
 			if newOverrides == nil {
 				newOverrides = make(map[overrideKey]label.Label, len(f.Directives))
 			}
@@ -205,10 +205,25 @@ func (*Configurer) Configure(c *config.Config, rel string, f *rule.File) {
 				log.Printf("gazelle:resolve_regexp %s: %v", d.Value, err)
 				continue
 			}
-			o.dep = o.dep.Abs("", rel)
+			o.dep = apparentLabel(c, o.dep).Abs("", rel)
 			regexpOverrides = append(regexpOverrides, o)
 		}
 	}
 
 	c.Exts[resolveName] = newResolveConfig(rc, newOverrides, regexpOverrides)
 }
+
+// apparentLabel translates a label with a canonical repository name, for
+// example, one copied from .bzl code, to the apparent name used in build
+// files of the main repository. Other labels, and labels in repositories
+// that aren't visible from the main repository, are returned unchanged.
+func apparentLabel(c *config.Config, l label.Label) label.Label {
+	if !l.Canonical {
+		return l
+	}
+	if apparent, ok := c.RepoMapping.Apparent(l); ok {
+		return apparent
+	}
+	log.Printf("repository of %s is not visible from the main repository", l)
+	return l
+}
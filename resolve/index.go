@@ -278,6 +278,13 @@ type FindResult struct {
 	Embeds []label.Label
 }
 
+// ImportedAs returns the import specs by which the rule with the given label
+// may be imported, including those of rules it embeds. It returns nil for
+// rules that weren't indexed. Finish must be called first.
+func (ix *RuleIndex) ImportedAs(l label.Label) []ImportSpec {
+	return ix.imports[l]
+}
+
 // FindRulesByImport attempts to resolve an import string to a rule record.
 // imp is the import to resolve (which includes the target language). lang is
 // the language of the rule with the dependency (for example, in
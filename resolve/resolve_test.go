@@ -160,3 +160,28 @@ func getTestLabel(t *testing.T, str string) label.Label {
 	}
 	return l
 }
+
+func TestFindRuleWithOverride_CanonicalLabel(t *testing.T) {
+	cfg := &config.Config{
+		Exts:        map[string]interface{}{},
+		RepoMapping: label.NewRepoMapping(map[string]string{"com_example": "gazelle++go_deps+com_example"}),
+	}
+	configurer := &Configurer{}
+	configurer.RegisterFlags(nil, "", cfg)
+	configurer.Configure(cfg, "", &rule.File{Directives: []rule.Directive{
+		{Key: "resolve", Value: "go example.com/foo @@gazelle++go_deps+com_example//foo"},
+		{Key: "resolve", Value: "go example.com/bar @@//bar"},
+	}})
+
+	for imp, want := range map[string]string{
+		"example.com/foo": "@com_example//foo",
+		"example.com/bar": "//bar",
+	} {
+		got, found := FindRuleWithOverride(cfg, ImportSpec{Lang: "go", Imp: imp}, "go")
+		if !found {
+			t.Errorf("%s: override not found", imp)
+		} else if got.String() != want {
+			t.Errorf("%s: got %s; want %s", imp, got, want)
+		}
+	}
+}
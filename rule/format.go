@@ -0,0 +1,191 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rule
+
+import (
+	"bytes"
+
+	bzl "github.com/bazelbuild/buildtools/build"
+)
+
+// FormatMinimal is like Format, but it only reformats statements that were
+// inserted or changed since the file was loaded or saved. The bytes of other
+// statements, including comments and whitespace between statements, are
+// copied from Content unchanged. Inserted statements are separated from their
+// neighbors the way Format would separate them. Deleted statements are
+// removed along with the blank lines after them.
+//
+// FormatMinimal falls back to Format when the file wasn't loaded from
+// Content, when statements were reordered, when a statement shares a line
+// with another statement, or when editing a macro file.
+//
+// This method calls Sync internally.
+func (f *File) FormatMinimal() []byte {
+	f.Sync()
+	if out, ok := f.formatMinimal(); ok {
+		return out
+	}
+	return bzl.Format(f.File)
+}
+
+func (f *File) formatMinimal() ([]byte, bool) {
+	if f.function != nil || f.Content == nil || !bytes.Equal(f.Content, f.loadedContent) {
+		return nil, false
+	}
+
+	// Parse the original content again. The statements in f.File have been
+	// modified in place, so this is the only way to tell whether they changed.
+	orig, err := parseType(f.File.Type, f.Path, f.Content)
+	if err != nil || len(orig.Stmt) != len(f.loadedStmt) {
+		return nil, false
+	}
+	type span struct{ start, end int }
+	spans := make([]span, len(orig.Stmt))
+	prevEnd := 0
+	for i, s := range orig.Stmt {
+		start, end, ok := stmtSpan(f.Content, s)
+		if !ok || start < prevEnd {
+			return nil, false
+		}
+		spans[i] = span{start, end}
+		prevEnd = end
+	}
+	origIndex := make(map[bzl.Expr]int, len(f.loadedStmt))
+	for i, s := range f.loadedStmt {
+		origIndex[s] = i
+	}
+
+	out := &bytes.Buffer{}
+	next := 0         // index of the next original statement to copy or skip
+	pos := 0          // end of the last original statement copied or skipped
+	inserted := false // whether the last statement written was inserted
+	var prev bzl.Expr // the last statement written
+	for _, s := range f.File.Stmt {
+		i, ok := origIndex[s]
+		if !ok {
+			// Inserted statement. End the previous line if the original content
+			// didn't end with a newline, then separate the statement with a blank
+			// line unless Format would keep them together.
+			if out.Len() > 0 {
+				if !bytes.HasSuffix(out.Bytes(), []byte("\n")) {
+					out.WriteByte('\n')
+				}
+				if !compactStmts(f.File.Type, prev, s) {
+					out.WriteByte('\n')
+				}
+			}
+			out.Write(formatStmt(f.File.Type, s))
+			inserted = true
+			prev = s
+			continue
+		}
+		if i < next {
+			return nil, false
+		}
+		// Statements between next and i were deleted. Keep the blank lines
+		// before the first of them, and skip the rest.
+		gap := f.Content[pos:spans[next].start]
+		if out.Len() > 0 || next == 0 {
+			if inserted && len(gap) == 0 && !compactStmts(f.File.Type, prev, s) {
+				gap = []byte("\n")
+			}
+			out.Write(gap)
+		}
+		text := f.Content[spans[i].start:spans[i].end]
+		if formatted := formatStmt(f.File.Type, s); !bytes.Equal(formatted, formatStmt(f.File.Type, orig.Stmt[i])) {
+			text = formatted
+		}
+		out.Write(text)
+		inserted = false
+		prev = s
+		next, pos = i+1, spans[i].end
+	}
+	if next == len(spans) {
+		out.Write(f.Content[pos:])
+	} else if rest := bytes.TrimLeft(f.Content[spans[len(spans)-1].end:], "\n"); len(rest) > 0 {
+		// The last statements were deleted. Keep whatever follows them, like
+		// comments at the end of the file.
+		if out.Len() > 0 {
+			out.WriteByte('\n')
+		}
+		out.Write(rest)
+	}
+	return out.Bytes(), true
+}
+
+// formatStmt formats a single top-level statement with its comments. The
+// result ends with a newline.
+func formatStmt(typ bzl.FileType, s bzl.Expr) []byte {
+	return bzl.Format(&bzl.File{Type: typ, Stmt: []bzl.Expr{s}})
+}
+
+// compactStmts returns whether Format would print s2 directly after s1,
+// without a blank line between them.
+func compactStmts(typ bzl.FileType, s1, s2 bzl.Expr) bool {
+	both := bzl.Format(&bzl.File{Type: typ, Stmt: []bzl.Expr{s1, s2}})
+	return len(both) == len(formatStmt(typ, s1))+len(formatStmt(typ, s2))
+}
+
+// stmtSpan returns the range of bytes in content taken by the top-level
+// statement s, including comments before and after it. The range starts at
+// the beginning of a line and ends after a newline (or at the end of the
+// content). ok is false if s shares a line with something else.
+func stmtSpan(content []byte, s bzl.Expr) (start, end int, ok bool) {
+	startPos, endPos := s.Span()
+	start, end = startPos.Byte, endPos.Byte
+	comments := s.Comment()
+	for _, cs := range [][]bzl.Comment{comments.Before, comments.Suffix, comments.After} {
+		for _, c := range cs {
+			if c.Start.Byte < start {
+				start = c.Start.Byte
+			}
+			if e := c.Start.Byte + len(c.Token); e > end {
+				end = e
+			}
+		}
+	}
+	if start < 0 || end > len(content) || start > end {
+		return 0, 0, false
+	}
+
+	lineStart := bytes.LastIndexByte(content[:start], '\n') + 1
+	if len(bytes.TrimSpace(content[lineStart:start])) > 0 {
+		return 0, 0, false
+	}
+	lineEnd := len(content)
+	if i := bytes.IndexByte(content[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if len(bytes.TrimSpace(content[end:lineEnd])) > 0 {
+		return 0, 0, false
+	}
+	return lineStart, lineEnd, true
+}
+
+func parseType(typ bzl.FileType, path string, data []byte) (*bzl.File, error) {
+	switch typ {
+	case bzl.TypeBuild:
+		return bzl.ParseBuild(path, data)
+	case bzl.TypeWorkspace:
+		return bzl.ParseWorkspace(path, data)
+	case bzl.TypeModule:
+		return bzl.ParseModule(path, data)
+	case bzl.TypeBzl:
+		return bzl.ParseBzl(path, data)
+	default:
+		return bzl.ParseDefault(path, data)
+	}
+}
@@ -0,0 +1,129 @@
+/* Copyright 2026 The Bazel Authors. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+   http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rule
+
+import (
+	"testing"
+)
+
+func TestFormatMinimal(t *testing.T) {
+	const old = `# Hand-written header.
+load("@rules_go//go:def.bzl", "go_library")
+load('//build:macros.bzl', 'my_macro')
+
+my_macro(name='hand',   srcs = [ 'a.txt' ])  # odd spacing
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+)
+
+go_library(name="old", srcs=["old.go"])
+
+
+# Trailing comment.
+`
+	for _, tc := range []struct {
+		desc, want string
+		edit       func(f *File)
+	}{
+		{
+			desc: "unchanged",
+			edit: func(f *File) {
+				// Setting an attribute to the same value doesn't change the rule.
+				f.Rules[1].SetAttr("srcs", []string{"lib.go"})
+			},
+			want: old,
+		},
+		{
+			desc: "modify",
+			edit: func(f *File) {
+				f.Rules[1].SetAttr("deps", []string{"//dep"})
+			},
+			want: `# Hand-written header.
+load("@rules_go//go:def.bzl", "go_library")
+load('//build:macros.bzl', 'my_macro')
+
+my_macro(name='hand',   srcs = [ 'a.txt' ])  # odd spacing
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+    deps = ["//dep"],
+)
+
+go_library(name="old", srcs=["old.go"])
+
+
+# Trailing comment.
+`,
+		},
+		{
+			desc: "insert_and_delete",
+			edit: func(f *File) {
+				f.Rules[2].Delete()
+				r := NewRule("go_test", "lib_test")
+				r.SetAttr("srcs", []string{"lib_test.go"})
+				r.Insert(f)
+				f.Loads[0].Add("go_test")
+			},
+			want: `# Hand-written header.
+load("@rules_go//go:def.bzl", "go_library", "go_test")
+load('//build:macros.bzl', 'my_macro')
+
+my_macro(name='hand',   srcs = [ 'a.txt' ])  # odd spacing
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+)
+
+# Trailing comment.
+
+go_test(
+    name = "lib_test",
+    srcs = ["lib_test.go"],
+)
+`,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			f, err := LoadData("BUILD.bazel", "", []byte(old))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.edit(f)
+			if got := string(f.FormatMinimal()); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatMinimalInsertAfterMissingNewline(t *testing.T) {
+	f, err := LoadData("BUILD.bazel", "", []byte(`go_library(name = "lib")`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewRule("go_test", "lib_test").Insert(f)
+	want := `go_library(name = "lib")
+
+go_test(name = "lib_test")
+`
+	if got := string(f.FormatMinimal()); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -77,6 +77,12 @@ type File struct {
 	// is modified outside of Rule methods, Content must be manually updated in
 	// order to keep it in sync.
 	Content []byte
+
+	// loadedContent and loadedStmt are the content and top-level statements of
+	// the file when it was loaded or last saved. FormatMinimal uses them to
+	// find statements that changed.
+	loadedContent []byte
+	loadedStmt    []bzl.Expr
 }
 
 // EmptyFile creates a File wrapped around an empty syntax tree.
@@ -148,7 +154,7 @@ func LoadData(path, pkg string, data []byte) (*File, error) {
 	if err := checkFile(f); err != nil {
 		return nil, err
 	}
-	f.Content = data
+	f.setContent(data)
 	return f, nil
 }
 
@@ -163,7 +169,22 @@ func LoadWorkspaceData(path, pkg string, data []byte) (*File, error) {
 	if err := checkFile(f); err != nil {
 		return nil, err
 	}
-	f.Content = data
+	f.setContent(data)
+	return f, nil
+}
+
+// LoadModuleData is similar to LoadData but parses the data as a
+// MODULE.bazel file.
+func LoadModuleData(path, pkg string, data []byte) (*File, error) {
+	ast, err := bzl.ParseModule(path, data)
+	if err != nil {
+		return nil, err
+	}
+	f := ScanAST(pkg, ast)
+	if err := checkFile(f); err != nil {
+		return nil, err
+	}
+	f.setContent(data)
 	return f, nil
 }
 
@@ -181,7 +202,7 @@ func LoadMacroData(path, pkg, defName string, data []byte) (*File, error) {
 	if err := checkFile(f); err != nil {
 		return nil, err
 	}
-	f.Content = data
+	f.setContent(data)
 	return f, nil
 }
 
@@ -423,10 +444,18 @@ func (f *File) SortMacro() {
 // Save writes the build file to disk. This method calls Sync internally.
 func (f *File) Save(path string) error {
 	f.Sync()
-	f.Content = bzl.Format(f.File)
+	f.setContent(bzl.Format(f.File))
 	return os.WriteFile(path, f.Content, 0o666)
 }
 
+// setContent sets Content to data, which must be the content of the file's
+// current syntax tree.
+func (f *File) setContent(data []byte) {
+	f.Content = data
+	f.loadedContent = data
+	f.loadedStmt = append([]bzl.Expr(nil), f.File.Stmt...)
+}
+
 // HasDefaultVisibility returns whether the File contains a "package" rule with
 // a "default_visibility" attribute. Rules generated by Gazelle should not
 // have their own visibility attributes if this is the case.
@@ -730,6 +759,12 @@ type Rule struct {
 	attrs       map[string]attrValue
 	private     map[string]interface{}
 	sortedAttrs []string
+
+	// normalizeLabel and labelAttrs are set by NormalizeLabels. When set,
+	// strings in the label-valued attributes named by labelAttrs are sorted
+	// by their normalized form.
+	normalizeLabel func(string) string
+	labelAttrs     map[string]bool
 }
 
 type attrValue struct {
@@ -1084,7 +1119,12 @@ func (r *Rule) sync() {
 	for _, k := range r.sortedAttrs {
 		attr, ok := r.attrs[k]
 		_, isUnsorted := attr.val.(UnsortedStrings)
-		if ok && !isUnsorted {
+		if !ok || isUnsorted {
+			continue
+		}
+		if r.normalizeLabel != nil && r.labelAttrs[k] {
+			bzl.Walk(attr.expr.RHS, sortNormalizedLabels(r.normalizeLabel))
+		} else {
 			bzl.Walk(attr.expr.RHS, sortExprLabels)
 		}
 	}
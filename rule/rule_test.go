@@ -22,6 +22,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/bazelbuild/bazel-gazelle/label"
 	bzl "github.com/bazelbuild/buildtools/build"
 )
 
@@ -753,3 +754,88 @@ a_rule(
 		t.Errorf("Unexpected r.SortedAttrs(): %v", r.SortedAttrs())
 	}
 }
+
+func TestNormalizeLabels(t *testing.T) {
+	f, err := LoadData("BUILD.bazel", "pkg", []byte(`
+go_library(
+    name = "lib",
+    srcs = [":lib.go"],
+    embed = "@repo//pkg:embedded",
+    deps = [
+        "//foo:foo",
+        "@repo//foo",
+        "//pkg:a",
+        ":a",
+        "@other//foo:foo",
+    ] + select({
+        "//conditions:default": ["//bar:bar"],
+    }),
+)
+
+go_library(
+    name = "kept",
+    deps = ["//pkg:a"],  # keep
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := KindInfo{
+		ResolveAttrs:    map[string]bool{"deps": true},
+		SubstituteAttrs: map[string]bool{"embed": true},
+	}
+	for _, r := range f.Rules {
+		r.NormalizeLabels(info, "repo", "pkg", label.ShortStyle)
+	}
+	f.Sync()
+
+	got := strings.TrimSpace(string(bzl.FormatWithoutRewriting(f.File)))
+	want := strings.TrimSpace(`
+go_library(
+    name = "lib",
+    srcs = [":lib.go"],
+    embed = ":embedded",
+    deps = [
+        "//foo",
+        ":a",
+        "@other//foo:foo",
+    ] + select({
+        "//conditions:default": ["//bar"],
+    }),
+)
+
+go_library(
+    name = "kept",
+    deps = ["//pkg:a"],  # keep
+)
+`)
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNormalizeLabelsSortsByNormalizedForm(t *testing.T) {
+	r := NewRule("go_library", "lib")
+	r.NormalizeLabels(KindInfo{ResolveAttrs: map[string]bool{"deps": true}}, "repo", "pkg", label.ShortStyle)
+	// Labels set after normalization, for example by a resolver, are sorted
+	// as if they were written in the configured style: "//pkg:b" as ":b".
+	r.SetAttr("deps", []string{":c", "//other:a", "//pkg:b"})
+	f := EmptyFile("BUILD.bazel", "pkg")
+	r.Insert(f)
+	f.Sync()
+
+	got := strings.TrimSpace(string(bzl.FormatWithoutRewriting(f.File)))
+	want := strings.TrimSpace(`
+go_library(
+    name = "lib",
+    deps = [
+        "//pkg:b",
+        ":c",
+        "//other:a",
+    ],
+)
+`)
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
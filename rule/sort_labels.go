@@ -19,13 +19,133 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/bazelbuild/bazel-gazelle/label"
 	bzl "github.com/bazelbuild/buildtools/build"
 )
 
+// NormalizeLabels writes labels in attributes of r in the given style, so that
+// labels written differently by different tools compare equal when rules are
+// merged and sort the same way. Attributes in info.ResolveAttrs and
+// info.SubstituteAttrs are assumed to hold labels; other attributes aren't
+// changed. pkg is the package containing r, and repo is the name of the main
+// repository. Labels that become duplicates of earlier labels in the same
+// list are removed unless they have comments. Rules and attributes marked
+// with "# keep" comments aren't changed. See label.Normalize.
+//
+// Labels in these attributes are sorted by their normalized form from then
+// on, including labels set or merged into the rule later, so the order
+// doesn't depend on how they were written.
+func (r *Rule) NormalizeLabels(info KindInfo, repo, pkg string, style label.Style) {
+	if style == label.NoStyle || r.ShouldKeep() {
+		return
+	}
+	normalize := func(s string) string {
+		return label.Normalize(s, repo, pkg, style)
+	}
+	r.normalizeLabel = normalize
+	r.labelAttrs = make(map[string]bool)
+	for _, attrs := range []map[string]bool{info.ResolveAttrs, info.SubstituteAttrs} {
+		for key := range attrs {
+			r.labelAttrs[key] = true
+		}
+	}
+	for key, attr := range r.attrs {
+		if (!info.ResolveAttrs[key] && !info.SubstituteAttrs[key]) || ShouldKeep(attr.expr) {
+			continue
+		}
+		switch val := attr.val.(type) {
+		case SortedStrings:
+			r.SetAttr(key, SortedStrings(normalizeStrings(val, normalize)))
+			continue
+		case UnsortedStrings:
+			r.SetAttr(key, UnsortedStrings(normalizeStrings(val, normalize)))
+			continue
+		}
+
+		changed := false
+		rhs := attr.expr.RHS
+		if str, ok := rhs.(*bzl.StringExpr); ok {
+			if v := normalize(str.Value); v != str.Value {
+				str.Value = v
+				changed = true
+			}
+		}
+		bzl.Walk(rhs, func(e bzl.Expr, _ []bzl.Expr) {
+			if list, ok := e.(*bzl.ListExpr); ok && normalizeListLabels(list, normalize) {
+				changed = true
+			}
+		})
+		if changed {
+			r.SetAttr(key, rhs)
+		}
+	}
+}
+
+// normalizeListLabels normalizes strings in list and removes duplicates
+// without comments. It returns whether list was changed. This function is
+// intended to be called while walking an expression with bzl.Walk.
+func normalizeListLabels(list *bzl.ListExpr, normalize func(string) string) bool {
+	changed := false
+	seen := make(map[string]bool)
+	elems := list.List[:0]
+	for _, elem := range list.List {
+		str, ok := elem.(*bzl.StringExpr)
+		if !ok {
+			elems = append(elems, elem)
+			continue
+		}
+		if v := normalize(str.Value); v != str.Value {
+			str.Value = v
+			changed = true
+		}
+		if seen[str.Value] && !hasComments(str) {
+			changed = true
+			continue
+		}
+		seen[str.Value] = true
+		elems = append(elems, elem)
+	}
+	list.List = elems
+	return changed
+}
+
+func normalizeStrings(strs []string, normalize func(string) string) []string {
+	seen := make(map[string]bool)
+	normalized := make([]string, 0, len(strs))
+	for _, s := range strs {
+		s = normalize(s)
+		if !seen[s] {
+			seen[s] = true
+			normalized = append(normalized, s)
+		}
+	}
+	return normalized
+}
+
+func hasComments(e bzl.Expr) bool {
+	c := e.Comment()
+	return len(c.Before) > 0 || len(c.Suffix) > 0 || len(c.After) > 0
+}
+
 // sortExprLabels sorts lists of strings using the same order as buildifier.
 // Buildifier also sorts string lists, but not those involved with "select"
 // expressions. This function is intended to be used with bzl.Walk.
 func sortExprLabels(e bzl.Expr, _ []bzl.Expr) {
+	sortListLabels(e, nil)
+}
+
+// sortNormalizedLabels returns a function like sortExprLabels that compares
+// strings by their values after normalize is applied. Rules use it for
+// label-valued attributes when # gazelle:label_style is set.
+func sortNormalizedLabels(normalize func(string) string) func(bzl.Expr, []bzl.Expr) {
+	return func(e bzl.Expr, _ []bzl.Expr) {
+		sortListLabels(e, normalize)
+	}
+}
+
+// sortListLabels sorts e if it's a list of strings. If normalize is not nil,
+// strings are compared by their normalized values.
+func sortListLabels(e bzl.Expr, normalize func(string) string) {
 	list, ok := e.(*bzl.ListExpr)
 	if !ok || len(list.List) == 0 {
 		return
@@ -37,7 +157,7 @@ func sortExprLabels(e bzl.Expr, _ []bzl.Expr) {
 		if !ok {
 			return // don't sort lists unless all elements are strings
 		}
-		keys[i] = makeSortKey(i, s)
+		keys[i] = makeSortKey(i, s, normalize)
 	}
 
 	before := keys[0].x.Comment().Before
@@ -66,23 +186,27 @@ type stringSortKey struct {
 	x        bzl.Expr
 }
 
-func makeSortKey(index int, x *bzl.StringExpr) stringSortKey {
+func makeSortKey(index int, x *bzl.StringExpr, normalize func(string) string) stringSortKey {
+	value := x.Value
+	if normalize != nil {
+		value = normalize(value)
+	}
 	key := stringSortKey{
-		value:    x.Value,
+		value:    value,
 		original: index,
 		x:        x,
 	}
 
 	switch {
-	case strings.HasPrefix(x.Value, ":"):
+	case strings.HasPrefix(value, ":"):
 		key.phase = 1
-	case strings.HasPrefix(x.Value, "//"):
+	case strings.HasPrefix(value, "//"):
 		key.phase = 2
-	case strings.HasPrefix(x.Value, "@"):
+	case strings.HasPrefix(value, "@"):
 		key.phase = 3
 	}
 
-	key.split = strings.Split(strings.Replace(x.Value, ":", ".", -1), ".")
+	key.split = strings.Split(strings.Replace(value, ":", ".", -1), ".")
 	return key
 }
 
@@ -54,3 +54,40 @@ type KindInfo struct {
 	// dependency resolution. See rule.Merge.
 	ResolveAttrs map[string]bool
 }
+
+// Union returns a KindInfo that combines info and other. Attribute sets and
+// lists contain the attributes from both, and MatchAny is true if it's true
+// in either. Neither info nor other is modified.
+func (info KindInfo) Union(other KindInfo) KindInfo {
+	unionSet := func(a, b map[string]bool) map[string]bool {
+		if len(a) == 0 && len(b) == 0 {
+			return a
+		}
+		u := make(map[string]bool, len(a)+len(b))
+		for k, v := range a {
+			u[k] = v
+		}
+		for k, v := range b {
+			u[k] = u[k] || v
+		}
+		return u
+	}
+	u := KindInfo{
+		MatchAny:        info.MatchAny || other.MatchAny,
+		NonEmptyAttrs:   unionSet(info.NonEmptyAttrs, other.NonEmptyAttrs),
+		SubstituteAttrs: unionSet(info.SubstituteAttrs, other.SubstituteAttrs),
+		MergeableAttrs:  unionSet(info.MergeableAttrs, other.MergeableAttrs),
+		ResolveAttrs:    unionSet(info.ResolveAttrs, other.ResolveAttrs),
+	}
+	u.MatchAttrs = append(u.MatchAttrs, info.MatchAttrs...)
+	for _, attr := range other.MatchAttrs {
+		found := false
+		for _, a := range u.MatchAttrs {
+			found = found || a == attr
+		}
+		if !found {
+			u.MatchAttrs = append(u.MatchAttrs, attr)
+		}
+	}
+	return u
+}